@@ -0,0 +1,106 @@
+package fixture
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// isArchivePath returns whether name is a .zip, .tar.gz or .tgz file, which
+// is loaded as a directory of table files.
+func isArchivePath(name string) bool {
+	name = strings.ToLower(name)
+
+	return strings.HasSuffix(name, ".zip") || strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
+
+// loadArchive loads the table files of an archive, expanded in memory. id is
+// the identifier of the archive file, which qualifies the ones of its files,
+// e.g. /path/to/seed.zip!/users.yaml.
+func (f *Fixture) loadArchive(name, id string, b []byte, includeStack []string) error {
+	var archiveFS fs.FS
+
+	if strings.HasSuffix(strings.ToLower(name), ".zip") {
+		r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			return fmt.Errorf("failed to read zip archive %s: %w", name, err)
+		}
+
+		archiveFS = r
+	} else {
+		files, err := readTarGz(b)
+		if err != nil {
+			return fmt.Errorf("failed to read tar.gz archive %s: %w", name, err)
+		}
+
+		archiveFS = &objectStoreFS{ctx: f.Context, store: files}
+	}
+
+	return f.loadPath(&fileSource{fsys: archiveFS, prefix: id + "!/"}, ".", includeStack)
+}
+
+// memoryStore is an ObjectStore with a single bucket, keyed by file path.
+type memoryStore map[string][]byte
+
+func (m memoryStore) List(ctx context.Context, bucket, prefix string) ([]string, error) {
+	keys := make([]string, 0, len(m))
+
+	for key := range m {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+func (m memoryStore) Get(ctx context.Context, bucket, key string) ([]byte, error) {
+	b, ok := m[key]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+
+	return b, nil
+}
+
+func readTarGz(b []byte) (memoryStore, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	defer gz.Close()
+
+	files := make(memoryStore)
+	r := tar.NewReader(gz)
+
+	for {
+		header, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", header.Name, err)
+		}
+
+		files[path.Clean(strings.TrimPrefix(header.Name, "./"))] = content
+	}
+}
@@ -0,0 +1,104 @@
+package fixture
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var archiveFiles = map[string]string{
+	"plans.yaml": "free:\n  name: Free\n",
+	"users.yaml": "alice:\n  plan: =ref plans free\n",
+	"README.md":  "not a table",
+}
+
+func writeZip(t *testing.T, name string) {
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+
+	for file, content := range archiveFiles {
+		fw, err := w.Create(file)
+		if err != nil {
+			t.Fatalf("failed to create zip entry: %s", err)
+		}
+
+		if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write zip entry: %s", err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close zip: %s", err)
+	}
+
+	if err := os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("failed to write zip: %s", err)
+	}
+}
+
+func writeTarGz(t *testing.T, name string) {
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	w := tar.NewWriter(gz)
+
+	for file, content := range archiveFiles {
+		header := &tar.Header{Name: "./" + file, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+
+		if err := w.WriteHeader(header); err != nil {
+			t.Fatalf("failed to write tar header: %s", err)
+		}
+
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write tar entry: %s", err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close tar: %s", err)
+	}
+
+	if err := gz.Close(); err != nil {
+		t.Fatalf("failed to close gzip: %s", err)
+	}
+
+	if err := os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("failed to write tar.gz: %s", err)
+	}
+}
+
+func TestFixtureArchive(t *testing.T) {
+	dir := t.TempDir()
+
+	writeZip(t, filepath.Join(dir, "seed.zip"))
+	writeTarGz(t, filepath.Join(dir, "seed.tar.gz"))
+
+	for _, file := range []string{"seed.zip", "seed.tar.gz"} {
+		t.Run(file, func(st *testing.T) {
+			f := &Fixture{
+				Config: &Config{},
+				Writer: &memoryWriter{},
+				Dir:    dir,
+				File:   file,
+			}
+
+			if err := f.Apply(); err != nil {
+				st.Fatalf("failed to Apply: %s", err)
+			}
+
+			assert.Len(st, f.Database, 2)
+
+			// The archive's files are identified by its path.
+			id := filepath.Join(dir, file) + "!/"
+			assert.Contains(st, f.loaded, id)
+			assert.Equal(st, id+"users.yaml", f.recordSources[[2]string{"users", "alice"}].file)
+			assert.Equal(st, f.Database["plans"]["free"]["id"], f.Database["users"]["alice"]["plan"])
+			assert.Nil(st, f.FS)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package fixture
+
+import (
+	"crypto/sha256"
+	"sync"
+	"text/template"
+)
+
+// cache holds the decoded fixture bodies and parsed templates shared by
+// every fixture once EnableCache is called.
+var cache struct {
+	mu        sync.RWMutex
+	enabled   bool
+	bodies    map[bodyCacheKey]*decodedBody
+	templates map[templateCacheKey]*template.Template
+}
+
+type bodyCacheKey struct {
+	hash     [sha256.Size]byte
+	format   int
+	database bool
+}
+
+type templateCacheKey struct {
+	hash        [sha256.Size]byte
+	left, right string
+	missingKey  string
+	version     int
+}
+
+// EnableCache makes every fixture share the fixture files it decodes, so the
+// tests of a suite loading the same files don't decode them (nor parse their
+// templates) again. Files are keyed by the hash of their content after their
+// templates are executed, so changed files and different template data are
+// decoded again. Templates using functions of Config.FuncMap or
+// Fixture.FuncMap are not shared.
+func EnableCache() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.enabled = true
+
+	if cache.bodies == nil {
+		cache.bodies = make(map[bodyCacheKey]*decodedBody)
+		cache.templates = make(map[templateCacheKey]*template.Template)
+	}
+}
+
+// DisableCache disables and clears the cache enabled by EnableCache.
+func DisableCache() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.enabled = false
+	cache.bodies = nil
+	cache.templates = nil
+}
+
+// cachedBody returns a copy of the cached decoded body of data, or decodes
+// it with decode, caching the result if the cache is enabled.
+func cachedBody(format int, database bool, data []byte, decode func() (*decodedBody, error)) (*decodedBody, error) {
+	cache.mu.RLock()
+	enabled := cache.enabled
+	cache.mu.RUnlock()
+
+	if !enabled {
+		return decode()
+	}
+
+	key := bodyCacheKey{
+		hash:     sha256.Sum256(data),
+		format:   format,
+		database: database,
+	}
+
+	cache.mu.RLock()
+	body, ok := cache.bodies[key]
+	cache.mu.RUnlock()
+
+	if ok {
+		// Loading mutates the decoded body, e.g. when parsing records,
+		// but not its sources nor numbers.
+		return &decodedBody{raw: deepCopy(body.raw).(map[string]any), sources: body.sources, numbers: body.numbers}, nil
+	}
+
+	body, err := decode()
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mu.Lock()
+
+	if cache.bodies != nil {
+		cache.bodies[key] = &decodedBody{raw: deepCopy(body.raw).(map[string]any), sources: body.sources, numbers: body.numbers}
+	}
+
+	cache.mu.Unlock()
+
+	return body, nil
+}
+
+// sharedTemplateKey returns the key of a template in the cache, and whether
+// it can be shared with other fixtures.
+func (f *Fixture) sharedTemplateKey(hash [sha256.Size]byte, version int) (templateCacheKey, bool) {
+	cache.mu.RLock()
+	enabled := cache.enabled
+	cache.mu.RUnlock()
+
+	if !enabled || len(f.Config.FuncMap) > 0 || len(f.FuncMap) > 0 {
+		return templateCacheKey{}, false
+	}
+
+	left, right := f.templateDelims()
+
+	return templateCacheKey{
+		hash:       hash,
+		left:       left,
+		right:      right,
+		missingKey: f.Config.TemplateMissingKey,
+		version:    version,
+	}, true
+}
+
+func cachedTemplate(key templateCacheKey) *template.Template {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	return cache.templates[key]
+}
+
+func cacheTemplate(key templateCacheKey, t *template.Template) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.templates != nil {
+		cache.templates[key] = t
+	}
+}
@@ -0,0 +1,66 @@
+package fixture
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixtureCache(t *testing.T) {
+	EnableCache()
+	defer DisableCache()
+
+	fsys := fstest.MapFS{
+		"users.yaml": {Data: []byte("users:\n  alice:\n    name: \"{{ .name }}\"\n    plan: =ref plans free\n")},
+		"plans/plans.toml": {Data: []byte(`
+[free]
+name = "Free"
+
+[[free.limits]]
+name = "seats"
+max = 3
+`)},
+	}
+
+	apply := func(name string) *Fixture {
+		f := &Fixture{
+			Config:       &Config{},
+			Writer:       &memoryWriter{},
+			FS:           fsys,
+			File:         "users.yaml",
+			Files:        []string{"plans"},
+			TemplateData: map[string]any{"name": name},
+		}
+
+		f.MustApply(t)
+
+		return f
+	}
+
+	f := apply("Alice")
+	assert.Len(t, cache.bodies, 2)
+	assert.Len(t, cache.templates, 2)
+
+	// Decoded bodies are copied from the cache.
+	f.Database["users"]["alice"]["name"] = "Bob"
+	f.Database["plans"]["free"]["limits"].([]map[string]any)[0]["max"] = 10
+
+	f = apply("Alice")
+	assert.Len(t, cache.bodies, 2)
+	assert.Equal(t, "Alice", f.Database["users"]["alice"]["name"])
+	assert.Equal(t, int64(1), f.Database["users"]["alice"]["plan"])
+	assert.Equal(t, int64(3), f.Database["plans"]["free"]["limits"].([]map[string]any)[0]["max"])
+
+	// Different template data or content is decoded again.
+	f = apply("Carol")
+	assert.Len(t, cache.bodies, 3)
+	assert.Len(t, cache.templates, 2)
+	assert.Equal(t, "Carol", f.Database["users"]["alice"]["name"])
+
+	fsys["plans/plans.toml"] = &fstest.MapFile{Data: []byte("[free]\nname = \"Basic\"\n")}
+
+	f = apply("Carol")
+	assert.Len(t, cache.bodies, 4)
+	assert.Equal(t, "Basic", f.Database["plans"]["free"]["name"])
+}
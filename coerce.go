@@ -0,0 +1,243 @@
+package fixture
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// timeLayouts are the layouts of the strings coerced to timestamps and dates.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// coerceRecord converts the values of a record to the types of their
+// columns, with Config.CoerceValues.
+func (f *Fixture) coerceRecord(writer Writer, table string, record Record) error {
+	if !f.Config.CoerceValues {
+		return nil
+	}
+
+	reader, ok := writer.(SchemaReader)
+	if !ok {
+		return nil
+	}
+
+	columns, err := f.getColumns(reader, table)
+	if err != nil {
+		return fmt.Errorf("failed to get columns of table %s: %w", table, err)
+	}
+
+	for _, field := range sortedKeys(record) {
+		column, ok := columns[field]
+		if !ok {
+			continue
+		}
+
+		if err := column.checkEnum(record[field]); err != nil {
+			return fmt.Errorf("column %s: %w", field, err)
+		}
+
+		v, err := coerceValue(column.Type, record[field])
+		if err != nil {
+			return fmt.Errorf("column %s of type %s: %w", field, column.Type, err)
+		}
+
+		record[field] = v
+	}
+
+	return nil
+}
+
+// coerceValue converts v to a value of the SQL type typ. Values of other
+// types, nil and Raw values are returned as is.
+func coerceValue(typ string, v any) (any, error) {
+	if _, ok := v.(Raw); ok || v == nil {
+		return v, nil
+	}
+
+	if elemType, ok := strings.CutSuffix(typ, "[]"); ok {
+		return coerceArray(elemType, v)
+	}
+
+	switch typ {
+	case "smallint", "integer", "bigint":
+		return coerceInteger(v)
+	case "numeric", "decimal":
+		return coerceDecimal(v)
+	case "real", "double precision":
+		return coerceFloat(v)
+	case "boolean":
+		return coerceBool(v)
+	case "uuid":
+		return coerceUUID(v)
+	case "timestamp with time zone", "timestamp without time zone", "date":
+		return coerceTime(v)
+	case "text", "character varying", "character":
+		return coerceString(v)
+	case "hstore":
+		return ToHstore(v)
+	}
+
+	return v, nil
+}
+
+// coerceArray converts the elements of a list to elemType, returning a
+// slice of their type (see postgresArray).
+func coerceArray(elemType string, v any) (any, error) {
+	list, ok := v.([]any)
+	if !ok {
+		// E.g. typed slices or array literals.
+		return v, nil
+	}
+
+	coerced := make([]any, len(list))
+
+	for i, elem := range list {
+		c, err := coerceValue(elemType, elem)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+
+		coerced[i] = c
+	}
+
+	return postgresArray(coerced), nil
+}
+
+func coerceInteger(v any) (any, error) {
+	switch v := v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return v, nil
+	case float64:
+		if v != math.Trunc(v) {
+			return nil, fmt.Errorf("cannot convert %v to an integer without losing its fraction", v)
+		}
+
+		return int64(v), nil
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", v)
+		}
+
+		return n, nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %T %v to an integer", v, v)
+}
+
+func coerceDecimal(v any) (any, error) {
+	if s, ok := postgresDecimal(v); ok {
+		return decimal.RequireFromString(s), nil
+	}
+
+	switch v := v.(type) {
+	case decimal.Decimal:
+		return v, nil
+	case int:
+		return decimal.NewFromInt(int64(v)), nil
+	case int64:
+		return decimal.NewFromInt(v), nil
+	case float64:
+		return decimal.NewFromFloat(v), nil
+	case string:
+		d, err := decimal.NewFromString(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid decimal %q", v)
+		}
+
+		return d, nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %T %v to a decimal", v, v)
+}
+
+func coerceFloat(v any) (any, error) {
+	switch v := v.(type) {
+	case float32, float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", v)
+		}
+
+		return n, nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %T %v to a float", v, v)
+}
+
+func coerceBool(v any) (any, error) {
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean %q", v)
+		}
+
+		return b, nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %T %v to a boolean", v, v)
+}
+
+func coerceUUID(v any) (any, error) {
+	switch v := v.(type) {
+	case uuid.UUID, [16]byte:
+		return v, nil
+	case string:
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uuid %q", v)
+		}
+
+		return id, nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %T %v to a uuid", v, v)
+}
+
+func coerceTime(v any) (any, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+
+		return nil, fmt.Errorf("invalid time %q, expected RFC 3339 or \"2006-01-02 15:04:05\"", v)
+	}
+
+	return nil, fmt.Errorf("cannot convert %T %v to a time", v, v)
+}
+
+func coerceString(v any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case int, int64, float64, bool, decimal.Decimal, uuid.UUID:
+		return fmt.Sprint(v), nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %T %v to a string", v, v)
+}
@@ -0,0 +1,103 @@
+package fixture
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixtureCoerceValues(t *testing.T) {
+	writer := &schemaWriter{tables: map[string][]Column{
+		"orders": {
+			{Name: "id", Type: "bigint"},
+			{Name: "uid", Type: "uuid"},
+			{Name: "total", Type: "numeric"},
+			{Name: "paid", Type: "boolean"},
+			{Name: "created_at", Type: "timestamp with time zone"},
+			{Name: "note", Type: "text"},
+		},
+	}}
+
+	f := &Fixture{
+		Config: &Config{CoerceValues: true},
+		Writer: writer,
+		Body: strings.NewReader(`orders:
+  1:
+    id: "1"
+    uid: 0b7c7a4e-3a36-4c3e-9f1c-5f0d2b1c8e11
+    total: 10
+    paid: "true"
+    created_at: "2024-01-02 03:04:05"
+    note: 42
+`),
+		BodyFormat: ".yaml",
+	}
+
+	f.MustApply(t)
+
+	assert.Equal(t, Record{
+		"id":         int64(1),
+		"uid":        uuid.MustParse("0b7c7a4e-3a36-4c3e-9f1c-5f0d2b1c8e11"),
+		"total":      decimal.NewFromInt(10),
+		"paid":       true,
+		"created_at": time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		"note":       "42",
+	}, writer.records["orders"][0])
+
+	f = &Fixture{
+		Config:     &Config{CoerceValues: true},
+		Writer:     &schemaWriter{tables: writer.tables},
+		Body:       strings.NewReader("orders:\n  1:\n    uid: not-a-uuid\n"),
+		BodyFormat: ".yaml",
+	}
+
+	assert.EqualError(t, f.Apply(), `failed to coerce record "orders"."1": column uid of type uuid: invalid uuid "not-a-uuid"`)
+}
+
+func TestFixtureCoerceArrays(t *testing.T) {
+	writer := &schemaWriter{tables: map[string][]Column{
+		"users": {{Name: "id", Type: "bigint"}},
+		"teams": {
+			{Name: "id", Type: "bigint"},
+			{Name: "member_ids", Type: "bigint[]"},
+			{Name: "keys", Type: "uuid[]"},
+			{Name: "tags", Type: "text[]"},
+		},
+	}}
+
+	f := &Fixture{
+		Config: &Config{CoerceValues: true},
+		Writer: writer,
+		Body: strings.NewReader(`users:
+  alice: {}
+  bob: {}
+teams:
+  core:
+    member_ids: [=ref users alice, =ref users bob, 3]
+    keys: [0b7c7a4e-3a36-4c3e-9f1c-5f0d2b1c8e11]
+    tags: [go, 1]
+`),
+		BodyFormat: ".yaml",
+	}
+
+	f.MustApply(t)
+
+	team := f.Database["teams"]["core"]
+	users := f.Database["users"]
+	assert.Equal(t, []int64{users["alice"]["id"].(int64), users["bob"]["id"].(int64), 3}, team["member_ids"])
+	assert.Equal(t, []uuid.UUID{uuid.MustParse("0b7c7a4e-3a36-4c3e-9f1c-5f0d2b1c8e11")}, team["keys"])
+	assert.Equal(t, []string{"go", "1"}, team["tags"])
+
+	f = &Fixture{
+		Config:     &Config{CoerceValues: true},
+		Writer:     &schemaWriter{tables: writer.tables},
+		Body:       strings.NewReader("teams:\n  core:\n    keys: [nope]\n"),
+		BodyFormat: ".yaml",
+	}
+
+	assert.EqualError(t, f.Apply(), `failed to coerce record "teams"."core": column keys of type uuid[]: element 0: invalid uuid "nope"`)
+}
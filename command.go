@@ -2,16 +2,35 @@ package fixture
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/netip"
+	"os"
 	"strconv"
 	"strings"
-	"text/scanner"
-	"text/template"
+	"sync"
+	"text/template/parse"
 	"time"
 
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
 	"github.com/google/uuid"
 	"github.com/oklog/ulid/v2"
+	"github.com/shopspring/decimal"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type CommandFunc func(in *CommandInput) (*CommandOutput, error)
@@ -23,72 +42,1496 @@ type CommandInput struct {
 	Field   string
 
 	Line string
+
+	// Piped is true when the command is part of a pipeline
+	// (e.g. "=ulid | =sha256") and not its first command. Input is then the
+	// previous command's value, which is also prepended, quoted, to Line.
+	Piped bool
+	Input any
+}
+
+type CommandDependency struct {
+	Label    [2]string
+	Callback func() (any, error)
+
+	// FieldCallbacks update other fields of the record, by name, once the
+	// dependency is written.
+	FieldCallbacks map[string]func() (any, error)
+}
+
+type CommandOutput struct {
+	Dependencies []*CommandDependency
+	IsUpdate     bool
+	Value        any
+
+	// Omit removes the field from the record (or its parent map) instead of
+	// setting it to Value.
+	Omit bool
+
+	// Resolve, if set, is called right before the record is written and its
+	// return replaces the field value.
+	Resolve func() (any, error)
+}
+
+// ScanLine splits the command line into positional arguments and key=value
+// arguments. Arguments are separated by whitespace and can be quoted to
+// include spaces or an "=": double quotes and backquotes follow Go syntax
+// (e.g. "a \"b\"\n"), while single quotes keep their content as is (e.g.
+// '{"a": 1}'). Quotes are removed from the returned values.
+func (in *CommandInput) ScanLine() ([]string, map[string]string, error) {
+	if in.Line == "" {
+		return nil, nil, nil
+	}
+
+	tokens, err := scanTokens(in.Line)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var args []string
+	var kwargs map[string]string
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+
+		if token.equals {
+			return nil, nil, errors.New("unexpected = without key")
+		}
+
+		if i+1 >= len(tokens) || !tokens[i+1].equals {
+			args = append(args, token.text)
+			continue
+		}
+
+		if kwargs == nil {
+			kwargs = make(map[string]string)
+		}
+
+		// An = at the end of the line sets an empty value.
+		var value string
+
+		if i+2 < len(tokens) {
+			if tokens[i+2].equals {
+				return nil, nil, fmt.Errorf("unexpected = after %s=", token.text)
+			}
+
+			value = tokens[i+2].text
+		}
+
+		kwargs[token.text] = value
+		i += 2
+	}
+
+	return args, kwargs, nil
+}
+
+// ScanLineValues is like ScanLine, but kwargs whose value is a JSON object
+// or array are decoded into map[string]any or []any, so commands can accept
+// structured arguments. E.g.:
+//
+//	=fake address locale=pt_BR fields='["street", "city"]'
+func (in *CommandInput) ScanLineValues() ([]string, map[string]any, error) {
+	args, rawKwargs, err := in.ScanLine()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if rawKwargs == nil {
+		return args, nil, nil
+	}
+
+	kwargs := make(map[string]any, len(rawKwargs))
+
+	for k, v := range rawKwargs {
+		if v == "" || (v[0] != '{' && v[0] != '[') {
+			kwargs[k] = v
+			continue
+		}
+
+		var value any
+
+		if err := json.Unmarshal([]byte(v), &value); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode %s: %w", k, err)
+		}
+
+		kwargs[k] = value
+	}
+
+	return args, kwargs, nil
+}
+
+type scanToken struct {
+	text   string
+	equals bool
+}
+
+func scanTokens(line string) ([]scanToken, error) {
+	var tokens []scanToken
+	var buf strings.Builder
+	var inToken bool
+
+	flush := func() {
+		if inToken {
+			tokens = append(tokens, scanToken{text: buf.String()})
+			buf.Reset()
+			inToken = false
+		}
+	}
+
+	for i := 0; i < len(line); {
+		switch c := line[i]; c {
+		case ' ', '\t', '\n', '\r':
+			flush()
+			i++
+		case '=':
+			flush()
+			tokens = append(tokens, scanToken{text: "=", equals: true})
+			i++
+		case '"', '`':
+			end := quotedEnd(line, i)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted string at %d", i)
+			}
+
+			s, err := strconv.Unquote(line[i:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted string %s: %w", line[i:end], err)
+			}
+
+			buf.WriteString(s)
+			inToken = true
+			i = end
+		case '\'':
+			j := strings.IndexByte(line[i+1:], '\'')
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated quoted string at %d", i)
+			}
+
+			buf.WriteString(line[i+1 : i+1+j])
+			inToken = true
+			i += j + 2
+		default:
+			buf.WriteByte(c)
+			inToken = true
+			i++
+		}
+	}
+
+	flush()
+
+	return tokens, nil
+}
+
+// quotedEnd returns the index after the closing quote of the Go quoted
+// string starting at line[start], or -1 if it is not terminated.
+func quotedEnd(line string, start int) int {
+	quote := line[start]
+
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if quote == '"' {
+				i++
+			}
+		case quote:
+			return i + 1
+		}
+	}
+
+	return -1
+}
+
+// escapedCommandPrefix starts the string values which are not commands, but
+// literal values starting with "=", e.g. "==SUM(A1)" for "=SUM(A1)".
+const escapedCommandPrefix = "=="
+
+// escapeValue returns v with its strings starting with "=", including in
+// lists and maps, escaped so they are not parsed as commands.
+func escapeValue(v any) any {
+	switch t := v.(type) {
+	case string:
+		if strings.HasPrefix(t, "=") {
+			return "=" + t
+		}
+	case map[string]any:
+		m := make(map[string]any, len(t))
+
+		for k := range t {
+			m[k] = escapeValue(t[k])
+		}
+
+		return m
+	case []any:
+		l := make([]any, len(t))
+
+		for i := range t {
+			l[i] = escapeValue(t[i])
+		}
+
+		return l
+	}
+
+	return v
+}
+
+var commands = map[string]CommandFunc{
+	"base64dec": base64DecodeCommand,
+	"bcrypt":    bcryptCommand,
+	"bytes":     bytesCommand,
+	"choice":    choiceCommand,
+	"decimal":   decimalCommand,
+	"env":       envCommand,
+	"expr":      exprCommand,
+	"geo":       geoCommand,
+	"hex":       hexCommand,
+	"hmac":      hmacCommand,
+	"inet":      inetCommand,
+	"key":       keyCommand,
+	"lookup":    lookupCommand,
+	"mac":       macCommand,
+	"now":       nowCommand,
+	"omit":      omitCommand,
+	"rand":      randCommand,
+	"raw":       rawCommand,
+	"ref":       refCommand,
+	"refany":    refanyCommand,
+	"refpath":   refpathCommand,
+	"self":      selfCommand,
+	"seq":       seqCommand,
+	"sha256":    sha256Command,
+	"snowflake": snowflakeCommand,
+	"sql":       sqlCommand,
+	"template":  templateCommand,
+	"ulid":      ulidCommand,
+	"uuidv4":    uuidv4Command,
+	"uuidv5":    uuidv5Command,
+	"xref":      xrefCommand,
+}
+
+var commandsMu sync.RWMutex
+
+var ErrCommandExists = errors.New("command already exists")
+
+// RegisterCommand adds a command available to every fixture, e.g. "stripe_customer"
+// for =stripe_customer. It returns ErrCommandExists if the name is already taken,
+// including by a built-in command. Fixture.Commands and Config.Commands can be
+// used to add or override commands for a single fixture or config instead.
+func RegisterCommand(name string, fn CommandFunc) error {
+	if err := validateCommandName(name); err != nil {
+		return err
+	}
+
+	if fn == nil {
+		return fmt.Errorf("nil func for command %s", name)
+	}
+
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+
+	if _, ok := commands[name]; ok {
+		return fmt.Errorf("%s: %w", name, ErrCommandExists)
+	}
+
+	commands[name] = fn
+
+	return nil
+}
+
+func validateCommandName(name string) error {
+	if name == "" {
+		return errors.New("empty command name")
+	}
+
+	if strings.ContainsAny(name, " \t\n=.") {
+		// Dots are reserved for CommandProvider namespaces.
+		return fmt.Errorf("invalid command name %q", name)
+	}
+
+	return nil
+}
+
+// CommandProvider is a library of commands registered under a namespace, so
+// that =<namespace>.<command> never collides with built-in or other commands.
+// E.g. a provider with namespace "myco" and a "token" command is used as:
+//
+//	=myco.token scope=admin
+type CommandProvider interface {
+	Namespace() string
+	Commands() map[string]CommandFunc
+}
+
+var providers = map[string]CommandProvider{}
+
+// RegisterCommandProvider makes the provider's commands available to every
+// fixture. It returns ErrCommandExists if the namespace is already taken.
+// Config.CommandProviders can be used to add providers to a single config.
+func RegisterCommandProvider(provider CommandProvider) error {
+	namespace := provider.Namespace()
+
+	if err := validateCommandName(namespace); err != nil {
+		return fmt.Errorf("invalid namespace: %w", err)
+	}
+
+	for name := range provider.Commands() {
+		if err := validateCommandName(name); err != nil {
+			return fmt.Errorf("namespace %s: %w", namespace, err)
+		}
+	}
+
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+
+	if _, ok := providers[namespace]; ok {
+		return fmt.Errorf("namespace %s: %w", namespace, ErrCommandExists)
+	}
+
+	providers[namespace] = provider
+
+	return nil
+}
+
+// command returns the command with the given name, looking up the fixture's
+// commands first, then the config's and finally the registered ones.
+// Namespaced names are looked up in the config's providers, then the
+// registered ones.
+func (f *Fixture) command(name string) (CommandFunc, bool) {
+	if fn, ok := f.Commands[name]; ok {
+		return fn, true
+	}
+
+	if fn, ok := f.Config.Commands[name]; ok {
+		return fn, true
+	}
+
+	if namespace, name, ok := strings.Cut(name, "."); ok {
+		for _, provider := range f.Config.CommandProviders {
+			if provider.Namespace() == namespace {
+				fn, ok := provider.Commands()[name]
+				return fn, ok
+			}
+		}
+
+		commandsMu.RLock()
+		provider, ok := providers[namespace]
+		commandsMu.RUnlock()
+
+		if !ok {
+			return nil, false
+		}
+
+		fn, ok := provider.Commands()[name]
+
+		return fn, ok
+	}
+
+	commandsMu.RLock()
+	defer commandsMu.RUnlock()
+
+	fn, ok := commands[name]
+
+	return fn, ok
+}
+
+func base64DecodeCommand(in *CommandInput) (*CommandOutput, error) {
+	args, _, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected at least 1 positional argument")
+	}
+
+	encoded := args[0]
+
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base64 string: %w", err)
+	}
+
+	out := &CommandOutput{
+		Value: b,
+	}
+
+	return out, nil
+}
+
+// bcryptCommand returns the bcrypt hash of the given plaintext. E.g.:
+//
+//	=bcrypt "hunter2" cost=12
+func bcryptCommand(in *CommandInput) (*CommandOutput, error) {
+	args, kwargs, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected at least 1 positional argument")
+	}
+
+	plaintext := args[0]
+
+	cost := bcrypt.DefaultCost
+
+	if v, ok := kwargs["cost"]; ok {
+		cost, err = strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse cost: %w", err)
+		}
+	}
+
+	b, err := bcrypt.GenerateFromPassword([]byte(plaintext), cost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate bcrypt hash: %w", err)
+	}
+
+	out := &CommandOutput{
+		Value: string(b),
+	}
+
+	return out, nil
+}
+
+// bytesCommand decodes a string into []byte using the given encoding, which
+// defaults to hex. E.g.:
+//
+//	=bytes "aGVsbG8" encoding=base64url
+func bytesCommand(in *CommandInput) (*CommandOutput, error) {
+	args, kwargs, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected at least 1 positional argument")
+	}
+
+	b, err := decodeBytes(args[0], kwargs["encoding"])
+	if err != nil {
+		return nil, err
+	}
+
+	out := &CommandOutput{
+		Value: b,
+	}
+
+	return out, nil
+}
+
+// hexCommand is a shorthand for =bytes with hex encoding. E.g.:
+//
+//	=hex "deadbeef"
+func hexCommand(in *CommandInput) (*CommandOutput, error) {
+	args, _, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected at least 1 positional argument")
+	}
+
+	b, err := decodeBytes(args[0], "hex")
+	if err != nil {
+		return nil, err
+	}
+
+	out := &CommandOutput{
+		Value: b,
+	}
+
+	return out, nil
+}
+
+// decodeBytes decodes a string encoded as hex (default), base64 or
+// base64url, padded or not.
+func decodeBytes(encoded, encoding string) ([]byte, error) {
+	if encoding == "" {
+		encoding = "hex"
+	}
+
+	var b []byte
+	var err error
+
+	switch encoding {
+	case "hex":
+		b, err = hex.DecodeString(encoded)
+	case "base64":
+		b, err = base64.StdEncoding.DecodeString(encoded)
+	case "base64url":
+		if len(encoded)%4 != 0 {
+			b, err = base64.RawURLEncoding.DecodeString(encoded)
+		} else {
+			b, err = base64.URLEncoding.DecodeString(encoded)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s string: %w", encoding, err)
+	}
+
+	return b, nil
+}
+
+// encodeDigest encodes a digest as hex (default), base64 or raw bytes.
+func encodeDigest(b []byte, encoding string) (any, error) {
+	switch encoding {
+	case "", "hex":
+		return hex.EncodeToString(b), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString(b), nil
+	case "raw":
+		return b, nil
+	}
+
+	return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+}
+
+// sha256Command returns the SHA-256 digest of the given value, encoded as
+// hex (default), base64 or raw bytes. E.g.:
+//
+//	=sha256 "some content" base64
+func sha256Command(in *CommandInput) (*CommandOutput, error) {
+	args, _, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected at least 1 positional argument")
+	}
+
+	value := args[0]
+
+	var encoding string
+
+	if len(args) > 1 {
+		encoding = args[1]
+	}
+
+	sum := sha256.Sum256([]byte(value))
+
+	v, err := encodeDigest(sum[:], encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &CommandOutput{
+		Value: v,
+	}
+
+	return out, nil
+}
+
+// hmacCommand returns the HMAC of the given value, using SHA-256 unless
+// another alg (sha1, sha512) is set. E.g.:
+//
+//	=hmac key="secret" "payload" hex
+func hmacCommand(in *CommandInput) (*CommandOutput, error) {
+	args, kwargs, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected at least 1 positional argument")
+	}
+
+	key, ok := kwargs["key"]
+	if !ok {
+		return nil, fmt.Errorf("missing key argument")
+	}
+
+	value := args[0]
+
+	var newHash func() hash.Hash
+
+	switch alg := kwargs["alg"]; alg {
+	case "", "sha256":
+		newHash = sha256.New
+	case "sha1":
+		newHash = sha1.New
+	case "sha512":
+		newHash = sha512.New
+	default:
+		return nil, fmt.Errorf("unsupported alg: %s", alg)
+	}
+
+	var encoding string
+
+	if len(args) > 1 {
+		encoding = args[1]
+	}
+
+	mac := hmac.New(newHash, []byte(key))
+	mac.Write([]byte(value))
+
+	v, err := encodeDigest(mac.Sum(nil), encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &CommandOutput{
+		Value: v,
+	}
+
+	return out, nil
+}
+
+// choiceCommand returns one of its positional arguments, picked using the
+// fixture's random source. Optional weights are given as a comma separated
+// list matching the arguments. E.g.:
+//
+//	=choice pending active suspended weights=1,8,1
+func choiceCommand(in *CommandInput) (*CommandOutput, error) {
+	args, kwargs, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected at least 1 positional argument")
+	}
+
+	weights := make([]float64, len(args))
+
+	if v, ok := kwargs["weights"]; ok {
+		parts := strings.Split(v, ",")
+
+		if len(parts) != len(args) {
+			return nil, fmt.Errorf("expected %d weights, got %d", len(args), len(parts))
+		}
+
+		for i := range parts {
+			weights[i], err = strconv.ParseFloat(parts[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse weight %q: %w", parts[i], err)
+			}
+
+			if weights[i] < 0 {
+				return nil, fmt.Errorf("weights must not be negative")
+			}
+		}
+	} else {
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+
+	var total float64
+
+	for i := range weights {
+		total += weights[i]
+	}
+
+	if total == 0 {
+		return nil, fmt.Errorf("weights must not all be zero")
+	}
+
+	n := in.Fixture.random().Float64() * total
+	pick := len(args) - 1
+
+	for i := range weights {
+		if n < weights[i] {
+			pick = i
+			break
+		}
+
+		n -= weights[i]
+	}
+
+	v := args[pick]
+
+	out := &CommandOutput{
+		Value: v,
+	}
+
+	return out, nil
+}
+
+// decimalCommand parses an exact decimal number into a decimal.Decimal, so
+// numeric columns are not subject to float rounding. When scale is set the
+// value is rounded to that many decimal places, and toString=true returns a
+// string that keeps the scale (e.g. "19.90"). E.g.:
+//
+//	=decimal "19.90" toString=true
+func decimalCommand(in *CommandInput) (*CommandOutput, error) {
+	args, kwargs, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected at least 1 positional argument")
+	}
+
+	s := args[0]
+
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse decimal %q: %w", s, err)
+	}
+
+	var scale int32
+
+	if d.Exponent() < 0 {
+		scale = -d.Exponent()
+	}
+
+	if v, ok := kwargs["scale"]; ok {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse scale: %w", err)
+		}
+
+		scale = int32(n)
+		d = d.Round(scale)
+	}
+
+	out := &CommandOutput{
+		Value: d,
+	}
+
+	if v, ok := kwargs["toString"]; ok && v == "true" {
+		out.Value = d.StringFixed(scale)
+	}
+
+	return out, nil
+}
+
+// envCommand returns the value of an environment variable, or the given
+// default when the variable is not set. E.g.:
+//
+//	=env API_HOST "http://localhost:8080"
+func envCommand(in *CommandInput) (*CommandOutput, error) {
+	args, _, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected at least 1 positional argument")
+	}
+
+	v, ok := os.LookupEnv(args[0])
+
+	if !ok {
+		if len(args) < 2 {
+			return nil, fmt.Errorf("environment variable %s is not set", args[0])
+		}
+
+		v = args[1]
+	}
+
+	out := &CommandOutput{
+		Value: v,
+	}
+
+	return out, nil
+}
+
+// exprCommand evaluates an expression (https://expr-lang.org) right before
+// the record is written, with the record's fields and the fixture's template
+// data (as data) in scope. Referenced fields are resolved first. E.g.:
+//
+//	=expr quantity * unit_price
+//	=expr data.currency + " " + string(total)
+func exprCommand(in *CommandInput) (*CommandOutput, error) {
+	fixture := in.Fixture
+	node := fixture.GetNode([2]string{in.Table, in.Key})
+	source := strings.TrimSpace(in.Line)
+
+	if source == "" {
+		return nil, fmt.Errorf("expected an expression")
+	}
+
+	tree, err := parser.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse expression: %w", err)
+	}
+
+	program, err := expr.Compile(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile expression: %w", err)
+	}
+
+	identifiers := new(exprIdentifiers)
+	ast.Walk(&tree.Node, identifiers)
+
+	for _, name := range identifiers.names {
+		if name == in.Field {
+			return nil, fmt.Errorf("field %s cannot reference itself", name)
+		}
+	}
+
+	return &CommandOutput{
+		Resolve: func() (any, error) {
+			if err := node.resolve(identifiers.names...); err != nil {
+				return nil, err
+			}
+
+			env := map[string]any{
+				"data": fixture.templateValues(),
+			}
+
+			for k, v := range fixture.Database[in.Table][in.Key] {
+				env[k] = v
+			}
+
+			v, err := expr.Run(program, env)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate expression: %w", err)
+			}
+
+			return v, nil
+		},
+	}, nil
+}
+
+// exprIdentifiers collects the identifiers used by an expression.
+type exprIdentifiers struct {
+	names []string
+}
+
+func (v *exprIdentifiers) Visit(node *ast.Node) {
+	if n, ok := (*node).(*ast.IdentifierNode); ok {
+		v.names = append(v.names, n.Value)
+	}
+}
+
+// geoCommand returns a geographic point, either from the given lat/lon or
+// picked randomly within bbox (minLat,minLon,maxLat,maxLon). The point is
+// formatted as EWKT by default, which PostGIS accepts for geometry and
+// geography columns, or as WKT or a []float64{lat, lon} pair. E.g.:
+//
+//	=geo point -23.55 -46.63
+//	=geo point bbox=-34,-74,5,-34 format=latlon
+func geoCommand(in *CommandInput) (*CommandOutput, error) {
+	args, kwargs, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	if len(args) == 0 || args[0] != "point" {
+		return nil, fmt.Errorf("expected geometry type point")
+	}
+
+	var lat, lon float64
+
+	switch len(args) {
+	case 1:
+		bbox := [4]float64{-90, -180, 90, 180}
+
+		if v, ok := kwargs["bbox"]; ok {
+			parts := strings.Split(v, ",")
+
+			if len(parts) != 4 {
+				return nil, fmt.Errorf("expected bbox with 4 values, got %d", len(parts))
+			}
+
+			for i := range parts {
+				bbox[i], err = strconv.ParseFloat(parts[i], 64)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse bbox value %q: %w", parts[i], err)
+				}
+			}
+		}
+
+		r := in.Fixture.random()
+		lat = bbox[0] + r.Float64()*(bbox[2]-bbox[0])
+		lon = bbox[1] + r.Float64()*(bbox[3]-bbox[1])
+	case 3:
+		lat, err = strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse lat: %w", err)
+		}
+
+		lon, err = strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse lon: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("expected both lat and lon, or neither")
+	}
+
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return nil, fmt.Errorf("point out of range: %v %v", lat, lon)
+	}
+
+	out := &CommandOutput{}
+
+	switch format := kwargs["format"]; format {
+	case "", "ewkt":
+		out.Value = fmt.Sprintf("SRID=4326;POINT(%v %v)", lon, lat)
+	case "wkt":
+		out.Value = fmt.Sprintf("POINT(%v %v)", lon, lat)
+	case "latlon":
+		out.Value = []float64{lat, lon}
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+
+	return out, nil
+}
+
+// lookupCommand finds an existing record (not defined in the fixture) by
+// field equality when the record is written, and returns one of its fields,
+// which defaults to the table's primary key. The writer must implement
+// Finder. E.g.:
+//
+//	=lookup users email="admin@example.com"
+//	=lookup users email="admin@example.com" name
+func lookupCommand(in *CommandInput) (*CommandOutput, error) {
+	fixture := in.Fixture
+
+	args, kwargs, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected at least 1 positional argument")
+	}
+
+	if len(kwargs) == 0 {
+		return nil, fmt.Errorf("expected at least 1 field to look up")
+	}
+
+	table := args[0]
+
+	var field string
+
+	if len(args) > 1 {
+		field = args[1]
+	} else {
+		field, err = fixture.getPrimaryKeyName(table)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	where := make(Record, len(kwargs))
+
+	for k, v := range kwargs {
+		where[k] = v
+	}
+
+	out := &CommandOutput{
+		Resolve: func() (any, error) {
+			writer, err := fixture.getWriter(table)
+			if err != nil {
+				return nil, err
+			}
+
+			finder, ok := writer.(Finder)
+			if !ok {
+				return nil, fmt.Errorf("writer %T does not support lookups", writer)
+			}
+
+			record, err := finder.Find(fixture, table, where)
+			if err != nil {
+				return nil, fmt.Errorf("failed to look up %s: %w", table, err)
+			}
+
+			v, ok := record[field]
+			if !ok {
+				return nil, fmt.Errorf("failed to look up %s: %w", table, ErrFieldNotFound)
+			}
+
+			return v, nil
+		},
+	}
+
+	return out, nil
+}
+
+// inetCommand returns a random IP address (netip.Addr) within the given
+// range, which defaults to 10.0.0.0/8 for v4 and fd00::/8 for v6. When prefix
+// is set, the masked network is returned as a netip.Prefix instead, suitable
+// for cidr columns. E.g.:
+//
+//	=inet v6
+//	=inet within="192.168.0.0/16" prefix=24 toString=true
+func inetCommand(in *CommandInput) (*CommandOutput, error) {
+	args, kwargs, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	var within string
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "v4":
+			within = "10.0.0.0/8"
+		case "v6":
+			within = "fd00::/8"
+		default:
+			return nil, fmt.Errorf("unsupported ip version: %s", args[0])
+		}
+	} else {
+		within = "10.0.0.0/8"
+	}
+
+	if v, ok := kwargs["within"]; ok {
+		within = v
+	}
+
+	network, err := netip.ParsePrefix(within)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse within %q: %w", within, err)
+	}
+
+	network = network.Masked()
+	base := network.Addr().AsSlice()
+	b := make([]byte, len(base))
+
+	in.Fixture.random().Read(b)
+
+	// Keep the network bits and randomize the host bits.
+	for i := range b {
+		bits := network.Bits() - i*8
+
+		switch {
+		case bits >= 8:
+			b[i] = base[i]
+		case bits > 0:
+			mask := byte(0xff << (8 - bits))
+			b[i] = base[i]&mask | b[i]&^mask
+		}
+	}
+
+	addr, _ := netip.AddrFromSlice(b)
+	out := &CommandOutput{}
+
+	if v, ok := kwargs["prefix"]; ok {
+		bits, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse prefix: %w", err)
+		}
+
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build prefix: %w", err)
+		}
+
+		out.Value = prefix
+	} else {
+		out.Value = addr
+	}
+
+	if v, ok := kwargs["toString"]; ok && v == "true" {
+		out.Value = fmt.Sprint(out.Value)
+	}
+
+	return out, nil
+}
+
+// macCommand returns a random, locally administered unicast MAC address
+// (net.HardwareAddr). E.g.:
+//
+//	=mac toString=true
+func macCommand(in *CommandInput) (*CommandOutput, error) {
+	_, kwargs, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	b := make(net.HardwareAddr, 6)
+
+	in.Fixture.random().Read(b)
+
+	b[0] = b[0]&^0x01 | 0x02
+
+	out := &CommandOutput{
+		Value: b,
+	}
+
+	if v, ok := kwargs["toString"]; ok && v == "true" {
+		out.Value = b.String()
+	}
+
+	return out, nil
+}
+
+func keyCommand(in *CommandInput) (*CommandOutput, error) {
+	args, _, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	var t string
+
+	if len(args) > 0 {
+		t = args[0]
+	}
+
+	var v any
+
+	switch t {
+	case "":
+		v = in.Key
+	case "int":
+		v, err = strconv.Atoi(in.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert key to int: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", t)
+	}
+
+	out := &CommandOutput{
+		Value: v,
+	}
+
+	return out, nil
+}
+
+// nowCommand returns the current UTC time, optionally shifted by one or more
+// durations and truncated to a unit. E.g.:
+//
+//	=now -24h +30m truncate=hour format=rfc3339
+func nowCommand(in *CommandInput) (*CommandOutput, error) {
+	args, kwargs, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	t := time.Now().UTC()
+
+	for i := range args {
+		d, err := time.ParseDuration(args[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse offset %q: %w", args[i], err)
+		}
+
+		t = t.Add(d)
+	}
+
+	if v, ok := kwargs["truncate"]; ok {
+		switch v {
+		case "second":
+			t = t.Truncate(time.Second)
+		case "minute":
+			t = t.Truncate(time.Minute)
+		case "hour":
+			t = t.Truncate(time.Hour)
+		case "day":
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		case "month":
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		case "year":
+			t = time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+		default:
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, fmt.Errorf("unsupported truncate unit: %s", v)
+			}
+
+			t = t.Truncate(d)
+		}
+	}
+
+	out := &CommandOutput{}
+
+	v, ok := kwargs["format"]
+	if !ok {
+		out.Value = t
+		return out, nil
+	}
+
+	switch v {
+	case "rfc3339":
+		out.Value = t.Format(time.RFC3339)
+	case "rfc3339nano":
+		out.Value = t.Format(time.RFC3339Nano)
+	case "date":
+		out.Value = t.Format(time.DateOnly)
+	case "unix":
+		out.Value = t.Unix()
+	case "unixmilli":
+		out.Value = t.UnixMilli()
+	default:
+		out.Value = t.Format(v)
+	}
+
+	return out, nil
+}
+
+// omitCommand removes the field from the record before it is written, which
+// differs from setting it to null. It is mostly useful to drop a field set by
+// TableOptions.DefaultValues for a single record. E.g.:
+//
+//	=omit
+func omitCommand(in *CommandInput) (*CommandOutput, error) {
+	out := &CommandOutput{
+		Omit: true,
+	}
+
+	return out, nil
+}
+
+// randCommand returns a random int in [min, max] or float in [min, max),
+// using the fixture's random source so results follow Fixture.Seed. E.g.:
+//
+//	=rand int 1 100
+//	=rand float 0 1
+func randCommand(in *CommandInput) (*CommandOutput, error) {
+	args, _, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	if len(args) != 3 {
+		return nil, fmt.Errorf("expected 3 positional arguments")
+	}
+
+	r := in.Fixture.random()
+	out := &CommandOutput{}
+
+	switch args[0] {
+	case "int":
+		min, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse min: %w", err)
+		}
+
+		max, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse max: %w", err)
+		}
+
+		if max < min {
+			return nil, fmt.Errorf("max must be greater than or equal to min")
+		}
+
+		out.Value = randInt64(r, min, max)
+	case "float":
+		min, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse min: %w", err)
+		}
+
+		max, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse max: %w", err)
+		}
+
+		if max < min {
+			return nil, fmt.Errorf("max must be greater than or equal to min")
+		}
+
+		out.Value = min + r.Float64()*(max-min)
+	default:
+		return nil, fmt.Errorf("unsupported rand type: %s", args[0])
+	}
+
+	return out, nil
+}
+
+// randInt64 returns a random integer in [min, max], including when the span
+// of the range doesn't fit in an int64, e.g. for the whole int64 range.
+func randInt64(r *rand.Rand, min, max int64) int64 {
+	// The span minus 1, exact as an unsigned integer.
+	span := uint64(max) - uint64(min)
+
+	if span < math.MaxInt64 {
+		return min + r.Int63n(int64(span)+1)
+	}
+
+	if span == math.MaxUint64 {
+		return int64(r.Uint64())
+	}
+
+	// Rejection sampling, keeping the distribution uniform.
+	for {
+		if n := r.Uint64(); n <= span {
+			return int64(uint64(min) + n)
+		}
+	}
+}
+
+// rawCommand returns the rest of the line as a Raw value, which PostgresWriter
+// embeds as a SQL expression. E.g.:
+//
+//	=raw NOW() - interval '1 day'
+func rawCommand(in *CommandInput) (*CommandOutput, error) {
+	expr := strings.TrimSpace(in.Line)
+
+	if expr == "" {
+		return nil, fmt.Errorf("missing expression")
+	}
+
+	out := &CommandOutput{
+		Value: Raw(expr),
+	}
+
+	return out, nil
+}
+
+// refCommand references a field (the primary key by default) of another
+// fixture record, which is written before this one. The field can be a dot
+// separated path into nested values. If the command's field is the name of
+// a composite reference (see TableOptions.CompositeReferences), all of its
+// fields are populated instead. E.g.:
+//
+//	=ref users 1
+//	=ref users # email
+//	=ref users 1 profile.settings.theme
+func refCommand(in *CommandInput) (*CommandOutput, error) {
+	args, _, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	if len(args) < 2 {
+		return nil, fmt.Errorf("expected at least 2 positional arguments")
+	}
+
+	table, key := args[0], args[1]
+
+	var field string
+
+	if len(args) >= 3 {
+		field = args[2]
+	}
+
+	if key == "#" {
+		key = in.Key
+	}
+
+	if composite := in.Fixture.Config.GetCompositeReference(in.Table, in.Field); composite != nil {
+		return compositeRefOutput(in, composite, table, key, field)
+	}
+
+	return refOutput(in, "ref", table, key, field), nil
 }
 
-type CommandDependency struct {
-	Label    [2]string
-	Callback func() (any, error)
+// compositeRefOutput returns a CommandOutput depending on table/key, which
+// populates every field of the composite reference once the referenced record
+// is written. The command's own field is omitted from the record.
+func compositeRefOutput(in *CommandInput, composite *CompositeReference, table, key, field string) (*CommandOutput, error) {
+	fixture := in.Fixture
+	key = fixture.resolveKey(table, key)
+
+	if table != composite.Table {
+		return nil, fmt.Errorf("composite reference %s.%s expects table %s, got %s", in.Table, in.Field, composite.Table, table)
+	}
+
+	if field != "" {
+		return nil, fmt.Errorf("composite reference %s.%s does not accept a field", in.Table, in.Field)
+	}
+
+	fixture.Logger.Debug().
+		Str("command", "ref").
+		Str("table", table).
+		Str("key", key).
+		Interface("fields", composite.Fields).
+		Send()
+
+	callbacks := make(map[string]func() (any, error), len(composite.Fields))
+
+	for localField, refField := range composite.Fields {
+		// Copy to prevent closure issues.
+		refFieldCopy := refField
+
+		callbacks[localField] = func() (any, error) {
+			return fixture.getFieldPath(table, key, refFieldCopy)
+		}
+	}
+
+	return &CommandOutput{
+		Dependencies: []*CommandDependency{{
+			Label:          [2]string{table, key},
+			FieldCallbacks: callbacks,
+		}},
+		Omit: true,
+	}, nil
 }
 
-type CommandOutput struct {
-	Dependencies []*CommandDependency
-	IsUpdate     bool
-	Value        any
+// refOutput returns a CommandOutput depending on table/key, whose value is
+// the given field of the referenced record once it is written. The field
+// defaults to the table's primary key.
+func refOutput(in *CommandInput, command, table, key, field string) *CommandOutput {
+	fixture := in.Fixture
+	key = fixture.resolveKey(table, key)
+
+	if field != "" {
+		// Keep the given field.
+	} else if tableOptions := fixture.getTableOptions(table); tableOptions != nil && tableOptions.PrimaryKeyName != "" {
+		field = tableOptions.PrimaryKeyName
+	} else {
+		field = fixture.Config.PrimaryKeyName
+	}
+
+	fixture.Logger.Debug().
+		Str("command", command).
+		Str("table", table).
+		Str("key", key).
+		Str("field", field).
+		Send()
+
+	return &CommandOutput{
+		Dependencies: []*CommandDependency{{
+			Label: [2]string{table, key},
+			Callback: func() (any, error) {
+				return fixture.getFieldPath(table, key, field)
+			},
+		}},
+	}
 }
 
-func (in *CommandInput) ScanLine() ([]string, map[string]string, error) {
-	if in.Line == "" {
-		return nil, nil, nil
+// refanyCommand references a record picked randomly from the given fixture
+// table, using the fixture's random source so it follows Fixture.Seed. E.g.:
+//
+//	=refany orders
+//	=refany orders number
+func refanyCommand(in *CommandInput) (*CommandOutput, error) {
+	fixture := in.Fixture
+
+	args, _, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
 	}
 
-	var args []string
-	var kwargs map[string]string
-	var equalsPrefix bool
-	var lastTxt string
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected at least 1 positional argument")
+	}
 
-	parse := func(txt string) {
-		switch {
-		case txt == "=":
-			equalsPrefix = true
-			return
-		case equalsPrefix:
-			equalsPrefix = false
-
-			if kwargs == nil {
-				kwargs = make(map[string]string)
-			}
+	table := args[0]
 
-			kwargs[lastTxt] = txt
-			lastTxt = ""
-			return
-		case lastTxt != "":
-			args = append(args, lastTxt)
-		}
+	var field string
 
-		lastTxt = txt
+	if len(args) >= 2 {
+		field = args[1]
 	}
 
-	s := new(scanner.Scanner).Init(strings.NewReader(in.Line))
+	keys := sortedKeys(fixture.Database[table])
 
-	for tok := s.Scan(); tok != scanner.EOF; tok = s.Scan() {
-		parse(s.TokenText())
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no records found in table %s", table)
 	}
 
-	parse("")
+	key := keys[fixture.random().Intn(len(keys))]
 
-	return args, kwargs, nil
+	return refOutput(in, "refany", table, key, field), nil
 }
 
-var commands = map[string]CommandFunc{
-	"base64dec": base64DecodeCommand,
-	"key":       keyCommand,
-	"ref":       refCommand,
-	"template":  templateCommand,
-	"ulid":      ulidCommand,
-	"uuidv4":    uuidv4Command,
-}
+// refpathCommand references a field reachable through the references of
+// other records, given as table.key.field[.field...]. Reference fields are
+// followed to the record they point to, and the remaining fields are used
+// as a path into the last record's nested values. E.g.:
+//
+//	=refpath gamma.1.beta_id.alpha_id
+//	=refpath orders.1.user_id.profile.theme
+//
+// is equivalent to =ref alpha <key>, where <key> is the alpha record
+// referenced by the beta record referenced by gamma 1.
+func refpathCommand(in *CommandInput) (*CommandOutput, error) {
+	fixture := in.Fixture
 
-func base64DecodeCommand(in *CommandInput) (*CommandOutput, error) {
 	args, _, err := in.ScanLine()
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan command line: %w", err)
@@ -98,49 +1541,188 @@ func base64DecodeCommand(in *CommandInput) (*CommandOutput, error) {
 		return nil, fmt.Errorf("expected at least 1 positional argument")
 	}
 
-	encoded, err := strconv.Unquote(args[0])
-	if err != nil {
-		return nil, fmt.Errorf("failed to unquote base64 string: %w", err)
+	parts := strings.Split(args[0], ".")
+
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("expected path with at least 3 parts, got %q", args[0])
 	}
 
-	b, err := base64.StdEncoding.DecodeString(encoded)
+	table, key, fields := parts[0], parts[1], parts[2:]
+
+	if key == "#" {
+		key = in.Key
+	}
+
+	for len(fields) > 1 {
+		refTable, refKey, err := fixture.referenceOf(table, key, fields[0])
+		if errors.Is(err, errNotReference) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to follow path %s: %w", args[0], err)
+		}
+
+		table, key, fields = refTable, refKey, fields[1:]
+	}
+
+	return refOutput(in, "refpath", table, key, strings.Join(fields, ".")), nil
+}
+
+// selfCommand returns another field of the same record, or a template
+// executed with the record as data. It is evaluated right before the record
+// is written, after the referenced fields have been resolved. E.g.:
+//
+//	=self name
+//	=self {{ .username }}@example.com
+func selfCommand(in *CommandInput) (*CommandOutput, error) {
+	fixture := in.Fixture
+	node := fixture.GetNode([2]string{in.Table, in.Key})
+
+	if left, _ := fixture.templateDelims(); strings.Contains(in.Line, left) {
+		t, err := fixture.parseTemplate(strings.TrimSpace(in.Line))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template: %w", err)
+		}
+
+		return &CommandOutput{
+			Resolve: func() (any, error) {
+				if err := node.resolve(templateFields(t.Tree.Root)...); err != nil {
+					return nil, err
+				}
+
+				buf := new(bytes.Buffer)
+
+				if err := t.Execute(buf, fixture.Database[in.Table][in.Key]); err != nil {
+					return nil, fmt.Errorf("failed to execute template: %w", err)
+				}
+
+				return buf.String(), nil
+			},
+		}, nil
+	}
+
+	args, _, err := in.ScanLine()
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode base64 string: %w", err)
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
 	}
 
-	out := &CommandOutput{
-		Value: b,
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 positional argument")
 	}
 
-	return out, nil
+	field := args[0]
+
+	if field == in.Field {
+		return nil, fmt.Errorf("field %s cannot reference itself", field)
+	}
+
+	return &CommandOutput{
+		Resolve: func() (any, error) {
+			if err := node.resolve(field); err != nil {
+				return nil, err
+			}
+
+			return fixture.getFieldPath(in.Table, in.Key, field)
+		},
+	}, nil
 }
 
-func keyCommand(in *CommandInput) (*CommandOutput, error) {
+// templateFields returns the top level fields (e.g. "name" for .name.first)
+// used by a parsed template.
+func templateFields(node parse.Node) []string {
+	var fields []string
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+
+		for _, child := range n.Nodes {
+			fields = append(fields, templateFields(child)...)
+		}
+	case *parse.ActionNode:
+		fields = templateFields(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+
+		for _, cmd := range n.Cmds {
+			fields = append(fields, templateFields(cmd)...)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			fields = append(fields, templateFields(arg)...)
+		}
+	case *parse.FieldNode:
+		fields = append(fields, n.Ident[0])
+	case *parse.ChainNode:
+		fields = templateFields(n.Node)
+	case *parse.IfNode:
+		fields = templateFields(&n.BranchNode)
+	case *parse.RangeNode:
+		fields = templateFields(&n.BranchNode)
+	case *parse.WithNode:
+		fields = templateFields(&n.BranchNode)
+	case *parse.BranchNode:
+		fields = append(fields, templateFields(n.Pipe)...)
+		fields = append(fields, templateFields(n.List)...)
+		fields = append(fields, templateFields(n.ElseList)...)
+	}
+
+	return fields
+}
+
+// seqCommand returns the next value of a named counter shared by every record
+// of the fixture. The name defaults to the table and field being set, and
+// start and step default to 1. E.g.:
+//
+//	=seq invoices 1000 10
+func seqCommand(in *CommandInput) (*CommandOutput, error) {
+	fixture := in.Fixture
+
 	args, _, err := in.ScanLine()
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan command line: %w", err)
 	}
 
-	var t string
+	name := in.Table + "." + in.Field
 
 	if len(args) > 0 {
-		t = args[0]
+		if _, err := strconv.ParseInt(args[0], 10, 64); err != nil {
+			name = args[0]
+			args = args[1:]
+		}
 	}
 
-	var v any
+	if len(args) > 2 {
+		return nil, fmt.Errorf("expected at most 3 positional arguments")
+	}
 
-	switch t {
-	case "":
-		v = in.Key
-	case "int":
-		v, err = strconv.Atoi(in.Key)
+	params := [2]int64{1, 1}
+
+	for i := range args {
+		v, err := strconv.ParseInt(args[i], 10, 64)
 		if err != nil {
-			return nil, fmt.Errorf("failed to convert key to int: %w", err)
+			return nil, fmt.Errorf("failed to parse integer %q: %w", args[i], err)
 		}
-	default:
-		return nil, fmt.Errorf("unsupported key type: %s", t)
+
+		params[i] = v
+	}
+
+	start, step := params[0], params[1]
+
+	v, ok := fixture.sequences[name]
+	if ok {
+		v += step
+	} else {
+		v = start
 	}
 
+	fixture.sequences[name] = v
+
 	out := &CommandOutput{
 		Value: v,
 	}
@@ -148,52 +1730,147 @@ func keyCommand(in *CommandInput) (*CommandOutput, error) {
 	return out, nil
 }
 
-func refCommand(in *CommandInput) (*CommandOutput, error) {
+var defaultSnowflakeEpoch = time.UnixMilli(1288834974657)
+
+// snowflake generates Twitter snowflake style ids: 41 bits of milliseconds
+// since the epoch, 10 bits of node id and a 12 bits sequence.
+type snowflake struct {
+	epoch    time.Time
+	node     int64
+	lastTime int64
+	sequence int64
+}
+
+func (s *snowflake) next() int64 {
+	t := time.Since(s.epoch).Milliseconds()
+
+	if t <= s.lastTime {
+		// Either the clock went backwards or we're still in the same
+		// millisecond; keep counting from the last time.
+		t = s.lastTime
+		s.sequence = (s.sequence + 1) & 0xfff
+
+		if s.sequence == 0 {
+			t++
+		}
+	} else {
+		s.sequence = 0
+	}
+
+	s.lastTime = t
+
+	return t<<22 | s.node<<12 | s.sequence
+}
+
+// snowflakeCommand returns a snowflake int64 id, using the node id and epoch
+// from the Config unless node is given. E.g.:
+//
+//	=snowflake node=7
+func snowflakeCommand(in *CommandInput) (*CommandOutput, error) {
 	fixture := in.Fixture
 
-	args, _, err := in.ScanLine()
+	_, kwargs, err := in.ScanLine()
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan command line: %w", err)
 	}
 
-	if len(args) < 2 {
-		return nil, fmt.Errorf("expected at least 2 positional arguments")
+	node := fixture.Config.SnowflakeNodeID
+
+	if v, ok := kwargs["node"]; ok {
+		node, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse node: %w", err)
+		}
 	}
 
-	table, key := args[0], args[1]
+	if node < 0 || node > 1023 {
+		return nil, fmt.Errorf("node must be between 0 and 1023")
+	}
 
-	var field string
+	if fixture.snowflakes == nil {
+		fixture.snowflakes = make(map[int64]*snowflake)
+	}
 
-	if len(args) >= 3 {
-		field = args[2]
-	} else if tableOptions, ok := fixture.Config.TableOptions[table]; ok && tableOptions.PrimaryKeyName != "" {
-		field = tableOptions.PrimaryKeyName
-	} else {
-		field = fixture.Config.PrimaryKeyName
+	generator, ok := fixture.snowflakes[node]
+	if !ok {
+		epoch := fixture.Config.SnowflakeEpoch
+
+		if epoch.IsZero() {
+			epoch = defaultSnowflakeEpoch
+		}
+
+		generator = &snowflake{
+			epoch:    epoch,
+			node:     node,
+			lastTime: -1,
+		}
+
+		fixture.snowflakes[node] = generator
 	}
 
-	if key == "#" {
-		key = in.Key
+	out := &CommandOutput{
+		Value: generator.next(),
 	}
 
-	fixture.Logger.Debug().
-		Str("command", "ref").
-		Str("table", table).
-		Str("key", key).
-		Str("field", field).
-		Send()
+	return out, nil
+}
+
+// sqlCommand runs a parameterized query when the record is written, and
+// uses its scalar result as the field value. The writer must implement
+// Querier. E.g.:
+//
+//	=sql "SELECT id FROM plans WHERE code = $1" pro
+func sqlCommand(in *CommandInput) (*CommandOutput, error) {
+	fixture := in.Fixture
+
+	args, _, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected at least 1 positional argument")
+	}
+
+	sql := args[0]
+
+	queryArgs := make([]any, len(args)-1)
+
+	for i := range queryArgs {
+		queryArgs[i] = args[i+1]
+	}
 
 	out := &CommandOutput{
-		Dependencies: []*CommandDependency{{
-			Label: [2]string{table, key},
-			Callback: func() (any, error) {
-				return fixture.GetField(table, key, field)
-			},
-		}},
+		Resolve: func() (any, error) {
+			querier, ok := fixture.Writer.(Querier)
+			if !ok {
+				return nil, fmt.Errorf("writer %T does not support queries", fixture.Writer)
+			}
+
+			rows, err := querier.Query(fixture, sql, queryArgs...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to run query: %w", err)
+			}
+
+			if len(rows) == 0 {
+				return nil, fmt.Errorf("query returned no rows")
+			}
+
+			if len(rows[0]) != 1 {
+				return nil, fmt.Errorf("expected query to return 1 column, got %d", len(rows[0]))
+			}
+
+			for _, v := range rows[0] {
+				return v, nil
+			}
+
+			return nil, nil
+		},
 	}
 
 	return out, nil
 }
+
 func templateCommand(in *CommandInput) (*CommandOutput, error) {
 	fixture := in.Fixture
 	templateBuf := fixture.templateBuf
@@ -205,12 +1882,12 @@ func templateCommand(in *CommandInput) (*CommandOutput, error) {
 		fixture.templateBuf = templateBuf
 	}
 
-	t, err := template.New("fixture").Funcs(funcMap).Parse(in.Line)
+	t, err := fixture.parseTemplate(in.Line)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
 
-	if err := t.Execute(fixture.templateBuf, fixture.TemplateData); err != nil {
+	if err := t.Execute(fixture.templateBuf, fixture.templateValues()); err != nil {
 		return nil, fmt.Errorf("failed to execute template: %w", err)
 	}
 
@@ -221,25 +1898,47 @@ func templateCommand(in *CommandInput) (*CommandOutput, error) {
 	return out, nil
 }
 
+// ulidCommand returns a new ULID, or parses one with fromString. The
+// timestamp can be pinned with fromTime (RFC3339), and monotonic=true makes
+// every ULID generated within the same Apply sort in creation order. E.g.:
+//
+//	=ulid fromTime="2023-01-01T00:00:00Z" monotonic=true toString=true
 func ulidCommand(in *CommandInput) (*CommandOutput, error) {
+	fixture := in.Fixture
+
 	_, kwargs, err := in.ScanLine()
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan command line: %w", err)
 	}
 
-	var fromString string
-
-	if v, ok := kwargs["fromString"]; ok {
-		fromString, err = strconv.Unquote(v)
-		if err != nil {
-			return nil, fmt.Errorf("failed to unquote fromString: %w", err)
-		}
-	}
+	fromString := kwargs["fromString"]
 
 	var ulidValue ulid.ULID
 
 	if fromString == "" {
-		ulidValue, err = ulid.New(ulid.Timestamp(time.Now().UTC()), ulid.DefaultEntropy())
+		ts := time.Now().UTC()
+
+		if v, ok := kwargs["fromTime"]; ok {
+			ts, err = time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse ulid fromTime %q: %w", v, err)
+			}
+		}
+
+		var entropy io.Reader
+
+		switch {
+		case kwargs["monotonic"] == "true":
+			if fixture.ulidEntropy == nil {
+				fixture.ulidEntropy = ulid.Monotonic(fixture.ulidRandom(), 0)
+			}
+
+			entropy = fixture.ulidEntropy
+		default:
+			entropy = fixture.ulidRandom()
+		}
+
+		ulidValue, err = ulid.New(ulid.Timestamp(ts), entropy)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate ULID: %w", err)
 		}
@@ -274,3 +1973,52 @@ func uuidv4Command(in *CommandInput) (*CommandOutput, error) {
 
 	return out, nil
 }
+
+// uuidv5Command returns a name-based (SHA-1) UUID, so the same namespace and
+// name always produce the same value. The namespace is either a UUID or one
+// of dns, url, oid and x500. E.g.:
+//
+//	=uuidv5 namespace=url name="users/1"
+func uuidv5Command(in *CommandInput) (*CommandOutput, error) {
+	_, kwargs, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	name, ok := kwargs["name"]
+	if !ok {
+		return nil, fmt.Errorf("missing name argument")
+	}
+
+	var namespace uuid.UUID
+
+	switch v := kwargs["namespace"]; v {
+	case "":
+		return nil, fmt.Errorf("missing namespace argument")
+	case "dns":
+		namespace = uuid.NameSpaceDNS
+	case "url":
+		namespace = uuid.NameSpaceURL
+	case "oid":
+		namespace = uuid.NameSpaceOID
+	case "x500":
+		namespace = uuid.NameSpaceX500
+	default:
+		namespace, err = uuid.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse namespace %q: %w", v, err)
+		}
+	}
+
+	id := uuid.NewSHA1(namespace, []byte(name))
+
+	out := &CommandOutput{
+		Value: id,
+	}
+
+	if v, ok := kwargs["toString"]; ok && v == "true" {
+		out.Value = id.String()
+	}
+
+	return out, nil
+}
@@ -1,9 +1,17 @@
 package fixture
 
 import (
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/oklog/ulid/v2"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestScanLine(t *testing.T) {
@@ -37,6 +45,30 @@ func TestScanLine(t *testing.T) {
 			args:   []string{"options", "a", "b", "and", "c"},
 			kwargs: map[string]string{"withD": "3", "andE": "something"},
 		},
+		{
+			name:   "signed",
+			line:   "-24h +30m truncate=hour",
+			args:   []string{"-24h", "+30m"},
+			kwargs: map[string]string{"truncate": "hour"},
+		},
+		{
+			name:   "quoted",
+			line:   `"a b" 'c "d"' ` + "`e\\f`" + ` "g\t\"h\"" x="1 = 2" y='{"z": [1]}' empty=""`,
+			args:   []string{"a b", `c "d"`, `e\f`, "g\t\"h\""},
+			kwargs: map[string]string{"x": "1 = 2", "y": `{"z": [1]}`, "empty": ""},
+		},
+		{
+			name:   "spaced kwargs",
+			line:   "a = 1 b= 2 c=",
+			args:   nil,
+			kwargs: map[string]string{"a": "1", "b": "2", "c": ""},
+		},
+		{
+			name:   "joined quotes",
+			line:   `pre"fix"'ed'`,
+			args:   []string{"prefixed"},
+			kwargs: nil,
+		},
 	}
 
 	commandInput := &CommandInput{}
@@ -56,4 +88,666 @@ func TestScanLine(t *testing.T) {
 			assert.Equal(st, testCase.kwargs, kwargs)
 		})
 	}
+
+	for _, line := range []string{`"unterminated`, `'unterminated`, `= 1`, `a==1`} {
+		commandInput.Line = line
+
+		_, _, err := commandInput.ScanLine()
+		assert.Error(t, err, line)
+	}
+}
+
+func TestScanLineValues(t *testing.T) {
+	in := &CommandInput{Line: `address locale=pt_BR fields='["street", "city"]' opts='{"n": 1}' empty=`}
+
+	args, kwargs, err := in.ScanLineValues()
+	if err != nil {
+		t.Fatalf("failed to ScanLineValues: %s", err)
+	}
+
+	assert.Equal(t, []string{"address"}, args)
+	assert.Equal(t, map[string]any{
+		"locale": "pt_BR",
+		"fields": []any{"street", "city"},
+		"opts":   map[string]any{"n": float64(1)},
+		"empty":  "",
+	}, kwargs)
+
+	in.Line = `fields='["street"'`
+
+	_, _, err = in.ScanLineValues()
+	assert.Error(t, err)
+}
+
+func TestNowCommand(t *testing.T) {
+	testCases := []struct {
+		name   string
+		line   string
+		offset time.Duration
+		round  time.Duration
+	}{
+		{
+			name: "now",
+			line: "",
+		},
+		{
+			name:   "offset",
+			line:   "-24h +30m",
+			offset: -23*time.Hour - 30*time.Minute,
+		},
+		{
+			name:   "truncate",
+			line:   "+2h truncate=hour",
+			offset: 2 * time.Hour,
+			round:  time.Hour,
+		},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(st *testing.T) {
+			out, err := nowCommand(&CommandInput{Line: testCase.line})
+			if err != nil {
+				st.Fatalf("failed to run command: %s", err)
+			}
+
+			v, ok := out.Value.(time.Time)
+			if !ok {
+				st.Fatalf("expected time.Time, got %T", out.Value)
+			}
+
+			expected := time.Now().UTC().Add(testCase.offset)
+
+			if testCase.round > 0 {
+				assert.Equal(st, v.Truncate(testCase.round), v)
+				assert.WithinDuration(st, expected, v, testCase.round)
+				return
+			}
+
+			assert.WithinDuration(st, expected, v, time.Second)
+		})
+	}
+
+	out, err := nowCommand(&CommandInput{Line: "truncate=day format=date"})
+	if err != nil {
+		t.Fatalf("failed to run command: %s", err)
+	}
+
+	assert.Equal(t, time.Now().UTC().Format(time.DateOnly), out.Value)
+}
+
+func TestSeqCommand(t *testing.T) {
+	in := &CommandInput{
+		Fixture: &Fixture{sequences: make(map[string]int64)},
+		Table:   "invoices",
+	}
+
+	testCases := []struct {
+		field    string
+		line     string
+		expected int64
+	}{
+		{field: "number", line: "", expected: 1},
+		{field: "number", line: "", expected: 2},
+		{field: "sort_order", line: "100", expected: 100},
+		{field: "sort_order", line: "100", expected: 101},
+		{field: "number", line: "named 10 5", expected: 10},
+		{field: "sort_order", line: "named 10 5", expected: 15},
+		{field: "number", line: "", expected: 3},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+		in.Field = testCase.field
+		in.Line = testCase.line
+
+		out, err := seqCommand(in)
+		if err != nil {
+			t.Fatalf("failed to run command %q: %s", testCase.line, err)
+		}
+
+		assert.Equal(t, testCase.expected, out.Value, "line %d", i)
+	}
+}
+
+func TestEnvCommand(t *testing.T) {
+	t.Setenv("FIXTURE_TEST_ENV", "from env")
+
+	testCases := []struct {
+		name     string
+		line     string
+		expected any
+		err      bool
+	}{
+		{name: "set", line: "FIXTURE_TEST_ENV", expected: "from env"},
+		{name: "set-default", line: `FIXTURE_TEST_ENV "unused"`, expected: "from env"},
+		{name: "default", line: `FIXTURE_TEST_UNSET "fallback value"`, expected: "fallback value"},
+		{name: "unquoted-default", line: "FIXTURE_TEST_UNSET fallback", expected: "fallback"},
+		{name: "unset", line: "FIXTURE_TEST_UNSET", err: true},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(st *testing.T) {
+			out, err := envCommand(&CommandInput{Line: testCase.line})
+			if testCase.err {
+				assert.Error(st, err)
+				return
+			}
+
+			if err != nil {
+				st.Fatalf("failed to run command: %s", err)
+			}
+
+			assert.Equal(st, testCase.expected, out.Value)
+		})
+	}
+}
+
+func TestBytesCommand(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cmd      CommandFunc
+		line     string
+		expected []byte
+	}{
+		{name: "hex", cmd: hexCommand, line: `"68656c6c6f"`, expected: []byte("hello")},
+		{name: "bytes-hex", cmd: bytesCommand, line: "68656c6c6f", expected: []byte("hello")},
+		{name: "bytes-base64", cmd: bytesCommand, line: `"aGVsbG8=" encoding=base64`, expected: []byte("hello")},
+		{name: "bytes-base64url", cmd: bytesCommand, line: `"_-8" encoding=base64url`, expected: []byte{0xff, 0xef}},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(st *testing.T) {
+			out, err := testCase.cmd(&CommandInput{Line: testCase.line})
+			if err != nil {
+				st.Fatalf("failed to run command: %s", err)
+			}
+
+			assert.Equal(st, testCase.expected, out.Value)
+		})
+	}
+}
+
+func TestBcryptCommand(t *testing.T) {
+	out, err := bcryptCommand(&CommandInput{Line: `"hunter2" cost=4`})
+	if err != nil {
+		t.Fatalf("failed to run command: %s", err)
+	}
+
+	hash, ok := out.Value.(string)
+	if !ok {
+		t.Fatalf("expected string, got %T", out.Value)
+	}
+
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(hash), []byte("hunter2")))
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, cost)
+}
+
+func TestDigestCommands(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cmd      CommandFunc
+		line     string
+		expected any
+	}{
+		{
+			name:     "sha256",
+			cmd:      sha256Command,
+			line:     `"hello"`,
+			expected: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		},
+		{
+			name:     "sha256-base64",
+			cmd:      sha256Command,
+			line:     `"hello" base64`,
+			expected: "LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=",
+		},
+		{
+			name:     "hmac",
+			cmd:      hmacCommand,
+			line:     `key="key" "The quick brown fox jumps over the lazy dog"`,
+			expected: "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		},
+		{
+			name:     "hmac-sha1",
+			cmd:      hmacCommand,
+			line:     `key="key" alg=sha1 "The quick brown fox jumps over the lazy dog"`,
+			expected: "de7c9b85b8b78aa6bc8a7a36f70a90701c9db4d9",
+		},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		t.Run(testCase.name, func(st *testing.T) {
+			out, err := testCase.cmd(&CommandInput{Line: testCase.line})
+			if err != nil {
+				st.Fatalf("failed to run command: %s", err)
+			}
+
+			assert.Equal(st, testCase.expected, out.Value)
+		})
+	}
+}
+
+func TestRandCommand(t *testing.T) {
+	run := func(seed int64, line string) []any {
+		in := &CommandInput{Fixture: &Fixture{Seed: seed}, Line: line}
+		values := make([]any, 20)
+
+		for i := range values {
+			out, err := randCommand(in)
+			if err != nil {
+				t.Fatalf("failed to run command %q: %s", line, err)
+			}
+
+			values[i] = out.Value
+		}
+
+		return values
+	}
+
+	for _, v := range run(0, "int -5 5") {
+		assert.GreaterOrEqual(t, v.(int64), int64(-5))
+		assert.LessOrEqual(t, v.(int64), int64(5))
+	}
+
+	for _, v := range run(0, "float 0 1") {
+		assert.GreaterOrEqual(t, v.(float64), 0.0)
+		assert.Less(t, v.(float64), 1.0)
+	}
+
+	assert.Equal(t, run(42, "int 1 1000"), run(42, "int 1 1000"))
+
+	// Spans overflowing int64.
+	for _, line := range []string{
+		"int 0 9223372036854775807",
+		"int -9223372036854775808 9223372036854775807",
+		"int -9223372036854775808 0",
+		"int -1 9223372036854775807",
+	} {
+		args := strings.Fields(line)
+		min, _ := strconv.ParseInt(args[1], 10, 64)
+		max, _ := strconv.ParseInt(args[2], 10, 64)
+
+		for _, v := range run(0, line) {
+			assert.GreaterOrEqual(t, v.(int64), min, line)
+			assert.LessOrEqual(t, v.(int64), max, line)
+		}
+	}
+
+	assert.Equal(t, []any{int64(9223372036854775807), int64(9223372036854775807)}, run(0, "int 9223372036854775807 9223372036854775807")[:2])
+
+	_, err := randCommand(&CommandInput{Fixture: &Fixture{}, Line: "int 10 1"})
+	assert.Error(t, err)
+}
+
+func TestChoiceCommand(t *testing.T) {
+	in := &CommandInput{Fixture: &Fixture{Seed: 1}}
+	counts := make(map[any]int)
+
+	for i := 0; i < 100; i++ {
+		in.Line = `pending active "on hold" weights=0,1,1`
+
+		out, err := choiceCommand(in)
+		if err != nil {
+			t.Fatalf("failed to run command: %s", err)
+		}
+
+		counts[out.Value]++
+	}
+
+	assert.Zero(t, counts["pending"])
+	assert.NotZero(t, counts["active"])
+	assert.NotZero(t, counts["on hold"])
+	assert.Equal(t, 100, counts["active"]+counts["on hold"])
+
+	in.Line = "a b weights=1"
+	_, err := choiceCommand(in)
+	assert.Error(t, err)
+}
+
+func TestNetworkCommands(t *testing.T) {
+	in := &CommandInput{Fixture: &Fixture{}}
+
+	testCases := []struct {
+		line   string
+		within string
+	}{
+		{line: "", within: "10.0.0.0/8"},
+		{line: "v6", within: "fd00::/8"},
+		{line: `within="192.168.4.0/22"`, within: "192.168.4.0/22"},
+		{line: `within="2001:db8::/61"`, within: "2001:db8::/61"},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+		in.Line = testCase.line
+
+		out, err := inetCommand(in)
+		if err != nil {
+			t.Fatalf("failed to run command %q: %s", testCase.line, err)
+		}
+
+		addr, ok := out.Value.(netip.Addr)
+		if !ok {
+			t.Fatalf("expected netip.Addr, got %T", out.Value)
+		}
+
+		assert.True(t, netip.MustParsePrefix(testCase.within).Contains(addr), "%s in %s", addr, testCase.within)
+	}
+
+	in.Line = `within="172.16.0.0/12" prefix=24 toString=true`
+
+	out, err := inetCommand(in)
+	if err != nil {
+		t.Fatalf("failed to run command: %s", err)
+	}
+
+	prefix, err := netip.ParsePrefix(out.Value.(string))
+	assert.NoError(t, err)
+	assert.Equal(t, 24, prefix.Bits())
+	assert.True(t, netip.MustParsePrefix("172.16.0.0/12").Contains(prefix.Addr()))
+
+	in.Line = ""
+
+	out, err = macCommand(in)
+	if err != nil {
+		t.Fatalf("failed to run command: %s", err)
+	}
+
+	mac := out.Value.(net.HardwareAddr)
+	assert.Len(t, mac, 6)
+	assert.Equal(t, byte(0x02), mac[0]&0x03)
+}
+
+func TestGeoCommand(t *testing.T) {
+	in := &CommandInput{Fixture: &Fixture{}}
+
+	testCases := []struct {
+		line     string
+		expected any
+	}{
+		{line: "point -23.55 -46.63", expected: "SRID=4326;POINT(-46.63 -23.55)"},
+		{line: "point 51.5 -0.12 format=wkt", expected: "POINT(-0.12 51.5)"},
+		{line: "point 1 2 format=latlon", expected: []float64{1, 2}},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+		in.Line = testCase.line
+
+		out, err := geoCommand(in)
+		if err != nil {
+			t.Fatalf("failed to run command %q: %s", testCase.line, err)
+		}
+
+		assert.Equal(t, testCase.expected, out.Value)
+	}
+
+	in.Line = "point bbox=-34,-74,5,-34 format=latlon"
+
+	out, err := geoCommand(in)
+	if err != nil {
+		t.Fatalf("failed to run command: %s", err)
+	}
+
+	point := out.Value.([]float64)
+	assert.True(t, point[0] >= -34 && point[0] <= 5, "lat %v", point[0])
+	assert.True(t, point[1] >= -74 && point[1] <= -34, "lon %v", point[1])
+
+	in.Line = "point 91 0"
+	_, err = geoCommand(in)
+	assert.Error(t, err)
+}
+
+func TestDecimalCommand(t *testing.T) {
+	testCases := []struct {
+		line     string
+		expected string
+	}{
+		{line: `"19.90" toString=true`, expected: "19.90"},
+		{line: "19.99 toString=true", expected: "19.99"},
+		{line: `"12345678901234567890.123456789" toString=true`, expected: "12345678901234567890.123456789"},
+		{line: `"1.005" scale=2 toString=true`, expected: "1.01"},
+		{line: "7 scale=2 toString=true", expected: "7.00"},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		out, err := decimalCommand(&CommandInput{Line: testCase.line})
+		if err != nil {
+			t.Fatalf("failed to run command %q: %s", testCase.line, err)
+		}
+
+		assert.Equal(t, testCase.expected, out.Value)
+	}
+
+	out, err := decimalCommand(&CommandInput{Line: `"0.1"`})
+	if err != nil {
+		t.Fatalf("failed to run command: %s", err)
+	}
+
+	assert.True(t, decimal.RequireFromString("0.1").Equal(out.Value.(decimal.Decimal)))
+}
+
+func TestUUIDv5Command(t *testing.T) {
+	testCases := []struct {
+		line     string
+		expected string
+	}{
+		{line: `namespace=dns name="python.org" toString=true`, expected: "886313e1-3b8a-5372-9b90-0c9aee199e5d"},
+		{line: `namespace="6ba7b810-9dad-11d1-80b4-00c04fd430c8" name="python.org" toString=true`, expected: "886313e1-3b8a-5372-9b90-0c9aee199e5d"},
+	}
+
+	for i := range testCases {
+		testCase := testCases[i]
+
+		out, err := uuidv5Command(&CommandInput{Line: testCase.line})
+		if err != nil {
+			t.Fatalf("failed to run command %q: %s", testCase.line, err)
+		}
+
+		assert.Equal(t, testCase.expected, out.Value)
+	}
+
+	a, err := uuidv5Command(&CommandInput{Line: `namespace=url name="users/1"`})
+	assert.NoError(t, err)
+
+	b, err := uuidv5Command(&CommandInput{Line: `namespace=url name="users/1"`})
+	assert.NoError(t, err)
+
+	assert.Equal(t, a.Value, b.Value)
+}
+
+func TestSnowflakeCommand(t *testing.T) {
+	epoch := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	in := &CommandInput{
+		Fixture: &Fixture{Config: &Config{SnowflakeNodeID: 3, SnowflakeEpoch: epoch}},
+	}
+
+	var last int64
+
+	for i := 0; i < 5000; i++ {
+		out, err := snowflakeCommand(in)
+		if err != nil {
+			t.Fatalf("failed to run command: %s", err)
+		}
+
+		v := out.Value.(int64)
+
+		if v <= last {
+			t.Fatalf("expected increasing ids, got %d after %d", v, last)
+		}
+
+		assert.Equal(t, int64(3), v>>12&0x3ff)
+
+		last = v
+	}
+
+	ms := last >> 22
+	assert.WithinDuration(t, time.Now(), epoch.Add(time.Duration(ms)*time.Millisecond), time.Second)
+
+	in.Line = "node=1024"
+	_, err := snowflakeCommand(in)
+	assert.Error(t, err)
+}
+
+func TestULIDCommand(t *testing.T) {
+	in := &CommandInput{
+		Fixture: &Fixture{},
+		Line:    `fromTime="2023-01-01T00:00:00Z" monotonic=true`,
+	}
+
+	var last ulid.ULID
+
+	for i := 0; i < 100; i++ {
+		out, err := ulidCommand(in)
+		if err != nil {
+			t.Fatalf("failed to run command: %s", err)
+		}
+
+		v := out.Value.(ulid.ULID)
+
+		assert.Equal(t, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), ulid.Time(v.Time()).UTC())
+		assert.Equal(t, 1, v.Compare(last))
+
+		last = v
+	}
+
+	run := func() any {
+		out, err := ulidCommand(&CommandInput{
+			Fixture: &Fixture{Seed: 7},
+			Line:    `fromTime="2023-01-01T00:00:00Z" toString=true`,
+		})
+		if err != nil {
+			t.Fatalf("failed to run command: %s", err)
+		}
+
+		return out.Value
+	}
+
+	assert.Equal(t, run(), run())
+}
+
+func TestRawCommand(t *testing.T) {
+	out, err := rawCommand(&CommandInput{Line: " nextval('invoice_seq') "})
+	if err != nil {
+		t.Fatalf("failed to run command: %s", err)
+	}
+
+	assert.Equal(t, Raw("nextval('invoice_seq')"), out.Value)
+
+	_, err = rawCommand(&CommandInput{})
+	assert.Error(t, err)
+}
+
+func TestRegisterCommand(t *testing.T) {
+	constant := func(v any) CommandFunc {
+		return func(in *CommandInput) (*CommandOutput, error) {
+			return &CommandOutput{Value: v}, nil
+		}
+	}
+
+	assert.ErrorIs(t, RegisterCommand("ref", constant(1)), ErrCommandExists)
+	assert.Error(t, RegisterCommand("bad name", constant(1)))
+	assert.Error(t, RegisterCommand("", constant(1)))
+	assert.NoError(t, RegisterCommand("test_register", constant("global")))
+	assert.ErrorIs(t, RegisterCommand("test_register", constant(1)), ErrCommandExists)
+
+	f := &Fixture{
+		Config: &Config{
+			Commands: map[string]CommandFunc{
+				"test_config": constant("config"),
+				"uuidv4":      constant("override"),
+			},
+		},
+		Commands: map[string]CommandFunc{
+			"test_config": constant("fixture"),
+		},
+		Writer: &memoryWriter{},
+		Database: Database{
+			"t": {
+				"1": {
+					"global": "=test_register",
+					"config": "=test_config",
+					"uuid":   "=uuidv4",
+				},
+			},
+		},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	record := f.Database["t"]["1"]
+	assert.Equal(t, "global", record["global"])
+	assert.Equal(t, "fixture", record["config"])
+	assert.Equal(t, "override", record["uuid"])
+}
+
+type testCommandProvider struct {
+	namespace string
+	value     any
+}
+
+func (p *testCommandProvider) Namespace() string {
+	return p.namespace
+}
+
+func (p *testCommandProvider) Commands() map[string]CommandFunc {
+	return map[string]CommandFunc{
+		"token": func(in *CommandInput) (*CommandOutput, error) {
+			return &CommandOutput{Value: p.value}, nil
+		},
+	}
+}
+
+func TestRegisterCommandProvider(t *testing.T) {
+	assert.NoError(t, RegisterCommandProvider(&testCommandProvider{"test_myco", "global"}))
+	assert.ErrorIs(t, RegisterCommandProvider(&testCommandProvider{"test_myco", 1}), ErrCommandExists)
+	assert.Error(t, RegisterCommandProvider(&testCommandProvider{"a.b", 1}))
+	assert.Error(t, RegisterCommand("test_myco.token", nil))
+
+	f := &Fixture{
+		Config: &Config{
+			CommandProviders: []CommandProvider{&testCommandProvider{"test_local", "config"}},
+		},
+		Writer: &memoryWriter{},
+		Database: Database{
+			"t": {
+				"1": {
+					"global": "=test_myco.token",
+					"config": "=test_local.token",
+				},
+			},
+		},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, "global", f.Database["t"]["1"]["global"])
+	assert.Equal(t, "config", f.Database["t"]["1"]["config"])
+
+	f = &Fixture{
+		Config: &Config{},
+		Writer: &memoryWriter{},
+		Database: Database{
+			"t": {"1": {"v": "=test_myco.missing"}},
+		},
+	}
+
+	assert.ErrorContains(t, f.Apply(), "unknown command")
 }
@@ -0,0 +1,137 @@
+package fixture
+
+import "errors"
+
+// Compiled is a fixture whose files were loaded by Compile. It is immutable,
+// and can be applied any number of times (e.g. per test or per transaction)
+// with the fixtures returned by its Fixture method.
+type Compiled struct {
+	settings *Fixture
+
+	database      Database
+	tableOptions  map[string]*TableOptions
+	recordLayers  map[[2]string][]int
+	recordSources map[[2]string]*recordSource
+	loaded        map[string]bool
+	templateData  map[string]any
+}
+
+// Compile loads the fixture files once: reading them, executing their
+// templates and directives such as _include and _options. Only loading is
+// skipped by the Applies of the returned Compiled fixture: they still run
+// the commands and build the records graph, so generated values and the ids
+// returned by writers are not shared between fixtures. E.g.:
+//
+//	compiled, err := (&fixture.Fixture{Config: config, File: "users.yaml"}).Compile()
+//
+//	// In each test:
+//	f := compiled.Fixture(writer)
+//	f.MustApply(t)
+//
+// The fixture itself is left unchanged.
+func (f *Fixture) Compile() (*Compiled, error) {
+	f.applyMu.Lock()
+	defer f.applyMu.Unlock()
+
+	// Loaded into a copy of the Database, see below.
+	f.publish()
+
+	if f.compiled != nil {
+		return nil, errors.New("fixture is already compiled")
+	}
+
+	if err := f.init(); err != nil {
+		return nil, err
+	}
+
+	database := f.Database
+
+	defer func() {
+		f.Database = database
+		f.resetState()
+	}()
+
+	f.resetState()
+	f.Database = copyDatabase(database)
+
+	if f.Database == nil {
+		f.Database = make(Database)
+	}
+
+	if err := f.loadTemplateData(); err != nil {
+		return nil, err
+	}
+
+	if err := f.handleFiles(); err != nil {
+		return nil, err
+	}
+
+	return &Compiled{
+		settings:      f.settings(),
+		database:      f.Database,
+		tableOptions:  f.tableOptions,
+		recordLayers:  f.recordLayers,
+		recordSources: f.recordSources,
+		loaded:        f.loaded,
+		templateData:  f.templateData,
+	}, nil
+}
+
+// Fixture returns a new fixture writing the compiled records with writer.
+// Its settings are the ones of the compiled fixture, and can be changed
+// before Apply, except for the files and template data which are compiled.
+func (c *Compiled) Fixture(writer Writer) *Fixture {
+	f := c.settings.settings()
+	f.Writer = writer
+	f.compiled = c
+
+	return f
+}
+
+// loadCompiled loads the compiled files into f.Database, as handleFiles.
+func (f *Fixture) loadCompiled(c *Compiled) {
+	f.Database = copyDatabase(c.database)
+	f.templateData = c.templateData
+
+	for k, v := range c.tableOptions {
+		f.tableOptions[k] = v
+	}
+
+	for k, v := range c.recordLayers {
+		f.recordLayers[k] = v
+	}
+
+	for k, v := range c.recordSources {
+		f.recordSources[k] = v
+	}
+
+	for k, v := range c.loaded {
+		f.loaded[k] = v
+	}
+}
+
+// settings returns a new fixture with the settings of f that don't select
+// the files to load.
+func (f *Fixture) settings() *Fixture {
+	return &Fixture{
+		Context:                 f.Context,
+		Logger:                  f.Logger,
+		Config:                  f.Config,
+		Writers:                 f.Writers,
+		FS:                      f.FS,
+		Dir:                     f.Dir,
+		Expectations:            f.Expectations,
+		Suite:                   f.Suite,
+		TemplateData:            f.TemplateData,
+		FuncMap:                 f.FuncMap,
+		PrintJSON:               f.PrintJSON,
+		DoNotCreateDependencies: f.DoNotCreateDependencies,
+		Output:                  f.Output,
+		OutputFormat:            f.OutputFormat,
+		Commands:                f.Commands,
+		Include:                 f.Include,
+		Exclude:                 f.Exclude,
+		ExcludeTags:             f.ExcludeTags,
+		Seed:                    f.Seed,
+	}
+}
@@ -0,0 +1,61 @@
+package fixture
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixtureCompile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"users.yaml": {Data: []byte(`
+_include: plans.yaml
+users:
+  alice:
+    name: "{{ .name }}"
+    plan: =ref plans free
+    settings: {theme: dark}
+`)},
+		"plans.yaml": {Data: []byte("plans:\n  free:\n    name: Free\n")},
+	}
+
+	source := &Fixture{
+		Config:       &Config{},
+		FS:           fsys,
+		File:         "users.yaml",
+		TemplateData: map[string]any{"name": "Alice"},
+	}
+
+	compiled, err := source.Compile()
+	if err != nil {
+		t.Fatalf("failed to Compile: %s", err)
+	}
+
+	assert.Nil(t, source.Database)
+
+	// Compiled fixtures don't read the files again.
+	delete(fsys, "users.yaml")
+	delete(fsys, "plans.yaml")
+
+	for i := 0; i < 2; i++ {
+		writer := &memoryWriter{ids: map[string]int64{"plans": int64(i * 10)}}
+		f := compiled.Fixture(writer)
+
+		if err := f.Apply(); err != nil {
+			t.Fatalf("failed to Apply: %s", err)
+		}
+
+		assert.Equal(t, []string{"plans.free", "users.alice"}, writer.inserted)
+		assert.Equal(t, "Alice", f.MustGetField(t, "users", "alice", "name"))
+		assert.Equal(t, int64(i*10+1), f.MustGetField(t, "users", "alice", "plan"))
+
+		// Records are not shared between fixtures.
+		settings := f.MustGetField(t, "users", "alice", "settings").(map[string]any)
+		assert.Equal(t, "dark", settings["theme"])
+		settings["theme"] = "light"
+	}
+
+	_, err = compiled.Fixture(&memoryWriter{}).Compile()
+	assert.EqualError(t, err, "fixture is already compiled")
+}
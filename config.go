@@ -4,7 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"sync"
+	"text/template"
+	"time"
 )
 
 const (
@@ -12,13 +18,147 @@ const (
 	WriteSync  = 2
 )
 
+const (
+	// Subdirectories are ignored.
+	SubdirectorySkip = 1
+
+	// Subdirectories namespace their tables, e.g. "audit/events.yaml"
+	// holds the records of the "audit.events" table.
+	SubdirectorySchema = 2
+
+	// Subdirectories are ordered groups of table files: the records of each
+	// group are written after the ones of the previous groups, by name.
+	SubdirectoryGroup = 3
+)
+
+// DefaultValueFunc computes a value of TableOptions.DefaultValues from the
+// record it is set in. It is called once the other fields of the record are
+// parsed, references excepted as they are resolved when writing, and can
+// return commands, e.g. "=ref users admin".
+type DefaultValueFunc func(ctx context.Context, table, key string, record Record) (any, error)
+
 type TableOptions struct {
 	TableName      string
 	PrimaryKeyName string
 	References     map[string]string
 	WriteMode      int
 	DefaultValues  Record
-	BeforeWrite    func(ctx context.Context, record Record) error
+
+	// Extends is the name of other TableOptions these ones inherit, e.g.
+	// "users" for the "users#admin" profile, overriding the options they set.
+	// Default and dependency values, field aliases and references are
+	// merged. The TableName of profiles defaults to the table of Extends.
+	// Booleans such as SkipExisting can only be turned on, not off, by the
+	// extending options. The TableOptions themselves are left untouched.
+	Extends string
+
+	// DefaultValuesFile is a TOML or YAML file of default values, in the
+	// format of GetDefaultValues, whose entry named after these options
+	// (e.g. "users" or "users#admin") is merged under DefaultValues when the
+	// config is first used. It can be shared by multiple table options.
+	DefaultValuesFile string
+
+	// BeforeWrite is called before a record is written, and can update it
+	// in place. Returning ErrSkipRecord skips the record: it is not written,
+	// but the records depending on it still get its values.
+	BeforeWrite func(ctx context.Context, record Record) error
+
+	// PrepareWrite is called after BeforeWrite, and returns the record to
+	// write instead, if not nil. Like BeforeWrite, it can return
+	// ErrSkipRecord to skip the record, e.g. depending on application state.
+	PrepareWrite func(ctx context.Context, record Record) (Record, error)
+
+	// AfterWrite is called after the writer inserts a record, with the
+	// values populated by the database (e.g. generated IDs or columns), and
+	// before the records depending on it are resolved. E.g. for cache
+	// warming, search indexing or assertions on generated columns.
+	AfterWrite func(ctx context.Context, record Record) error
+
+	// Writer is the name of the Fixture.Writers entry writing the table's
+	// records. Records of other writers can reference them as usual, e.g.
+	// cache entries (written by a RedisWriter) holding the ID of a row.
+	// Fixture.Writer can then be nil if every table sets its writer.
+	// Default: Fixture.Writer
+	Writer string
+
+	// DependencyValues are the values of the records created for references
+	// to undefined records of the table, e.g. the required fields that
+	// DefaultValues don't set for every record. They take precedence over
+	// DefaultValues, and can be commands, e.g. "=key" for the referenced
+	// key.
+	DependencyValues Record
+
+	// DoNotCreateDependencies makes references to undefined records of the
+	// table fail, instead of creating empty records, e.g. for tables whose
+	// NOT NULL constraints such records would violate. See
+	// Fixture.DoNotCreateDependencies for every table.
+	DoNotCreateDependencies bool
+
+	// Base64Fields are the fields holding base64 (standard encoding)
+	// strings, decoded to []byte before the records are written, e.g. for
+	// bytea columns. Set by the base64 option of _options too.
+	Base64Fields []string
+
+	// OmitFields are the fields removed from the records before they are
+	// written, e.g. GENERATED ALWAYS columns present in copied data or
+	// snapshots, which can't be inserted. Set by the omit option of
+	// _options too.
+	OmitFields []string
+
+	// FieldAliases maps the names of fields in fixture files to the names
+	// of their columns, e.g. {"email": "email_address"}, so fixtures keep
+	// their names across schema refactors. Records are written with the
+	// column names, and keep the fixture ones otherwise, e.g. in references
+	// and callbacks. Set by the field_aliases option of _options too.
+	FieldAliases map[string]string
+
+	// SkipExisting loads the records of the table already in the database
+	// instead of inserting them, see Config.SkipExisting. Set by the
+	// skip_existing option of _options too.
+	SkipExisting bool
+
+	// LookupFields, if set, are the fields identifying the existing records
+	// of the table, e.g. ["email"], instead of the primary key, and enable
+	// SkipExisting for the table: records are found by these fields, or
+	// created if missing, and the records referencing them get the values
+	// of the found ones, such as their IDs. Set by the lookup_fields option
+	// of _options too.
+	LookupFields []string
+
+	// CompositeReferences declares references spanning multiple fields, by
+	// the name of the (virtual) field holding the referenced key. E.g.:
+	//
+	// 	CompositeReferences: map[string]*fixture.CompositeReference{
+	// 		"user": {
+	// 			Table:  "users",
+	// 			Fields: map[string]string{"tenant_id": "tenant_id", "user_id": "id"},
+	// 		},
+	// 	}
+	//
+	// A record with `user: =ref users 1` (or just `user: 1`) then depends on
+	// users.1 and gets both tenant_id and user_id, while "user" is omitted.
+	CompositeReferences map[string]*CompositeReference
+}
+
+// CompositeReference is a reference to Table populating multiple fields.
+type CompositeReference struct {
+	// The referenced table.
+	Table string
+
+	// Fields maps each field of the referencing record to the field of the
+	// referenced record it is populated from.
+	Fields map[string]string
+}
+
+// Suite is a named scenario of fixture files, see Config.Suites.
+type Suite struct {
+	// Files are fixture files or directories, relative to Fixture.Dir,
+	// loaded in order before the fixture's File/Body.
+	Files []string
+
+	// TemplateData is merged over the fixture's TemplateDataProviders, and
+	// under its TemplateData.
+	TemplateData map[string]any
 }
 
 type Config struct {
@@ -35,6 +175,47 @@ type Config struct {
 	// Default: WriteAsync
 	WriteMode int
 
+	// How subdirectories are loaded in directory mode.
+	// Default: SubdirectorySkip
+	SubdirectoryMode int
+
+	// OrderPrefixes makes the files of a directory named with a numeric
+	// prefix, e.g. "01_users.yaml", hold the records of the table without
+	// the prefix ("users"), written in the order of their prefixes.
+	// Otherwise the prefix is part of the table name, e.g. "2024_events".
+	OrderPrefixes bool
+
+	// The node (machine) id used by the =snowflake command, from 0 to 1023.
+	// Can be overwritten per command with node=<id>.
+	SnowflakeNodeID int64
+
+	// The epoch used by the =snowflake command.
+	// Default: 2010-11-04T01:42:54.657Z (Twitter's epoch)
+	SnowflakeEpoch time.Time
+
+	// Commands adds or overrides commands for fixtures using this config.
+	// Fixture.Commands take precedence. See RegisterCommand.
+	Commands map[string]CommandFunc
+
+	// CommandProviders adds namespaced commands for fixtures using this config,
+	// taking precedence over the ones registered with RegisterCommandProvider.
+	CommandProviders []CommandProvider
+
+	// FuncMap adds or overrides template functions for fixtures using this
+	// config. Fixture.FuncMap takes precedence. Changes take effect on the
+	// next Apply of the fixtures. See AddFuncMap.
+	FuncMap template.FuncMap
+
+	// TemplateDelims sets the template action delimiters, so fixtures
+	// containing a literal "{{" don't need escaping. E.g. [2]string{"[[", "]]"}.
+	// Default: "{{" and "}}"
+	TemplateDelims [2]string
+
+	// TemplateMissingKey sets the template behavior for missing map keys:
+	// "default" (or "invalid"), "zero" or "error". See text/template's Option.
+	// Default: "default"
+	TemplateMissingKey string
+
 	// TableOptions can be used to set table specific options or
 	// create multiple profiles for the same table. E.g.:
 	//
@@ -51,15 +232,136 @@ type Config struct {
 	// 			},
 	// 		},
 	// 	}
+	//
+	// Records of the "foo" table can also get the DefaultValues of a
+	// profile with their _profile field, e.g. `_profile: profile2`.
 	TableOptions map[string]*TableOptions
 
+	// Suites are named scenarios, applied with Fixture.Suite. E.g.:
+	//
+	// 	Suites: map[string]*fixture.Suite{
+	// 		"checkout-happy-path": {
+	// 			Files:        []string{"users.yaml", "carts/"},
+	// 			TemplateData: map[string]any{"paid": true},
+	// 		},
+	// 	}
+	Suites map[string]*Suite
+
+	// Retry, if set, retries the writer operations failing with transient
+	// errors, such as serialization failures or connection errors in CI.
+	// Retries within a transaction (PostgresWriter.Tx) usually fail again,
+	// as Postgres aborts the transaction on error. E.g.:
+	//
+	// 	Retry: &fixture.Retry{Attempts: 5, Backoff: 50 * time.Millisecond},
+	Retry *Retry
+
+	// WriteTimeout, if set, limits the duration of each writer operation
+	// (each attempt, with Retry), through Fixture.WriteContext.
+	WriteTimeout time.Duration
+
+	// WriteLimit, if set, limits the rate and concurrency of the writer
+	// operations of the fixtures using this config. E.g.:
+	//
+	// 	WriteLimit: &fixture.WriteLimit{PerSecond: 50, Concurrent: 4},
+	WriteLimit *WriteLimit
+
+	// Strict makes Apply fail when a reference points at a record the
+	// fixture does not define, instead of creating an empty record, when
+	// References or TableOptions reference a table the fixture does not
+	// define, or when TableOptions are used by none of its tables.
+	Strict bool
+
+	// ValidateSchema makes Apply check that the tables and columns of the
+	// records exist in the database, and that the values of enum columns
+	// are labels of their enum, before writing any record, reporting all
+	// the mismatches at once. Only the writers implementing SchemaReader,
+	// such as PostgresWriter, are checked.
+	ValidateSchema bool
+
+	// CoerceValues makes Apply convert the values of the records to the
+	// types of their columns before writing them, e.g. strings to time.Time
+	// for timestamp columns or numbers to decimal.Decimal for numeric ones,
+	// and fail with the column and value when they can't be converted. Only
+	// the writers implementing SchemaReader, such as PostgresWriter, are
+	// supported.
+	CoerceValues bool
+
+	// DecimalNumbers decodes the YAML numbers with a fraction or exponent,
+	// and the integers overflowing int64, as decimal.Decimal instead of
+	// float64, preserving their literal, e.g. for NUMERIC(38, 18) columns.
+	// Records can also hold decimal.Decimal, *big.Int and *big.Rat values,
+	// which PostgresWriter writes without float rounding.
+	DecimalNumbers bool
+
+	// Converters convert the values of application types, or of specific
+	// fields, before records are written, before the ones added with
+	// RegisterConverter. E.g.:
+	//
+	// 	Converters: []*fixture.Converter{
+	// 		fixture.ConvertType(func(s Status) (any, error) { return s.String(), nil }),
+	// 		fixture.ConvertField("events.payload", marshalProto),
+	// 	}
+	Converters []*Converter
+
+	// SkipExisting makes Apply look up each record by its primary key (or
+	// TableOptions.LookupFields) before inserting it, and load the existing
+	// record of the database into it instead, e.g. to apply fixtures
+	// repeatedly to a persistent development database. Records without
+	// these fields, such as the ones whose IDs the database generates, are
+	// inserted. The writers must implement Finder, as PostgresWriter does.
+	// See TableOptions.SkipExisting for some tables only.
+	SkipExisting bool
+
+	// DiscoverReferences reads the foreign keys of the database once, so
+	// plain keys resolve as references without declaring them. The
+	// TableOptions references set by hand take precedence, and are left
+	// untouched. Applies fail until a fixture whose Writer (or one of its
+	// Writers) implements ReferenceReader, as PostgresWriter does, reads
+	// them.
+	DiscoverReferences bool
+
+	// ColumnName, if set, returns the column names of the fields that
+	// TableOptions.FieldAliases don't rename, e.g. SnakeCase for fixtures
+	// generated from JSON payloads with camelCase fields. Like FieldAliases,
+	// records are written with the column names and keep the fixture ones
+	// otherwise.
+	ColumnName func(field string) string
+
+	// DefaultValuesDir is a directory of TOML or YAML files of default
+	// values, in the format of GetDefaultValues, loaded by name when the
+	// config is first used, from the Fixture.FS of the fixture using it if
+	// set. Their entries are merged under the DefaultValues of the
+	// TableOptions of the same name, and under the ones of
+	// TableOptions.DefaultValuesFile, including for tables without
+	// TableOptions. The TableOptions themselves are left untouched. E.g.
+	// defaults/users.yaml:
+	//
+	// 	users:
+	// 	  role: member
+	// 	users#admin:
+	// 	  role: admin
+	//
+	// The entries naming a profile should set its TableName in TableOptions.
+	DefaultValuesDir string
+
 	tableAliases map[string]string
 
+	// resolvedOptions are the TableOptions with the ones they extend merged,
+	// and the default values loaded from DefaultValuesFile and
+	// DefaultValuesDir, by name, see tableOptions.
+	resolvedOptions map[string]*TableOptions
+
+	// discoveredReferences are the references read with
+	// DiscoverReferences, by table and field, see getReference.
+	discoveredReferences map[string]map[string]string
+	referencesMu         sync.Mutex
+	referencesRead       bool
+
 	initOnce sync.Once
 	initErr  error
 }
 
-func (c *Config) init() error {
+func (c *Config) init(src *fileSource) error {
 	if c == nil {
 		return errors.New("nil config")
 	}
@@ -71,14 +373,26 @@ func (c *Config) init() error {
 
 		if c.TableOptions == nil {
 			c.TableOptions = make(map[string]*TableOptions)
+		}
+
+		defaults, err := c.loadDefaultValues(src)
+		if err != nil {
+			c.initErr = err
 			return
 		}
 
-		c.tableAliases = make(map[string]string)
+		resolved, err := c.resolveExtends()
+		if err != nil {
+			c.initErr = err
+			return
+		}
 
-		for table := range c.TableOptions {
-			options := c.TableOptions[table]
+		c.resolvedOptions = resolved
+		c.setDefaultOptions(defaults)
 
+		c.tableAliases = make(map[string]string)
+
+		for table, options := range c.resolvedOptions {
 			if options.TableName != "" {
 				c.tableAliases[table] = options.TableName
 			}
@@ -88,14 +402,306 @@ func (c *Config) init() error {
 	return c.initErr
 }
 
+// loadDefaultValues returns the default values of the DefaultValuesFile of
+// the TableOptions, then of the DefaultValuesDir, read from src, by name.
+func (c *Config) loadDefaultValues(src *fileSource) (map[string]Record, error) {
+	defaults := make(map[string]Record)
+	files := make(map[string]Table)
+
+	for _, name := range sortedKeys(c.TableOptions) {
+		options := c.TableOptions[name]
+		if options == nil || options.DefaultValuesFile == "" {
+			continue
+		}
+
+		table, ok := files[options.DefaultValuesFile]
+		if !ok {
+			var err error
+
+			table, err = readDefaultValues(src.fsys, options.DefaultValuesFile)
+			if err != nil {
+				return nil, fmt.Errorf("table options %s, default values file %s: %w", name, options.DefaultValuesFile, err)
+			}
+
+			files[options.DefaultValuesFile] = table
+		}
+
+		defaults[name] = mergeDefaultValues(defaults[name], table[name])
+	}
+
+	if c.DefaultValuesDir == "" {
+		return defaults, nil
+	}
+
+	entries, err := fs.ReadDir(src.fsys, c.DefaultValuesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read default values dir: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		if _, err := bodyFormat(filepath.Ext(entry.Name())); err != nil {
+			continue
+		}
+
+		file := src.join(c.DefaultValuesDir, entry.Name())
+
+		table, err := readDefaultValues(src.fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("default values file %s: %w", file, err)
+		}
+
+		for _, name := range sortedKeys(table) {
+			defaults[name] = mergeDefaultValues(defaults[name], table[name])
+		}
+	}
+
+	return defaults, nil
+}
+
+// setDefaultOptions sets the default values of the resolved options with
+// default values loaded from files, or extending ones that have. Their own
+// default values take precedence over the loaded ones, which take precedence
+// over the extended ones.
+func (c *Config) setDefaultOptions(defaults map[string]Record) {
+	if len(defaults) == 0 {
+		return
+	}
+
+	var resolve func(name string) (Record, bool)
+
+	resolve = func(name string) (Record, bool) {
+		values, loaded := defaults[name]
+
+		if options := c.TableOptions[name]; options != nil {
+			values = mergeDefaultValues(options.DefaultValues, values)
+		}
+
+		// Extends are resolved, so they can't be circular.
+		if options := c.TableOptions[name]; options != nil && options.Extends != "" {
+			extended, ok := resolve(options.Extends)
+			values = mergeDefaultValues(values, extended)
+			loaded = loaded || ok
+		}
+
+		return values, loaded
+	}
+
+	names := sortedKeys(c.TableOptions)
+
+	for name := range defaults {
+		if _, ok := c.TableOptions[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		values, loaded := resolve(name)
+		if !loaded {
+			continue
+		}
+
+		options := new(TableOptions)
+
+		if c.resolvedOptions[name] != nil {
+			*options = *c.resolvedOptions[name]
+		}
+
+		options.DefaultValues = values
+		c.resolvedOptions[name] = options
+	}
+}
+
+// tableOptions returns the TableOptions of a table, with the ones they extend
+// merged and the default values loaded from files, see Config.DefaultValuesDir.
+func (c *Config) tableOptions(table string) *TableOptions {
+	if options, ok := c.resolvedOptions[table]; ok {
+		return options
+	}
+
+	return c.TableOptions[table]
+}
+
+// resolveExtends returns the TableOptions with the ones they extend, see
+// TableOptions.Extends, merged under them, by name.
+func (c *Config) resolveExtends() (map[string]*TableOptions, error) {
+	resolved := make(map[string]*TableOptions, len(c.TableOptions))
+	resolving := make(map[string]bool)
+
+	var resolve func(name string) error
+
+	resolve = func(name string) error {
+		options := c.TableOptions[name]
+		if resolved[name] != nil || options == nil {
+			return nil
+		}
+
+		if options.Extends == "" {
+			resolved[name] = options
+			return nil
+		}
+
+		if resolving[name] {
+			return fmt.Errorf("table options %s extend themselves", name)
+		}
+
+		resolving[name] = true
+
+		base, ok := c.TableOptions[options.Extends]
+		if !ok || base == nil {
+			return fmt.Errorf("table options %s extend undefined table options %s", name, options.Extends)
+		}
+
+		if err := resolve(options.Extends); err != nil {
+			return err
+		}
+
+		base = resolved[options.Extends]
+
+		merged := mergeTableOptions(base, options)
+		merged.Extends = options.Extends
+
+		if strings.Contains(name, "#") && options.TableName == "" {
+			merged.TableName = base.TableName
+			if merged.TableName == "" {
+				merged.TableName, _, _ = strings.Cut(options.Extends, "#")
+			}
+		}
+
+		resolved[name] = merged
+
+		return nil
+	}
+
+	for _, name := range sortedKeys(c.TableOptions) {
+		if err := resolve(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return resolved, nil
+}
+
+// mergeDefaultValues returns values with the fields of defaults it doesn't
+// set, which are deep merged for nested maps.
+func mergeDefaultValues(values, defaults Record) Record {
+	if len(defaults) == 0 {
+		return values
+	}
+
+	merged := deepCopy(defaults).(Record)
+	deepMerge(merged, values)
+
+	return merged
+}
+
 func (c *Config) TableAlias(table string) string {
 	return c.tableAliases[table]
 }
 
+// Validate checks the config for misconfigurations that would otherwise
+// surface during Apply, reporting all of them at once:
+//   - profiles (TableOptions keys containing "#") without a TableName nor
+//     Extends, and TableNames naming another profile, as aliases are not
+//     resolved recursively;
+//   - TableOptions extending undefined ones;
+//   - references to profiles not defined in TableOptions;
+//   - write modes other than WriteAsync and WriteSync;
+//   - default values that are functions other than func(string) (any, error)
+//     and DefaultValueFunc, which would be written as is.
+func (c *Config) Validate() error {
+	var errs []error
+
+	checkReference := func(ref, source string) {
+		table, _ := c.splitReference(ref, nil)
+
+		if strings.Contains(table, "#") && c.TableOptions[table] == nil {
+			errs = append(errs, fmt.Errorf("%s references undefined profile %s", source, table))
+		}
+	}
+
+	checkWriteMode := func(writeMode int, source string) {
+		if writeMode != 0 && writeMode != WriteAsync && writeMode != WriteSync {
+			errs = append(errs, fmt.Errorf("%s has invalid write mode %d", source, writeMode))
+		}
+	}
+
+	checkWriteMode(c.WriteMode, "config")
+
+	for _, field := range sortedKeys(c.References) {
+		checkReference(c.References[field], fmt.Sprintf("reference %s", field))
+	}
+
+	for _, name := range sortedKeys(c.TableOptions) {
+		options := c.TableOptions[name]
+		if options == nil {
+			continue
+		}
+
+		source := fmt.Sprintf("table options %s", name)
+
+		switch {
+		case strings.Contains(name, "#") && options.TableName == "" && options.Extends == "":
+			errs = append(errs, fmt.Errorf("%s is a profile without TableName", source))
+		case options.TableName != "" && c.TableOptions[options.TableName] != nil && c.TableOptions[options.TableName].TableName != "":
+			errs = append(errs, fmt.Errorf("%s: TableName %s is itself an alias", source, options.TableName))
+		case strings.Contains(options.TableName, "#"):
+			errs = append(errs, fmt.Errorf("%s: TableName %s is a profile", source, options.TableName))
+		}
+
+		checkWriteMode(options.WriteMode, source)
+
+		if options.Extends != "" && c.TableOptions[options.Extends] == nil {
+			errs = append(errs, fmt.Errorf("%s extends undefined table options %s", source, options.Extends))
+		}
+
+		for _, field := range sortedKeys(options.References) {
+			checkReference(options.References[field], fmt.Sprintf("%s, reference %s", source, field))
+		}
+
+		for _, field := range sortedKeys(options.CompositeReferences) {
+			if ref := options.CompositeReferences[field]; ref != nil {
+				checkReference(ref.Table, fmt.Sprintf("%s, composite reference %s", source, field))
+			}
+		}
+
+		for _, field := range sortedKeys(options.DefaultValues) {
+			v := options.DefaultValues[field]
+
+			if _, ok := v.(func(string) (any, error)); ok {
+				continue
+			}
+
+			if _, ok := asDefaultValueFunc(v); ok {
+				continue
+			}
+
+			if v != nil && reflect.TypeOf(v).Kind() == reflect.Func {
+				errs = append(errs, fmt.Errorf("%s, default value %s: %T is not a func(string) (any, error) nor a DefaultValueFunc", source, field, v))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// GetSuite returns the suite with the given name.
+func (c *Config) GetSuite(name string) (*Suite, error) {
+	suite, ok := c.Suites[name]
+	if !ok || suite == nil {
+		return nil, fmt.Errorf("unknown suite %q", name)
+	}
+
+	return suite, nil
+}
+
 var ErrPrimaryKeyUndefined = errors.New("primary key undefined")
 
 func (c *Config) GetPrimaryKeyName(table string) (string, error) {
-	options := c.TableOptions[table]
+	options := c.tableOptions(table)
 
 	if options != nil && options.PrimaryKeyName != "" {
 		return options.PrimaryKeyName, nil
@@ -110,33 +716,15 @@ func (c *Config) GetPrimaryKeyName(table string) (string, error) {
 
 // GetReference checks if the given field has a reference and returns its table and field names.
 // If no reference is found, both values are empty and no error is returned.
+//
+// References are table names, or "table.field" to reference a field other
+// than the primary key, e.g. "users.email". Dotted names of TableOptions,
+// such as "audit.events", are tables.
 func (c *Config) GetReference(table, field string) (string, string, error) {
-	srcTableOptions := c.TableOptions[table]
-	srcHasReferences := srcTableOptions != nil && len(srcTableOptions.References) > 0
-	confReferences := c.References
-	confHasReferences := len(confReferences) > 0
-	refTable := ""
-
-	switch {
-	case srcHasReferences:
-		if t, ok := srcTableOptions.References[field]; ok && t != "" {
-			refTable = t
-			break
-		} else if ok {
-			// If the table name is empty, it means the field should not be dereferenced.
-			break
-		}
-
-		fallthrough
-	case confHasReferences:
-		if t, ok := confReferences[field]; ok {
-			refTable = t
-		}
-	}
+	refTable, refField := c.getReference(table, field, nil)
 
-	if refTable == "" {
-		// Set empty values to avoid checking again.
-		return "", "", nil
+	if refTable == "" || refField != "" {
+		return refTable, refField, nil
 	}
 
 	refPrimaryKeyName, err := c.GetPrimaryKeyName(refTable)
@@ -146,3 +734,53 @@ func (c *Config) GetReference(table, field string) (string, string, error) {
 
 	return refTable, refPrimaryKeyName, nil
 }
+
+// getReference returns the table of the reference of the given field, and
+// its field if it isn't the primary key. Dotted references naming one of
+// tables aren't split.
+func (c *Config) getReference(table, field string, tables Database) (string, string) {
+	var refTable string
+	var ok bool
+
+	// If the table name is empty, it means the field should not be
+	// dereferenced.
+	if srcTableOptions := c.tableOptions(table); srcTableOptions != nil {
+		refTable, ok = srcTableOptions.References[field]
+	}
+
+	if !ok {
+		refTable, ok = c.discoveredReferences[table][field]
+	}
+
+	if !ok {
+		refTable = c.References[field]
+	}
+
+	return c.splitReference(refTable, tables)
+}
+
+// splitReference splits a "table.field" reference, unless it names
+// TableOptions or one of tables.
+func (c *Config) splitReference(ref string, tables Database) (string, string) {
+	i := strings.LastIndex(ref, ".")
+	if i <= 0 || i == len(ref)-1 || c.TableOptions[ref] != nil {
+		return ref, ""
+	}
+
+	if _, ok := tables[ref]; ok {
+		return ref, ""
+	}
+
+	return ref[:i], ref[i+1:]
+}
+
+// GetCompositeReference returns the composite reference declared for the given
+// table and field, or nil if there is none.
+func (c *Config) GetCompositeReference(table, field string) *CompositeReference {
+	options := c.tableOptions(table)
+	if options == nil {
+		return nil
+	}
+
+	return options.CompositeReferences[field]
+}
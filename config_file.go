@@ -0,0 +1,184 @@
+package fixture
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// LoadConfig loads a Config from a YAML or TOML file, e.g.
+// fixture.config.yaml, so it can be reviewed and shared without Go code:
+//
+//	primary_key: id
+//	write_mode: sync
+//	references:
+//	  user_id: users
+//	tables:
+//	  users:
+//	    primary_key: user_id
+//	    defaults: {role: member}
+//	  admins:
+//	    table_name: users
+//	    defaults: {role: admin}
+//	    references: {manager_id: users, user_id: ""}
+//	  users#guest:
+//	    extends: users
+//	    defaults: {role: guest}
+//
+// Tables accept the options of the _options directive, plus table_name,
+// extends and references. Functions, such as Commands or BeforeWrite, can be set on the
+// returned Config before its first use.
+func LoadConfig(file string) (*Config, error) {
+	return LoadConfigFS(osFS{}, file)
+}
+
+// LoadConfigFS is like LoadConfig, reading the file from fsys.
+func LoadConfigFS(fsys fs.FS, name string) (*Config, error) {
+	format, err := bodyFormat(path.Ext(name))
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	raw := make(map[string]any)
+
+	if err := unmarshalBody(format, b, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", name, err)
+	}
+
+	config, err := parseConfig(raw)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", name, err)
+	}
+
+	return config, nil
+}
+
+// parseConfig parses a decoded config file.
+func parseConfig(raw map[string]any) (*Config, error) {
+	config := &Config{
+		TableOptions: make(map[string]*TableOptions),
+	}
+
+	for _, key := range sortedKeys(raw) {
+		value := raw[key]
+
+		switch key {
+		case "primary_key":
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("primary_key must be a string, got %T", value)
+			}
+
+			config.PrimaryKeyName = s
+		case "write_mode":
+			writeMode, err := parseWriteMode(value)
+			if err != nil {
+				return nil, fmt.Errorf("write_mode %w", err)
+			}
+
+			config.WriteMode = writeMode
+		case "references":
+			references, err := parseReferences(value)
+			if err != nil {
+				return nil, err
+			}
+
+			config.References = references
+		case "tables":
+			tables, ok := stringMap(value)
+			if !ok {
+				return nil, fmt.Errorf("tables must be a map, got %T", value)
+			}
+
+			for _, name := range sortedKeys(tables) {
+				rawTable, ok := stringMap(tables[name])
+				if !ok {
+					return nil, fmt.Errorf("table %s must be a map, got %T", name, tables[name])
+				}
+
+				options, err := parseConfigTableOptions(rawTable)
+				if err != nil {
+					return nil, fmt.Errorf("table %s: %w", name, err)
+				}
+
+				config.TableOptions[name] = options
+			}
+		default:
+			return nil, fmt.Errorf("unknown option %s", key)
+		}
+	}
+
+	return config, nil
+}
+
+// parseConfigTableOptions parses the options of a table of a config file:
+// the ones of parseTableOptions, plus table_name, extends and references.
+func parseConfigTableOptions(raw map[string]any) (*TableOptions, error) {
+	rest := make(map[string]any, len(raw))
+
+	for k, v := range raw {
+		if k != "table_name" && k != "extends" && k != "references" {
+			rest[k] = v
+		}
+	}
+
+	options, err := parseTableOptions(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range []string{"table_name", "extends"} {
+		v, ok := raw[key]
+		if !ok {
+			continue
+		}
+
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a string, got %T", key, v)
+		}
+
+		if key == "table_name" {
+			options.TableName = s
+		} else {
+			options.Extends = s
+		}
+	}
+
+	if v, ok := raw["references"]; ok {
+		references, err := parseReferences(v)
+		if err != nil {
+			return nil, err
+		}
+
+		options.References = references
+	}
+
+	return options, nil
+}
+
+// parseReferences parses references, mapping fields to tables.
+func parseReferences(value any) (map[string]string, error) {
+	raw, ok := stringMap(value)
+	if !ok {
+		return nil, fmt.Errorf("references must be a map, got %T", value)
+	}
+
+	references := make(map[string]string, len(raw))
+
+	for field, v := range raw {
+		table, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("reference %s must be a table name, got %T", field, v)
+		}
+
+		references[field] = table
+	}
+
+	return references, nil
+}
@@ -0,0 +1,69 @@
+package fixture
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	fsys := fstest.MapFS{
+		"fixture.config.yaml": {Data: []byte(`primary_key: id
+write_mode: sync
+references:
+  user_id: users
+tables:
+  admins:
+    table_name: users
+    defaults: {role: admin}
+    references: {user_id: ""}
+`)},
+		"fixture.config.toml": {Data: []byte(`primary_key = "id"
+
+[tables.users]
+primary_key = "user_id"
+write_mode = "async"
+`)},
+		"invalid.yaml": {Data: []byte("tables:\n  users:\n    refs: {}\n")},
+	}
+
+	config, err := LoadConfigFS(fsys, "fixture.config.yaml")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "id", config.PrimaryKeyName)
+	assert.Equal(t, WriteSync, config.WriteMode)
+	assert.Equal(t, map[string]string{"user_id": "users"}, config.References)
+	assert.Equal(t, &TableOptions{
+		TableName:     "users",
+		References:    map[string]string{"user_id": ""},
+		DefaultValues: Record{"role": "admin"},
+	}, config.TableOptions["admins"])
+
+	writer := &memoryWriter{}
+
+	f := &Fixture{
+		Config:     config,
+		Writer:     writer,
+		Body:       strings.NewReader("admins:\n  alice:\n    user_id: 1\n"),
+		BodyFormat: ".yaml",
+	}
+
+	f.MustApply(t)
+
+	assert.Equal(t, "admin", f.Database["admins"]["alice"]["role"])
+	assert.Equal(t, 1, f.Database["admins"]["alice"]["user_id"])
+
+	config, err = LoadConfigFS(fsys, "fixture.config.toml")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, &TableOptions{PrimaryKeyName: "user_id", WriteMode: WriteAsync}, config.TableOptions["users"])
+
+	_, err = LoadConfigFS(fsys, "invalid.yaml")
+	assert.EqualError(t, err, "config file invalid.yaml: table users: unknown option refs")
+}
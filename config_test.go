@@ -0,0 +1,204 @@
+package fixture
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigValidate(t *testing.T) {
+	config := &Config{
+		References: map[string]string{"admin_id": "users#staff"},
+		TableOptions: map[string]*TableOptions{
+			"users": {
+				DefaultValues: Record{
+					"id":   func(key string) (any, error) { return key, nil },
+					"name": func() string { return "Alice" },
+				},
+			},
+			"users#admin":   {TableName: "users", WriteMode: 3},
+			"users#guest":   {},
+			"users#owner":   {TableName: "users#admin"},
+			"users#support": {Extends: "support"},
+			"orders": {
+				References: map[string]string{"user_id": "users#member"},
+			},
+		},
+	}
+
+	assert.EqualError(t, config.Validate(), `reference admin_id references undefined profile users#staff
+table options orders, reference user_id references undefined profile users#member
+table options users, default value name: func() string is not a func(string) (any, error) nor a DefaultValueFunc
+table options users#admin has invalid write mode 3
+table options users#guest is a profile without TableName
+table options users#owner: TableName users#admin is itself an alias
+table options users#support extends undefined table options support`)
+
+	assert.NoError(t, (&Config{}).Validate())
+}
+
+func TestConfigDefaultValuesFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, body string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %s", name, err)
+		}
+	}
+
+	write("users.yaml", "users:\n  role: member\n  settings: {theme: dark, lang: en}\nusers#admin:\n  role: admin\n")
+	write("posts.toml", "[posts]\nstatus = \"draft\"\n")
+	write("README.md", "Not loaded.")
+
+	if err := os.Mkdir(filepath.Join(dir, "shared"), 0o700); err != nil {
+		t.Fatalf("failed to create dir: %s", err)
+	}
+
+	shared := filepath.Join(dir, "shared", "defaults.yaml")
+	if err := os.WriteFile(shared, []byte("users:\n  active: true\n  role: guest\n"), 0o600); err != nil {
+		t.Fatalf("failed to write defaults: %s", err)
+	}
+
+	f := &Fixture{
+		Config: &Config{
+			DefaultValuesDir: dir,
+			TableOptions: map[string]*TableOptions{
+				"users": {
+					DefaultValuesFile: shared,
+					DefaultValues:     Record{"settings": map[string]any{"lang": "fr"}},
+				},
+				"users#admin": {TableName: "users"},
+			},
+		},
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("users:\n  alice: {}\nusers#admin:\n  bob: {}\nposts:\n  p1: {}\n"),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	alice := f.Database["users"]["alice"]
+	delete(alice, "id")
+
+	assert.Equal(t, Record{"role": "guest", "active": true, "settings": map[string]any{"theme": "dark", "lang": "fr"}}, alice)
+	assert.Equal(t, "admin", f.Database["users#admin"]["bob"]["role"])
+	assert.Equal(t, "draft", f.Database["posts"]["p1"]["status"])
+
+	// The loaded default values are kept apart from the TableOptions.
+	assert.Equal(t, Record{"settings": map[string]any{"lang": "fr"}}, f.Config.TableOptions["users"].DefaultValues)
+	assert.NotContains(t, f.Config.TableOptions, "posts")
+
+	f = &Fixture{
+		Config: &Config{DefaultValuesDir: filepath.Join(dir, "missing")},
+		Writer: &memoryWriter{},
+	}
+
+	assert.ErrorContains(t, f.Apply(), "failed to read default values dir")
+}
+
+func TestConfigDefaultValuesFS(t *testing.T) {
+	f := &Fixture{
+		Config: &Config{
+			DefaultValuesDir: "defaults",
+			// Default values of unused tables don't add unused TableOptions.
+			Strict: true,
+			TableOptions: map[string]*TableOptions{
+				"users":       {DefaultValues: Record{"active": true}},
+				"users#admin": {Extends: "users", DefaultValues: Record{"level": int64(1)}},
+			},
+		},
+		Writer: &memoryWriter{},
+		FS: fstest.MapFS{
+			"defaults/users.yaml": {Data: []byte("users:\n  role: member\n  active: false\nusers#admin:\n  role: admin\n")},
+			"defaults/posts.yaml": {Data: []byte("posts:\n  status: draft\n")},
+			"seed.yaml":           {Data: []byte("users:\n  alice: {}\nusers#admin:\n  bob: {}\n")},
+		},
+		File: "seed.yaml",
+	}
+
+	f.MustApply(t)
+
+	assert.Equal(t, "member", f.Database["users"]["alice"]["role"])
+	assert.Equal(t, true, f.Database["users"]["alice"]["active"])
+
+	// Own default values, then loaded ones, then extended ones.
+	assert.Equal(t, "admin", f.Database["users#admin"]["bob"]["role"])
+	assert.Equal(t, true, f.Database["users#admin"]["bob"]["active"])
+	assert.Equal(t, int64(1), f.Database["users#admin"]["bob"]["level"])
+}
+
+func TestConfigExtends(t *testing.T) {
+	hook := func(ctx context.Context, record Record) error { return nil }
+
+	config := &Config{
+		TableOptions: map[string]*TableOptions{
+			"users": {
+				PrimaryKeyName: "user_id",
+				References:     map[string]string{"team_id": "teams"},
+				DefaultValues:  Record{"role": "member", "active": true},
+				BeforeWrite:    hook,
+			},
+			"users#admin": {
+				Extends:       "users",
+				DefaultValues: Record{"role": "admin"},
+			},
+			"users#owner": {
+				Extends:    "users#admin",
+				References: map[string]string{"org_id": "orgs"},
+			},
+		},
+	}
+
+	f := &Fixture{
+		Config: config,
+		Writer: &memoryWriter{},
+		Body: strings.NewReader(`teams:
+  core: {}
+users#owner:
+  alice:
+    team_id: core
+`),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	owner := config.tableOptions("users#owner")
+
+	assert.Equal(t, "users", owner.TableName)
+	assert.Equal(t, "user_id", owner.PrimaryKeyName)
+	assert.Equal(t, map[string]string{"team_id": "teams", "org_id": "orgs"}, owner.References)
+	assert.Equal(t, Record{"role": "admin", "active": true}, owner.DefaultValues)
+	assert.NotNil(t, owner.BeforeWrite)
+	assert.Equal(t, Record{"role": "member", "active": true}, config.TableOptions["users"].DefaultValues)
+
+	// The TableOptions are left untouched.
+	assert.Equal(t, &TableOptions{Extends: "users#admin", References: map[string]string{"org_id": "orgs"}}, config.TableOptions["users#owner"])
+	assert.Equal(t, &TableOptions{Extends: "users", DefaultValues: Record{"role": "admin"}}, config.TableOptions["users#admin"])
+
+	assert.Equal(t, "admin", f.Database["users#owner"]["alice"]["role"])
+	assert.Equal(t, int64(1), f.Database["users#owner"]["alice"]["team_id"])
+
+	for name, options := range map[string]map[string]*TableOptions{
+		"table options a extend themselves": {
+			"a": {Extends: "b"},
+			"b": {Extends: "a"},
+		},
+		"table options a extend undefined table options b": {
+			"a": {Extends: "b"},
+		},
+	} {
+		f := &Fixture{Config: &Config{TableOptions: options}, Writer: &memoryWriter{}}
+
+		assert.EqualError(t, f.Apply(), name)
+	}
+}
@@ -0,0 +1,243 @@
+package fixture
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ConverterFunc converts a record value before it is written.
+type ConverterFunc func(v any) (any, error)
+
+// Converter converts the values of a Go type, or of the fields matching a
+// pattern, before records are written, so application types such as enums
+// or proto messages can be used in Database literals. See ConvertType and
+// ConvertField.
+type Converter struct {
+	// Type, if set, is the type of the converted values. Values implementing
+	// it match too if it is an interface type.
+	Type reflect.Type
+
+	// Field, if set, is a pattern (see path.Match) of the "table.field"
+	// labels of the converted values, e.g. "*.metadata".
+	Field string
+
+	Convert ConverterFunc
+}
+
+// ConvertType returns a Converter of the values of type T. E.g.:
+//
+//	fixture.ConvertType(func(s Status) (any, error) { return s.String(), nil })
+func ConvertType[T any](fn func(v T) (any, error)) *Converter {
+	return &Converter{
+		Type: reflect.TypeOf((*T)(nil)).Elem(),
+		Convert: func(v any) (any, error) {
+			return fn(v.(T))
+		},
+	}
+}
+
+// ConvertField returns a Converter of the values of the fields matching
+// pattern, e.g. "events.payload".
+func ConvertField(pattern string, fn ConverterFunc) *Converter {
+	return &Converter{Field: pattern, Convert: fn}
+}
+
+// match returns whether the converter converts the value of a field.
+func (c *Converter) match(table, field string, v any) bool {
+	if c.Type != nil {
+		t := reflect.TypeOf(v)
+
+		if t == nil {
+			return false
+		}
+
+		if t != c.Type && (c.Type.Kind() != reflect.Interface || !t.Implements(c.Type)) {
+			return false
+		}
+	}
+
+	if c.Field != "" {
+		ok, err := path.Match(c.Field, table+"."+field)
+
+		return err == nil && ok
+	}
+
+	return true
+}
+
+// ParseTime is a ConverterFunc parsing RFC 3339 strings into time.Time.
+// Other values are returned as is. E.g.:
+//
+//	fixture.ConvertField("*.created_at", fixture.ParseTime)
+func ParseTime(v any) (any, error) {
+	s, ok := v.(string)
+	if !ok {
+		return v, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RFC 3339 time %q", s)
+	}
+
+	return t, nil
+}
+
+// ParseDuration is a ConverterFunc parsing Go duration strings, e.g. "1h30m",
+// into time.Duration. Other values are returned as is.
+func ParseDuration(v any) (any, error) {
+	s, ok := v.(string)
+	if !ok {
+		return v, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid duration %q", s)
+	}
+
+	return d, nil
+}
+
+// ParseTimes is a ConverterFunc parsing the RFC 3339 strings into time.Time
+// and the Go duration strings into time.Duration, returning other values as
+// is, so it can be enabled for whole tables. E.g.:
+//
+//	fixture.ConvertField("events.*", fixture.ParseTimes)
+func ParseTimes(v any) (any, error) {
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return v, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+
+	// Durations need a unit, so numbers such as "0" are kept.
+	if last := s[len(s)-1]; last >= 'a' && last <= 'z' {
+		if d, err := time.ParseDuration(s); err == nil {
+			return d, nil
+		}
+	}
+
+	return v, nil
+}
+
+// ToHstore is a ConverterFunc turning flat maps into Postgres hstore values
+// (pgtype.Hstore). Scalar values are formatted as strings, and nil values
+// are NULL. Other values are returned as is. E.g.:
+//
+//	fixture.ConvertField("products.attributes", fixture.ToHstore)
+//
+// The hstore type must be registered on the pgx connection, see
+// pgtype.HstoreCodec. Columns of type hstore are converted by
+// Config.CoerceValues too.
+func ToHstore(v any) (any, error) {
+	m, ok := stringMap(v)
+	if !ok {
+		return v, nil
+	}
+
+	hstore := make(pgtype.Hstore, len(m))
+
+	for k, value := range m {
+		switch value.(type) {
+		case nil:
+			hstore[k] = nil
+		case string, bool, int, int64, float64:
+			s := fmt.Sprint(value)
+			hstore[k] = &s
+		default:
+			return nil, fmt.Errorf("hstore value %s must be a scalar, got %T", k, value)
+		}
+	}
+
+	return hstore, nil
+}
+
+var (
+	converters   []*Converter
+	convertersMu sync.RWMutex
+)
+
+// RegisterConverter adds a converter applied to the records of every
+// fixture, after the ones of Config.Converters.
+func RegisterConverter(c *Converter) error {
+	if c == nil || c.Convert == nil {
+		return fmt.Errorf("nil converter func")
+	}
+
+	if c.Type == nil && c.Field == "" {
+		return fmt.Errorf("converter without Type nor Field")
+	}
+
+	convertersMu.Lock()
+	defer convertersMu.Unlock()
+
+	converters = append(converters, c)
+
+	return nil
+}
+
+// convertRecord decodes the TableOptions.Base64Fields of a record, then
+// converts its values with the first matching converter, if any.
+func (f *Fixture) convertRecord(tableOptions *TableOptions, table string, record Record) error {
+	if tableOptions != nil {
+		for _, field := range tableOptions.Base64Fields {
+			s, ok := record[field].(string)
+			if !ok {
+				continue
+			}
+
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return fmt.Errorf("failed to decode base64 field %s: %w", field, err)
+			}
+
+			record[field] = b
+		}
+	}
+
+	convertersMu.RLock()
+	registered := converters
+	convertersMu.RUnlock()
+
+	if len(f.Config.Converters) == 0 && len(registered) == 0 {
+		return nil
+	}
+
+	all := make([]*Converter, 0, len(f.Config.Converters)+len(registered))
+	all = append(append(all, f.Config.Converters...), registered...)
+
+	for _, field := range sortedKeys(record) {
+		v := record[field]
+
+		if _, ok := v.(Raw); ok {
+			continue
+		}
+
+		for _, c := range all {
+			if !c.match(table, field, v) {
+				continue
+			}
+
+			converted, err := c.Convert(v)
+			if err != nil {
+				return fmt.Errorf("failed to convert field %s: %w", field, err)
+			}
+
+			record[field] = converted
+
+			break
+		}
+	}
+
+	return nil
+}
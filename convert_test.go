@@ -0,0 +1,156 @@
+package fixture
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+type testStatus int
+
+func (s testStatus) String() string {
+	return [...]string{"draft", "published"}[s]
+}
+
+func TestFixtureConverters(t *testing.T) {
+	config := &Config{
+		Converters: []*Converter{
+			ConvertType(func(s testStatus) (any, error) { return s.String(), nil }),
+			ConvertType(func(s fmt.Stringer) (any, error) { return "stringer", nil }),
+			ConvertField("*.payload", func(v any) (any, error) {
+				b, err := json.Marshal(v)
+				return string(b), err
+			}),
+		},
+	}
+
+	f := &Fixture{
+		Config: config,
+		Writer: &memoryWriter{},
+		Database: Database{
+			"posts": {"p1": {"status": testStatus(1), "title": "Hello", "ttl": time.Second}},
+		},
+		Body:       strings.NewReader("events:\n  e1:\n    payload: {a: 1}\n"),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	// The first matching converter is used.
+	assert.Equal(t, "published", f.Database["posts"]["p1"]["status"])
+	assert.Equal(t, "Hello", f.Database["posts"]["p1"]["title"])
+	assert.Equal(t, "stringer", f.Database["posts"]["p1"]["ttl"])
+	assert.Equal(t, `{"a":1}`, f.Database["events"]["e1"]["payload"])
+
+	assert.Error(t, RegisterConverter(&Converter{Convert: func(v any) (any, error) { return v, nil }}))
+}
+
+func TestFixtureParseTimes(t *testing.T) {
+	config := &Config{
+		Converters: []*Converter{
+			ConvertField("users.created_at", ParseTime),
+			ConvertField("sessions.*", ParseTimes),
+		},
+	}
+
+	f := &Fixture{
+		Config: config,
+		Writer: &memoryWriter{},
+		Body: strings.NewReader(`users:
+  alice:
+    created_at: "2024-01-02T03:04:05Z"
+    ttl: 1h
+sessions:
+  s1:
+    started_at: "2024-01-02T03:04:05+02:00"
+    ttl: 1h30m
+    attempts: "0"
+    name: hourly
+`),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), f.Database["users"]["alice"]["created_at"])
+	assert.Equal(t, "1h", f.Database["users"]["alice"]["ttl"])
+
+	session := f.Database["sessions"]["s1"]
+	assert.True(t, time.Date(2024, 1, 2, 1, 4, 5, 0, time.UTC).Equal(session["started_at"].(time.Time)))
+	assert.Equal(t, 90*time.Minute, session["ttl"])
+	assert.Equal(t, "0", session["attempts"])
+	assert.Equal(t, "hourly", session["name"])
+
+	_, err := ParseDuration("soon")
+	assert.EqualError(t, err, `invalid duration "soon"`)
+}
+
+func TestToHstore(t *testing.T) {
+	f := &Fixture{
+		Config:     &Config{Converters: []*Converter{ConvertField("products.attributes", ToHstore)}},
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("products:\n  p1:\n    attributes: {color: red, size: 42, discontinued: null}\n"),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	red, size := "red", "42"
+
+	assert.Equal(t, pgtype.Hstore{"color": &red, "size": &size, "discontinued": nil}, f.Database["products"]["p1"]["attributes"])
+
+	_, err := ToHstore(map[string]any{"dimensions": []any{1, 2}})
+	assert.EqualError(t, err, "hstore value dimensions must be a scalar, got []interface {}")
+}
+
+func TestFixtureBase64Fields(t *testing.T) {
+	f := &Fixture{
+		Config: &Config{
+			TableOptions: map[string]*TableOptions{
+				"users": {Base64Fields: []string{"avatar"}},
+			},
+		},
+		Writer: &memoryWriter{},
+		Body: strings.NewReader(`_options:
+  tables:
+    files:
+      base64: [content]
+users:
+  alice:
+    avatar: aGVsbG8=
+    name: aGVsbG8=
+files:
+  f1:
+    content: d29ybGQ=
+`),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, []byte("hello"), f.Database["users"]["alice"]["avatar"])
+	assert.Equal(t, "aGVsbG8=", f.Database["users"]["alice"]["name"])
+	assert.Equal(t, []byte("world"), f.Database["files"]["f1"]["content"])
+
+	f = &Fixture{
+		Config:     &Config{TableOptions: map[string]*TableOptions{"users": {Base64Fields: []string{"avatar"}}}},
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("users:\n  alice:\n    avatar: '!!'\n"),
+		BodyFormat: ".yaml",
+	}
+
+	assert.ErrorContains(t, f.Apply(), `failed to convert record "users"."alice": failed to decode base64 field avatar`)
+}
@@ -0,0 +1,173 @@
+package fixture
+
+import (
+	"math/big"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+)
+
+// numberLiteral is a YAML number decoded as a float64, kept to decode it as
+// a decimal.Decimal with Config.DecimalNumbers.
+type numberLiteral struct {
+	// path is the path of the number in the decoded body.
+	path    []string
+	literal string
+}
+
+// yamlNumbers returns the floats, and the integers overflowing int64, of a
+// YAML node, with their paths under path.
+func yamlNumbers(path []string, node *yaml.Node) []numberLiteral {
+	var numbers []numberLiteral
+
+	var walk func(path []string, node *yaml.Node)
+
+	walk = func(path []string, node *yaml.Node) {
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				walk(append(path[:len(path):len(path)], node.Content[i].Value), node.Content[i+1])
+			}
+		case yaml.SequenceNode:
+			for i, item := range node.Content {
+				walk(append(path[:len(path):len(path)], strconv.Itoa(i)), item)
+			}
+		case yaml.ScalarNode:
+			switch node.ShortTag() {
+			case "!!int":
+				if _, err := strconv.ParseInt(node.Value, 0, 64); err == nil {
+					return
+				}
+			case "!!float":
+			default:
+				return
+			}
+
+			numbers = append(numbers, numberLiteral{path: path, literal: node.Value})
+		}
+	}
+
+	walk(path, node)
+
+	return numbers
+}
+
+// setDecimals replaces the numbers of a decoded body by decimal.Decimal
+// values parsed from their literals. Literals that are not decimals, such as
+// .inf, are kept as decoded.
+func setDecimals(raw map[string]any, numbers []numberLiteral) {
+	for _, number := range numbers {
+		d, err := decimal.NewFromString(number.literal)
+		if err != nil {
+			continue
+		}
+
+		setPath(raw, number.path, d)
+	}
+}
+
+// setPath sets the value at path of nested maps and lists, if it exists.
+func setPath(v any, path []string, value any) {
+	for i, elem := range path {
+		last := i == len(path)-1
+
+		switch t := v.(type) {
+		case map[string]any:
+			if _, ok := t[elem]; !ok {
+				return
+			}
+
+			if last {
+				t[elem] = value
+				return
+			}
+
+			v = t[elem]
+		case Record:
+			setPath(map[string]any(t), path[i:], value)
+			return
+		case []any:
+			n, err := strconv.Atoi(elem)
+			if err != nil || n < 0 || n >= len(t) {
+				return
+			}
+
+			if last {
+				t[n] = value
+				return
+			}
+
+			v = t[n]
+		default:
+			return
+		}
+	}
+}
+
+// postgresDecimal returns the decimal string of an arbitrary precision
+// number, so numeric columns get it without float rounding, and whether v is
+// one. Rationals whose decimal expansion doesn't terminate are rounded to 38
+// decimal places, Postgres rounding them to the scale of their column.
+func postgresDecimal(v any) (string, bool) {
+	switch t := v.(type) {
+	case decimal.Decimal:
+		if t.Exponent() < 0 {
+			// Keeps the scale, e.g. "19.90".
+			return t.StringFixed(-t.Exponent()), true
+		}
+
+		return t.String(), true
+	case *big.Int:
+		return t.String(), t != nil
+	case big.Int:
+		return t.String(), true
+	case *big.Rat:
+		if t == nil {
+			return "", false
+		}
+
+		return ratString(t), true
+	case big.Rat:
+		return ratString(&t), true
+	}
+
+	return "", false
+}
+
+// ratString returns the decimal expansion of r, exact if it terminates.
+func ratString(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	// The expansion terminates if the denominator only has factors 2 and
+	// 5, after as many places as the highest power of them.
+	denom := new(big.Int).Set(r.Denom())
+
+	var twos, fives int
+
+	two, five := big.NewInt(2), big.NewInt(5)
+	mod := new(big.Int)
+
+	for mod.Mod(denom, two).Sign() == 0 {
+		denom.Quo(denom, two)
+		twos++
+	}
+
+	for mod.Mod(denom, five).Sign() == 0 {
+		denom.Quo(denom, five)
+		fives++
+	}
+
+	if denom.Cmp(big.NewInt(1)) != 0 {
+		return r.FloatString(38)
+	}
+
+	places := twos
+	if fives > places {
+		places = fives
+	}
+
+	return r.FloatString(places)
+}
@@ -0,0 +1,58 @@
+package fixture
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixtureDecimalNumbers(t *testing.T) {
+	body := `accounts:
+  a1:
+    balance: 12345678901234567890.123456789
+    supply: 123456789012345678901234567890
+    rates: [0.1, 2]
+    limits: {daily: 1e3}
+    count: 3
+`
+
+	f := &Fixture{
+		Config:     &Config{DecimalNumbers: true},
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader(body),
+		BodyFormat: ".yaml",
+	}
+
+	f.MustApply(t)
+
+	account := f.Database["accounts"]["a1"]
+	assert.Equal(t, "12345678901234567890.123456789", account["balance"].(decimal.Decimal).String())
+	assert.Equal(t, "123456789012345678901234567890", account["supply"].(decimal.Decimal).String())
+	assert.Equal(t, []any{decimal.RequireFromString("0.1"), 2}, account["rates"])
+	assert.Equal(t, "1000", account["limits"].(map[string]any)["daily"].(decimal.Decimal).String())
+	assert.Equal(t, 3, account["count"])
+
+	f = &Fixture{
+		Config:     &Config{},
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader(body),
+		BodyFormat: ".yaml",
+	}
+
+	f.MustApply(t)
+
+	assert.IsType(t, float64(0), f.Database["accounts"]["a1"]["balance"])
+}
+
+func TestPostgresDecimal(t *testing.T) {
+	n, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+
+	assert.Equal(t, "123456789012345678901234567890", postgresValue(n))
+	assert.Equal(t, "0.125", postgresValue(big.NewRat(1, 8)))
+	assert.Equal(t, "3", postgresValue(big.NewRat(6, 2)))
+	assert.Equal(t, "0.33333333333333333333333333333333333333", postgresValue(big.NewRat(1, 3)))
+	assert.Equal(t, "19.90", postgresValue(decimal.RequireFromString("19.90")))
+}
@@ -0,0 +1,287 @@
+package fixture
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// DatabaseDiff holds the differences between the records of an applied
+// fixture and the current rows of the database, see Fixture.Diff.
+type DatabaseDiff struct {
+	// Missing holds the fixture records not found in the database.
+	Missing []RecordDiff
+
+	// Extra holds the rows of the fixture tables not matching any record.
+	Extra []RecordDiff
+
+	// Changed holds the fields whose value differs from the fixture's.
+	Changed []FieldDiff
+}
+
+// RecordDiff is a missing record or an extra row. Key is empty for rows.
+type RecordDiff struct {
+	Table  string
+	Key    string
+	Record Record
+}
+
+// FieldDiff is a field of a record whose value differs from the fixture's.
+type FieldDiff struct {
+	Table    string
+	Key      string
+	Field    string
+	Expected any
+	Actual   any
+}
+
+// Empty returns whether the database matches the fixture.
+func (d *DatabaseDiff) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Extra) == 0 && len(d.Changed) == 0
+}
+
+func (d *DatabaseDiff) String() string {
+	var b strings.Builder
+
+	for _, r := range d.Missing {
+		fmt.Fprintf(&b, "- %s.%s: %v\n", r.Table, r.Key, r.Record)
+	}
+
+	for _, r := range d.Extra {
+		fmt.Fprintf(&b, "+ %s: %v\n", r.Table, r.Record)
+	}
+
+	for _, c := range d.Changed {
+		fmt.Fprintf(&b, "~ %s.%s.%s: %v != %v\n", c.Table, c.Key, c.Field, c.Expected, c.Actual)
+	}
+
+	return b.String()
+}
+
+// Diff compares the records of the applied fixture with the current rows of
+// their tables, read with the Querier of their writer. Records are matched
+// to rows by primary key, or by all of their fields if they don't have one.
+// Only the fields of the records (including the ones returned by the writer)
+// are compared.
+func (f *Fixture) Diff() (*DatabaseDiff, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.view().diff()
+}
+
+func (f *Fixture) diff() (*DatabaseDiff, error) {
+	if !f.applied {
+		return nil, errors.New("fixture not applied")
+	}
+
+	diff := new(DatabaseDiff)
+
+	// Tables can be aliased by multiple fixture tables (profiles), whose
+	// records are matched against the same rows.
+	aliases := make(map[string][]string)
+
+	for _, table := range sortedKeys(f.Database) {
+		name := table
+
+		if v := f.Config.TableAlias(table); v != "" {
+			name = v
+		}
+
+		aliases[name] = append(aliases[name], table)
+	}
+
+	for _, name := range sortedKeys(aliases) {
+		tables := aliases[name]
+
+		writer, err := f.getWriter(tables[0])
+		if err != nil {
+			return nil, err
+		}
+
+		querier, ok := writer.(Querier)
+		if !ok {
+			return nil, fmt.Errorf("writer %T does not support queries", writer)
+		}
+
+		rows, err := querier.Query(f, selectAllSQL(name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read table %s: %w", name, err)
+		}
+
+		matched := make([]bool, len(rows))
+
+		for _, table := range tables {
+			primaryKey, err := f.getPrimaryKeyName(table)
+			if err != nil {
+				return nil, err
+			}
+
+			// Rows have the column names of the fields, see insertRecord.
+			tableOptions := f.getTableOptions(table)
+			primaryKey = f.columnName(tableOptions, primaryKey)
+
+			for _, key := range sortedKeys(f.Database[table]) {
+				record := f.Database[table][key]
+
+				i := matchRow(f.columnRecord(tableOptions, record), primaryKey, rows, matched)
+				if i < 0 {
+					diff.Missing = append(diff.Missing, RecordDiff{Table: table, Key: key, Record: record})
+					continue
+				}
+
+				matched[i] = true
+
+				for _, field := range sortedKeys(record) {
+					actual, ok := rows[i][f.columnName(tableOptions, field)]
+					if !ok || !valuesEqual(record[field], actual) {
+						diff.Changed = append(diff.Changed, FieldDiff{
+							Table:    table,
+							Key:      key,
+							Field:    field,
+							Expected: record[field],
+							Actual:   actual,
+						})
+					}
+				}
+			}
+		}
+
+		for i := range rows {
+			if !matched[i] {
+				diff.Extra = append(diff.Extra, RecordDiff{Table: name, Record: rows[i]})
+			}
+		}
+	}
+
+	return diff, nil
+}
+
+// matchRow returns the index of the first unmatched row with the primary
+// key of the record, or with all of its fields if it has none. It returns
+// -1 if no row matches.
+func matchRow(record Record, primaryKey string, rows []Record, matched []bool) int {
+	for i, row := range rows {
+		if matched[i] {
+			continue
+		}
+
+		if v, ok := record[primaryKey]; ok {
+			if valuesEqual(v, row[primaryKey]) {
+				return i
+			}
+
+			continue
+		}
+
+		equal := true
+
+		for field, v := range record {
+			if !valuesEqual(v, row[field]) {
+				equal = false
+				break
+			}
+		}
+
+		if equal {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// selectAllSQL returns the query of the rows of a table, whose name can be
+// qualified by a schema.
+func selectAllSQL(name string) string {
+	return "SELECT * FROM " + pgx.Identifier(strings.Split(name, ".")).Sanitize()
+}
+
+// valuesEqual returns whether a record value equals a row value, comparing
+// times by instant and numbers by value regardless of their types. Integers
+// are compared exactly, and decimals too unless compared to a float.
+func valuesEqual(a, b any) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+
+	if at, ok := a.(time.Time); ok {
+		bt, ok := b.(time.Time)
+		return ok && at.Equal(bt)
+	}
+
+	ai, aok := integerValue(a)
+	bi, bok := integerValue(b)
+
+	if aok && bok {
+		return ai.Cmp(bi) == 0
+	}
+
+	ad, aok := decimalValue(a)
+	bd, bok := decimalValue(b)
+
+	if aok && bok {
+		return ad.Equal(bd)
+	}
+
+	af, ok := numberValue(a)
+	if !ok {
+		return false
+	}
+
+	bf, ok := numberValue(b)
+
+	return ok && af == bf
+}
+
+// integerValue returns the value of an integer of any type.
+func integerValue(v any) (*big.Int, bool) {
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Int).SetUint64(rv.Uint()), true
+	}
+
+	return nil, false
+}
+
+// decimalValue returns the value of a decimal or an integer, as a decimal.
+func decimalValue(v any) (decimal.Decimal, bool) {
+	if d, ok := v.(decimal.Decimal); ok {
+		return d, true
+	}
+
+	if i, ok := integerValue(v); ok {
+		return decimal.NewFromBigInt(i, 0), true
+	}
+
+	return decimal.Decimal{}, false
+}
+
+func numberValue(v any) (float64, bool) {
+	if d, ok := v.(decimal.Decimal); ok {
+		return d.InexactFloat64(), true
+	}
+
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+
+	return 0, false
+}
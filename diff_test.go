@@ -0,0 +1,122 @@
+package fixture
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixtureDiff(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	writer := &memoryWriter{}
+	f := &Fixture{
+		Config:     &Config{},
+		Writer:     writer,
+		Body:       strings.NewReader("users:\n  alice: {id: 1, name: Alice}\n  bob: {id: 2, name: Bob}\n  carol: {id: 3, name: Carol}\n"),
+		BodyFormat: ".yaml",
+		Database:   Database{"events": {"e1": {"id": 1, "name": "signup", "created_at": createdAt}}},
+	}
+
+	_, err := f.Diff()
+	assert.ErrorContains(t, err, "fixture not applied")
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	writer.queries = map[string][]Record{
+		`SELECT * FROM "users"`: {
+			{"id": int32(1), "name": "Alice"},
+			{"id": int32(2), "name": "Robert"},
+			{"id": int32(4), "name": "Dave"},
+		},
+		`SELECT * FROM "events"`: {
+			{"id": int64(1), "name": "signup", "created_at": createdAt.In(time.FixedZone("CET", 3600))},
+		},
+	}
+
+	diff, err := f.Diff()
+	if err != nil {
+		t.Fatalf("failed to Diff: %s", err)
+	}
+
+	assert.False(t, diff.Empty())
+	assert.Equal(t, []RecordDiff{{Table: "users", Key: "carol", Record: f.Database["users"]["carol"]}}, diff.Missing)
+	assert.Equal(t, []RecordDiff{{Table: "users", Record: Record{"id": int32(4), "name": "Dave"}}}, diff.Extra)
+	assert.Equal(t, []FieldDiff{{Table: "users", Key: "bob", Field: "name", Expected: "Bob", Actual: "Robert"}}, diff.Changed)
+	assert.Equal(t, "- users.carol: map[id:3 name:Carol]\n+ users: map[id:4 name:Dave]\n~ users.bob.name: Bob != Robert\n", diff.String())
+}
+
+func TestValuesEqual(t *testing.T) {
+	assert.True(t, valuesEqual(int64(1), int32(1)))
+	assert.True(t, valuesEqual(int64(1<<60), uint64(1<<60)))
+	assert.False(t, valuesEqual(int64(1<<60), int64(1<<60+1)))
+	assert.False(t, valuesEqual(uint64(1<<63), int64(-1<<63)))
+	assert.True(t, valuesEqual(1.5, float32(1.5)))
+	assert.True(t, valuesEqual(2, 2.0))
+	assert.True(t, valuesEqual(decimal.RequireFromString("19.90"), decimal.RequireFromString("19.9")))
+	assert.True(t, valuesEqual(decimal.NewFromInt(1<<60+1), int64(1<<60+1)))
+	assert.False(t, valuesEqual(decimal.NewFromInt(1<<60), int64(1<<60+1)))
+	assert.True(t, valuesEqual(decimal.RequireFromString("0.5"), 0.5))
+	assert.False(t, valuesEqual("1", 1))
+}
+
+func TestFixtureDiffFieldAliases(t *testing.T) {
+	writer := &memoryWriter{}
+	f := &Fixture{
+		Config: &Config{
+			TableOptions: map[string]*TableOptions{
+				"users": {FieldAliases: map[string]string{"id": "user_id", "email": "email_address"}},
+			},
+		},
+		Writer:     writer,
+		Body:       strings.NewReader("users:\n  alice: {id: 1, email: alice@example.com}\n  bob: {id: 2, email: bob@example.com}\n"),
+		BodyFormat: ".yaml",
+	}
+
+	f.MustApply(t)
+
+	writer.queries = map[string][]Record{
+		`SELECT * FROM "users"`: {
+			{"user_id": int64(1), "email_address": "alice@example.com"},
+			{"user_id": int64(2), "email_address": "robert@example.com"},
+		},
+	}
+
+	diff, err := f.Diff()
+	if err != nil {
+		t.Fatalf("failed to Diff: %s", err)
+	}
+
+	assert.Empty(t, diff.Missing)
+	assert.Empty(t, diff.Extra)
+	assert.Equal(t, []FieldDiff{{Table: "users", Key: "bob", Field: "email", Expected: "bob@example.com", Actual: "robert@example.com"}}, diff.Changed)
+}
+
+func TestFixtureDiffColumnName(t *testing.T) {
+	writer := &memoryWriter{}
+	f := &Fixture{
+		Config:     &Config{ColumnName: SnakeCase},
+		Writer:     writer,
+		Body:       strings.NewReader("users:\n  alice: {id: 1, firstName: Alice}\n"),
+		BodyFormat: ".yaml",
+	}
+
+	f.MustApply(t)
+
+	writer.queries = map[string][]Record{
+		`SELECT * FROM "users"`: {{"id": int64(1), "first_name": "Alicia"}},
+	}
+
+	diff, err := f.Diff()
+	if err != nil {
+		t.Fatalf("failed to Diff: %s", err)
+	}
+
+	assert.Empty(t, diff.Missing)
+	assert.Empty(t, diff.Extra)
+	assert.Equal(t, []FieldDiff{{Table: "users", Key: "alice", Field: "firstName", Expected: "Alice", Actual: "Alicia"}}, diff.Changed)
+}
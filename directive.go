@@ -0,0 +1,376 @@
+package fixture
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Reserved fields of records, which are never written.
+const (
+	// whenField excludes the record from Apply unless its template
+	// evaluates to true. E.g.:
+	//
+	//	_when: '{{ eq .scenario "checkout" }}'
+	whenField = "_when"
+
+	// skipField excludes the record from Apply if it is true, or a template
+	// evaluating to true. E.g.:
+	//
+	//	_skip: '{{ env "CI" | empty | not }}'
+	skipField = "_skip"
+
+	// writerField writes the record with the Fixture.Writers entry of the
+	// given name instead of the table's writer.
+	writerField = "_writer"
+
+	// writeModeField overrides the table's write mode for the record:
+	// "sync" writes it after the previous record of the table (by key),
+	// "async" doesn't.
+	writeModeField = "_writeMode"
+
+	// tagsField tags the record with one or more names, which can exclude
+	// it with Fixture.ExcludeTags. See Fixture.RecordTags.
+	tagsField = "_tags"
+
+	// profileField gives the record the DefaultValues of a profile of its
+	// table instead of the table's, e.g. "admin" for the TableOptions
+	// "users#admin" of a users record.
+	profileField = "_profile"
+)
+
+// Reserved keys of fixture files, which are not tables.
+const (
+	// includeDirective loads other fixture files or directories, relative
+	// to the including file, before its own records. E.g.:
+	//
+	//	_include: [plans.yaml, common/]
+	includeDirective = "_include"
+
+	// requiresDirective loads prerequisite fixture files or directories,
+	// relative to the requiring file, before its own records. Unlike
+	// _include, a required file already loaded by Apply isn't loaded again.
+	// E.g.:
+	//
+	//	_requires: [base-users.yaml]
+	requiresDirective = "_requires"
+
+	// optionsDirective sets the options of the file's tables, which
+	// override the ones of the config. See fileOptions.
+	optionsDirective = "_options"
+)
+
+var databaseDirectives = map[string]bool{
+	includeDirective:  true,
+	requiresDirective: true,
+	optionsDirective:  true,
+}
+
+// Reserved keys of tables, which are not records.
+var tableDirectives = map[string]bool{
+	whenField: true,
+}
+
+// decodeDatabase converts a generically decoded fixture body, applying the
+// table directives.
+func (f *Fixture) decodeDatabase(raw map[string]any) (Database, error) {
+	database := make(Database, len(raw))
+
+	for _, name := range sortedKeys(raw) {
+		if databaseDirectives[name] {
+			continue
+		}
+
+		rawTable, ok := stringMap(raw[name])
+		if !ok {
+			return nil, fmt.Errorf("table %s must be a map, got %T", name, raw[name])
+		}
+
+		table, ok, err := f.decodeTable(name, rawTable)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			database[name] = table
+		}
+	}
+
+	return database, nil
+}
+
+// decodeTable converts a generically decoded table, applying its directives.
+// It returns false if the table is excluded by _when.
+func (f *Fixture) decodeTable(name string, raw map[string]any) (Table, bool, error) {
+	if when, ok := raw[whenField]; ok {
+		include, err := f.evaluateWhen(whenField, when)
+		if err != nil {
+			return nil, false, fmt.Errorf("table %s: %w", name, err)
+		}
+
+		if !include {
+			f.Logger.Debug().
+				Str("table", name).
+				Msg("table excluded by " + whenField)
+
+			return nil, false, nil
+		}
+	}
+
+	table := make(Table, len(raw))
+
+	for key, value := range raw {
+		if tableDirectives[key] {
+			continue
+		}
+
+		if value == nil {
+			table[key] = make(Record)
+			continue
+		}
+
+		record, ok := stringMap(value)
+		if !ok {
+			return nil, false, fmt.Errorf("record %s.%s must be a map, got %T", name, key, value)
+		}
+
+		table[key] = record
+	}
+
+	return table, true, nil
+}
+
+// recordOptions are the options of a record, set by its reserved fields.
+type recordOptions struct {
+	writer    string
+	writeMode int
+	tags      []string
+	profile   string
+}
+
+// applyRecordDirectives removes the reserved fields of the table's records,
+// and the records excluded by _when, _skip or Fixture.ExcludeTags.
+func (f *Fixture) applyRecordDirectives(table string, databaseTable Table) error {
+	var errs []error
+
+	for _, key := range sortedKeys(databaseTable) {
+		record := databaseTable[key]
+
+		options, field, err := decodeRecordOptions(record)
+		if err != nil {
+			errs = append(errs, f.recordError(table, key, field, err))
+			continue
+		}
+
+		if options != nil {
+			f.recordOptions[[2]string{table, key}] = options
+		}
+
+		directive, err := f.excludedBy(record, options)
+		if err != nil {
+			errs = append(errs, f.recordError(table, key, directive, err))
+			continue
+		}
+
+		delete(record, whenField)
+		delete(record, skipField)
+
+		if directive == "" {
+			continue
+		}
+
+		f.Logger.Debug().
+			Str("table", table).
+			Str("key", key).
+			Msg("record excluded by " + directive)
+
+		delete(databaseTable, key)
+		f.excluded[[2]string{table, key}] = directive
+	}
+
+	return errors.Join(errs...)
+}
+
+// excludedBy returns the directive excluding the record, if any.
+func (f *Fixture) excludedBy(record Record, options *recordOptions) (string, error) {
+	if when, ok := record[whenField]; ok {
+		include, err := f.evaluateWhen(whenField, when)
+		if err != nil {
+			return whenField, err
+		}
+
+		if !include {
+			return whenField, nil
+		}
+	}
+
+	if skip, ok := record[skipField]; ok {
+		exclude, err := f.evaluateWhen(skipField, skip)
+		if err != nil {
+			return skipField, err
+		}
+
+		if exclude {
+			return skipField, nil
+		}
+	}
+
+	if options != nil {
+		for _, tag := range options.tags {
+			for _, excluded := range f.ExcludeTags {
+				if tag == excluded {
+					return tagsField, nil
+				}
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// decodeRecordOptions removes the _writer, _writeMode, _tags and _profile
+// fields of the record, returning their options or nil if it has none. On error, it
+// also returns the invalid field.
+func decodeRecordOptions(record Record) (*recordOptions, string, error) {
+	var options *recordOptions
+
+	for _, field := range []string{writerField, writeModeField, tagsField, profileField} {
+		value, ok := record[field]
+		if !ok {
+			continue
+		}
+
+		delete(record, field)
+
+		if options == nil {
+			options = new(recordOptions)
+		}
+
+		switch field {
+		case writerField, profileField:
+			s, ok := value.(string)
+			if !ok {
+				return nil, field, fmt.Errorf("%s must be a string, got %T", field, value)
+			}
+
+			if field == writerField {
+				options.writer = s
+			} else {
+				options.profile = s
+			}
+		case writeModeField:
+			writeMode, err := parseWriteMode(value)
+			if err != nil {
+				return nil, field, fmt.Errorf("%s %w", field, err)
+			}
+
+			options.writeMode = writeMode
+		case tagsField:
+			tags, err := directivePaths(field, value)
+			if err != nil {
+				return nil, field, fmt.Errorf("%s must be a tag or a list of tags, got %T", field, value)
+			}
+
+			options.tags = tags
+		}
+	}
+
+	return options, "", nil
+}
+
+// RecordTags returns the tags of a record, set by its _tags field.
+func (f *Fixture) RecordTags(table, key string) []string {
+	if options := f.recordOptions[[2]string{table, key}]; options != nil {
+		return options.tags
+	}
+
+	return nil
+}
+
+// evaluateWhen returns the value of a _when (or _skip) directive, either a
+// boolean or a template executed with the fixture's template data.
+func (f *Fixture) evaluateWhen(directive string, when any) (bool, error) {
+	switch t := when.(type) {
+	case bool:
+		return t, nil
+	case string:
+		tmpl, err := f.parseTemplate(t)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse %s template: %w", directive, err)
+		}
+
+		var b strings.Builder
+
+		if err := tmpl.Execute(&b, f.templateValues()); err != nil {
+			return false, fmt.Errorf("failed to execute %s template: %w", directive, err)
+		}
+
+		s := strings.TrimSpace(b.String())
+		if s == "" {
+			return false, nil
+		}
+
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return false, fmt.Errorf("%s must evaluate to a boolean, got %q", directive, s)
+		}
+
+		return v, nil
+	default:
+		return false, fmt.Errorf("%s must be a boolean or a template, got %T", directive, when)
+	}
+}
+
+// stringMap returns v as a map with string keys, converting the keys of
+// maps decoded from YAML with non-string keys (e.g. 1: ...).
+func stringMap(v any) (map[string]any, bool) {
+	switch t := v.(type) {
+	case map[string]any:
+		return t, true
+	case Record:
+		return t, true
+	case map[any]any:
+		m := make(map[string]any, len(t))
+
+		for k, v := range t {
+			m[fmt.Sprint(k)] = v
+		}
+
+		return m, true
+	}
+
+	return nil, false
+}
+
+// directivePaths returns the value of a directive accepting one or
+// more paths.
+func directivePaths(directive string, value any) ([]string, error) {
+	switch t := value.(type) {
+	case string:
+		return []string{t}, nil
+	case []any:
+		paths := make([]string, len(t))
+
+		for i := range t {
+			s, ok := t[i].(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: path %d must be a string, got %T", directive, i, t[i])
+			}
+
+			paths[i] = s
+		}
+
+		return paths, nil
+	}
+
+	return nil, fmt.Errorf("%s must be a path or a list of paths, got %T", directive, value)
+}
+
+// profileName returns the TableOptions name of a profile of a table, e.g.
+// "users#admin".
+func profileName(table, profile string) string {
+	base, _, _ := strings.Cut(table, "#")
+
+	return base + "#" + profile
+}
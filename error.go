@@ -1,14 +1,117 @@
 package fixture
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
+// RecordError is the error of a record field. Apply reports the errors of
+// every record at once, joined with errors.Join.
 type RecordError struct {
 	Table string
 	Key   string
 	Field string
 	Err   error
+
+	// File is the fixture file defining the record, if any, and Line and
+	// Column the position of the field (or record) in YAML files.
+	File   string
+	Line   int
+	Column int
 }
 
 func (e *RecordError) Error() string {
-	return fmt.Sprintf("table %s, key %s, field %s: %s", e.Table, e.Key, e.Field, e.Err)
+	msg := fmt.Sprintf("table %s, key %s, field %s: %s", e.Table, e.Key, e.Field, e.Err)
+
+	switch {
+	case e.File != "" && e.Line > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, msg)
+	case e.File != "":
+		return e.File + ": " + msg
+	}
+
+	return msg
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// ErrSkipRecord is returned by TableOptions.BeforeWrite and
+// TableOptions.PrepareWrite to skip writing a record.
+var ErrSkipRecord = errors.New("skip record")
+
+// ErrDependencyFailed is the error of the records skipped by an Apply with
+// ContinueOnError, because a record they depend on failed to be written.
+var ErrDependencyFailed = errors.New("dependency failed")
+
+// WriteError is the error of a record that failed to be written, see
+// ApplyError.
+type WriteError struct {
+	Table string
+	Key   string
+	Err   error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("table %s, key %s: %s", e.Table, e.Key, e.Err)
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyError is returned by an Apply with ContinueOnError, holding the
+// errors of the records that failed to be written or were skipped, in
+// writing order.
+type ApplyError struct {
+	Errors []*WriteError
+
+	failed map[[2]string]bool
+}
+
+func (e *ApplyError) Error() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "failed to write %d records:", len(e.Errors))
+
+	for _, err := range e.Errors {
+		b.WriteString("\n\t")
+		b.WriteString(err.Error())
+	}
+
+	return b.String()
+}
+
+func (e *ApplyError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+
+	for i := range e.Errors {
+		errs[i] = e.Errors[i]
+	}
+
+	return errs
+}
+
+func (e *ApplyError) add(table, key string, err error) {
+	e.Errors = append(e.Errors, &WriteError{Table: table, Key: key, Err: err})
+	e.failed[[2]string{table, key}] = true
+}
+
+// failedDependency returns an ErrDependencyFailed error if a record node
+// depends on failed. Layer barriers only order records, so records
+// don't depend on the records of the previous layers through them.
+func (e *ApplyError) failedDependency(node *Node) error {
+	if e == nil {
+		return nil
+	}
+
+	for _, dependency := range node.to {
+		if label := dependency.Label(); e.failed[label] {
+			return fmt.Errorf("%w: %s.%s", ErrDependencyFailed, label[0], label[1])
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,73 @@
+package fixture
+
+import (
+	"errors"
+	"fmt"
+)
+
+// skipExisting returns whether the records of a table already in the
+// database are loaded instead of inserted, see Config.SkipExisting and
+// TableOptions.LookupFields.
+func (f *Fixture) skipExisting(tableOptions *TableOptions) bool {
+	if f.Config.SkipExisting {
+		return true
+	}
+
+	return tableOptions != nil && (tableOptions.SkipExisting || len(tableOptions.LookupFields) > 0)
+}
+
+// findExisting returns the record of the database with the primary key, or
+// the TableOptions.LookupFields, of record, or nil if there is none or record
+// doesn't set them, e.g. if the database generates them. record has the
+// column names of its fields.
+func (f *Fixture) findExisting(writer Writer, tableOptions *TableOptions, table string, record Record) (Record, error) {
+	var keyColumns []string
+
+	if tableOptions != nil && len(tableOptions.LookupFields) > 0 {
+		for _, field := range tableOptions.LookupFields {
+			keyColumns = append(keyColumns, f.columnName(tableOptions, field))
+		}
+	} else {
+		primaryKey, err := f.getPrimaryKeyName(table)
+		if err != nil {
+			return nil, err
+		}
+
+		keyColumns = []string{f.columnName(tableOptions, primaryKey)}
+	}
+
+	where := make(Record, len(keyColumns))
+
+	for _, column := range keyColumns {
+		v, ok := record[column]
+		if !ok || v == nil {
+			return nil, nil
+		}
+
+		where[column] = v
+	}
+
+	finder, ok := writer.(Finder)
+	if !ok {
+		return nil, fmt.Errorf("writer %T does not support lookups", writer)
+	}
+
+	var existing Record
+
+	err := f.retry(func() error {
+		return f.write(func() error {
+			var err error
+
+			existing, err = finder.Find(f, table, where)
+
+			return err
+		})
+	})
+	if errors.Is(err, ErrRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to look up existing record: %w", err)
+	}
+
+	return existing, nil
+}
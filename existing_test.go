@@ -0,0 +1,136 @@
+package fixture
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// insertWriter is a memoryWriter which can't look up records.
+type insertWriter struct {
+	memory memoryWriter
+}
+
+func (w *insertWriter) Insert(f *Fixture, table string, key string, record Record) error {
+	return w.memory.Insert(f, table, key, record)
+}
+
+func (w *insertWriter) Update(f *Fixture, table string, key string, record Record) error {
+	return w.memory.Update(f, table, key, record)
+}
+
+func TestFixtureSkipExisting(t *testing.T) {
+	writer := &memoryWriter{records: map[string][]Record{
+		"users":    {{"id": 1, "name": "Alice (database)"}},
+		"accounts": {{"id": 7, "email": "bob@example.com", "plan": "pro"}},
+	}}
+
+	f := &Fixture{
+		Config: &Config{SkipExisting: true},
+		Writer: writer,
+		Body: strings.NewReader(`_options:
+  tables:
+    accounts:
+      lookup_fields: [email]
+users:
+  alice:
+    id: 1
+    name: Alice
+  bob:
+    id: 2
+    name: Bob
+  carol:
+    name: Carol
+accounts:
+  bob:
+    email: bob@example.com
+    plan: free
+    user_id: =ref users bob
+posts:
+  p1:
+    account_id: =ref accounts bob
+`),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.ElementsMatch(t, []string{"users.bob", "users.carol", "posts.p1"}, writer.inserted)
+	assert.Equal(t, Record{"id": 1, "name": "Alice (database)"}, f.Database["users"]["alice"])
+	assert.Equal(t, "pro", f.Database["accounts"]["bob"]["plan"])
+	assert.Equal(t, 7, f.Database["posts"]["p1"]["account_id"])
+
+	f = &Fixture{
+		Config:     &Config{SkipExisting: true},
+		Writer:     &insertWriter{},
+		Body:       strings.NewReader("users:\n  alice:\n    id: 1\n"),
+		BodyFormat: ".yaml",
+	}
+
+	assert.EqualError(t, f.Apply(), `failed to insert record "users"."alice": writer *fixture.insertWriter does not support lookups`)
+}
+
+func TestFixtureLookupFields(t *testing.T) {
+	writer := &memoryWriter{records: map[string][]Record{
+		"users": {{"id": 42, "email": "alice@example.com", "name": "Alice"}},
+	}}
+
+	f := &Fixture{
+		Config: &Config{
+			TableOptions: map[string]*TableOptions{
+				"users": {LookupFields: []string{"email"}},
+			},
+		},
+		Writer: writer,
+		Body: strings.NewReader(`users:
+  alice:
+    email: alice@example.com
+  bob:
+    email: bob@example.com
+orders:
+  1:
+    user_id: =ref users alice
+  2:
+    user_id: =ref users bob
+`),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.NotContains(t, writer.inserted, "users.alice")
+	assert.Contains(t, writer.inserted, "users.bob")
+	assert.Equal(t, "Alice", f.Database["users"]["alice"]["name"])
+	assert.Equal(t, 42, f.Database["orders"]["1"]["user_id"])
+	assert.Equal(t, f.Database["users"]["bob"]["id"], f.Database["orders"]["2"]["user_id"])
+}
+
+func TestFixtureSkipExistingColumnNames(t *testing.T) {
+	writer := &memoryWriter{records: map[string][]Record{
+		"users": {{"user_id": 1, "name": "Alice (database)"}},
+	}}
+
+	f := &Fixture{
+		Config: &Config{
+			SkipExisting: true,
+			TableOptions: map[string]*TableOptions{
+				"users": {FieldAliases: map[string]string{"id": "user_id"}},
+			},
+		},
+		Writer:     writer,
+		Body:       strings.NewReader("users:\n  alice:\n    id: 1\n    name: Alice\n"),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Empty(t, writer.inserted)
+	assert.Equal(t, "Alice (database)", f.Database["users"]["alice"]["name"])
+}
@@ -0,0 +1,245 @@
+package fixture
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Assert verifies that the tables of the expectation files contain their
+// expected records, failing t otherwise. It is usually called after the
+// code under test runs on an applied fixture. Each expected record must
+// match a different row, see matchesExpectation for the supported matchers.
+func (f *Fixture) Assert(t testing.TB) {
+	t.Helper()
+
+	f.mu.RLock()
+	unmet, err := f.view().unmetExpectations()
+	f.mu.RUnlock()
+
+	if err != nil {
+		t.Fatalf("failed to check expectations: %s", err)
+	}
+
+	for _, msg := range unmet {
+		t.Error(msg)
+	}
+}
+
+// unmetExpectations returns a message for each expected record without a
+// matching row.
+func (f *Fixture) unmetExpectations() ([]string, error) {
+	if !f.applied {
+		return nil, errors.New("fixture not applied")
+	}
+
+	expected, err := f.loadExpectations()
+	if err != nil {
+		return nil, err
+	}
+
+	var unmet []string
+
+	for _, table := range sortedKeys(expected) {
+		name := table
+
+		if v := f.Config.TableAlias(table); v != "" {
+			name = v
+		}
+
+		writer, err := f.getWriter(table)
+		if err != nil {
+			return nil, err
+		}
+
+		querier, ok := writer.(Querier)
+		if !ok {
+			return nil, fmt.Errorf("writer %T does not support queries", writer)
+		}
+
+		rows, err := querier.Query(f, selectAllSQL(name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read table %s: %w", name, err)
+		}
+
+		matched := make([]bool, len(rows))
+		tableOptions := f.getTableOptions(table)
+
+		for _, key := range sortedKeys(expected[table]) {
+			// Rows have the column names of the fields, see insertRecord.
+			record := f.columnRecord(tableOptions, expected[table][key])
+			found := false
+
+			for i, row := range rows {
+				if matched[i] {
+					continue
+				}
+
+				ok, err := f.matchesExpectation(record, row)
+				if err != nil {
+					return nil, fmt.Errorf("expectation %s.%s: %w", table, key, err)
+				}
+
+				if ok {
+					matched[i] = true
+					found = true
+
+					break
+				}
+			}
+
+			if !found {
+				unmet = append(unmet, fmt.Sprintf("expected record %s.%s not found in %s: %v", table, key, name, expected[table][key]))
+			}
+		}
+	}
+
+	return unmet, nil
+}
+
+// loadExpectations loads the expectation files into a new Database, without
+// parsing their records. They are loaded by a throwaway fixture with the
+// settings of f, so the loaded files, table options and includes of f are
+// left untouched.
+func (f *Fixture) loadExpectations() (Database, error) {
+	loader := f.settings()
+	loader.resetState()
+	loader.Database = make(Database)
+	loader.templateData = f.templateData
+
+	for _, path := range f.Expectations {
+		if err := loader.loadPath(loader.source(), loader.fixturePath(path), nil); err != nil {
+			return nil, fmt.Errorf("failed to load expectation %s: %w", path, err)
+		}
+	}
+
+	return loader.Database, nil
+}
+
+// matchesExpectation returns whether every field of the expected record
+// matches the row. Expected values can be matchers:
+//
+//	=any                 any value, including null
+//	=notnull             any value but null
+//	=null                null
+//	=regex <pattern>     a string matching the regular expression
+//	=ref <table> <key>   a field (primary key by default) of a fixture record
+//
+// Other values, including escaped ones such as "==literal", are compared with
+// the row's, times by instant and numbers by value. Strings also match times
+// (RFC 3339) and UUIDs of the same value.
+func (f *Fixture) matchesExpectation(expected, row Record) (bool, error) {
+	for field, want := range expected {
+		got, ok := row[field]
+
+		s, isString := want.(string)
+		if isString && strings.HasPrefix(s, escapedCommandPrefix) {
+			want, isString = s[1:], false
+		}
+
+		if !isString || !strings.HasPrefix(s, "=") {
+			if !ok || !expectedValueEqual(want, got) {
+				return false, nil
+			}
+
+			continue
+		}
+
+		name, arg, _ := strings.Cut(s[1:], " ")
+
+		switch name {
+		case "any":
+		case "notnull":
+			if !ok || got == nil {
+				return false, nil
+			}
+		case "null":
+			if ok && got != nil {
+				return false, nil
+			}
+		case "regex":
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return false, fmt.Errorf("field %s: invalid regex: %w", field, err)
+			}
+
+			gotString, ok := got.(string)
+			if !ok || !re.MatchString(gotString) {
+				return false, nil
+			}
+		case "ref":
+			value, err := f.expectedRef(arg)
+			if err != nil {
+				return false, fmt.Errorf("field %s: %w", field, err)
+			}
+
+			if !ok || !expectedValueEqual(value, got) {
+				return false, nil
+			}
+		default:
+			return false, fmt.Errorf("field %s: unknown matcher %s", field, s)
+		}
+	}
+
+	return true, nil
+}
+
+// expectedRef returns the value referenced by the arguments of a =ref
+// matcher: table, key and optionally field.
+func (f *Fixture) expectedRef(line string) (any, error) {
+	in := &CommandInput{Line: line}
+
+	args, _, err := in.ScanLine()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) < 2 || len(args) > 3 {
+		return nil, fmt.Errorf("=ref expects a table, a key and an optional field, got %q", line)
+	}
+
+	table, key := args[0], f.resolveKey(args[0], args[1])
+
+	field, err := f.getPrimaryKeyName(table)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) == 3 {
+		field = args[2]
+	}
+
+	value, err := f.getField(table, key, field)
+	if err != nil {
+		return nil, fmt.Errorf("%s.%s.%s: %w", table, key, field, err)
+	}
+
+	return value, nil
+}
+
+func expectedValueEqual(want, got any) bool {
+	if valuesEqual(want, got) {
+		return true
+	}
+
+	s, ok := want.(string)
+	if !ok {
+		return false
+	}
+
+	switch t := got.(type) {
+	case time.Time:
+		wantTime, err := time.Parse(time.RFC3339Nano, s)
+		return err == nil && wantTime.Equal(t)
+	case [16]uint8:
+		wantUUID, err := uuid.Parse(s)
+		return err == nil && wantUUID == uuid.UUID(t)
+	}
+
+	return false
+}
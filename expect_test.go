@@ -0,0 +1,137 @@
+package fixture
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixtureAssert(t *testing.T) {
+	writer := &memoryWriter{}
+	f := &Fixture{
+		Config: &Config{},
+		Writer: writer,
+		FS: fstest.MapFS{
+			"seed.yaml": {Data: []byte("users:\n  alice: {name: Alice}\n")},
+			"expected.yaml": {Data: []byte(`
+orders:
+  paid:
+    user_id: =ref users alice
+    status: paid
+    total: 10
+    number: =regex ^ORD-[0-9]+$
+    paid_at: =notnull
+    note: =any
+  pending:
+    user_id: =ref users alice
+    status: pending
+    paid_at: =null
+`)},
+		},
+		File:         "seed.yaml",
+		Expectations: []string{"expected.yaml"},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	aliceID := f.Database["users"]["alice"]["id"]
+
+	writer.queries = map[string][]Record{
+		`SELECT * FROM "orders"`: {
+			{"user_id": aliceID, "status": "pending", "paid_at": nil, "number": "ORD-2"},
+			{"user_id": aliceID, "status": "paid", "total": int32(10), "number": "ORD-1", "paid_at": "2024-01-01", "note": nil},
+		},
+	}
+
+	loaded := make(map[string]bool)
+	for k, v := range f.loaded {
+		loaded[k] = v
+	}
+
+	f.Assert(t)
+
+	// The expectation files are loaded apart from the fixture's.
+	assert.Equal(t, loaded, f.loaded)
+	assert.NotContains(t, f.Database, "orders")
+
+	writer.queries[`SELECT * FROM "orders"`] = writer.queries[`SELECT * FROM "orders"`][:1]
+
+	unmet, err := f.unmetExpectations()
+	if err != nil {
+		t.Fatalf("failed to check expectations: %s", err)
+	}
+
+	assert.Len(t, unmet, 1)
+	assert.True(t, strings.HasPrefix(unmet[0], "expected record orders.paid not found in orders"))
+
+	f.Expectations = []string{"seed.yaml"}
+	writer.queries[`SELECT * FROM "users"`] = []Record{{"name": "Alice"}}
+	f.FS.(fstest.MapFS)["seed.yaml"] = &fstest.MapFile{Data: []byte("users:\n  alice: {name: =unknown}\n")}
+
+	_, err = f.unmetExpectations()
+	assert.ErrorContains(t, err, "unknown matcher =unknown")
+}
+
+func TestFixtureAssertFieldAliases(t *testing.T) {
+	writer := &memoryWriter{}
+	f := &Fixture{
+		Config: &Config{
+			TableOptions: map[string]*TableOptions{
+				"users": {FieldAliases: map[string]string{"email": "email_address"}},
+			},
+		},
+		Writer: writer,
+		FS: fstest.MapFS{
+			"seed.yaml":     {Data: []byte("users:\n  alice: {email: alice@example.com}\n")},
+			"expected.yaml": {Data: []byte("users:\n  alice: {email: alice@example.com}\n")},
+		},
+		File:         "seed.yaml",
+		Expectations: []string{"expected.yaml"},
+	}
+
+	f.MustApply(t)
+
+	writer.queries = map[string][]Record{
+		`SELECT * FROM "users"`: {{"id": int64(1), "email_address": "alice@example.com"}},
+	}
+
+	f.Assert(t)
+}
+
+func TestFixtureAssertColumnName(t *testing.T) {
+	writer := &memoryWriter{}
+	f := &Fixture{
+		Config: &Config{ColumnName: SnakeCase},
+		Writer: writer,
+		FS: fstest.MapFS{
+			"seed.yaml":     {Data: []byte("users:\n  alice: {firstName: Alice}\n")},
+			"expected.yaml": {Data: []byte("users:\n  alice: {firstName: Alice, lastName: =null}\n")},
+		},
+		File:         "seed.yaml",
+		Expectations: []string{"expected.yaml"},
+	}
+
+	f.MustApply(t)
+
+	writer.queries = map[string][]Record{
+		`SELECT * FROM "users"`: {{"id": int64(1), "first_name": "Alice", "last_name": nil}},
+	}
+
+	f.Assert(t)
+}
+
+func TestMatchesEscapedExpectation(t *testing.T) {
+	f := &Fixture{Config: &Config{}}
+
+	ok, err := f.matchesExpectation(Record{"formula": "==SUM(A1)"}, Record{"formula": "=SUM(A1)"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = f.matchesExpectation(Record{"formula": "==SUM(A1)"}, Record{"formula": "==SUM(A1)"})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
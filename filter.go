@@ -0,0 +1,82 @@
+package fixture
+
+import (
+	"fmt"
+	"path"
+)
+
+// filterRecords removes the records not selected by Include and Exclude,
+// keeping the dependencies of the selected ones. Filtered records are kept
+// in the graph, but not written.
+func (f *Fixture) filterRecords() error {
+	if len(f.Include) == 0 && len(f.Exclude) == 0 {
+		return nil
+	}
+
+	for _, pattern := range append(f.Include[:len(f.Include):len(f.Include)], f.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid filter %q: %w", pattern, err)
+		}
+	}
+
+	selected := make(map[[2]string]bool)
+
+	var selectNode func(node *Node)
+	selectNode = func(node *Node) {
+		label := node.Label()
+
+		if selected[label] || label[0] == "" {
+			// Layer barriers only order the records.
+			return
+		}
+
+		selected[label] = true
+
+		for _, dependency := range node.to {
+			selectNode(dependency)
+		}
+	}
+
+	for label, node := range f.nodesByKey {
+		if label[0] == "" {
+			continue
+		}
+
+		if len(f.Include) > 0 && !matchesRecord(f.Include, label) {
+			continue
+		}
+
+		if matchesRecord(f.Exclude, label) {
+			continue
+		}
+
+		selectNode(node)
+	}
+
+	for label := range f.nodesByKey {
+		if label[0] == "" || selected[label] {
+			continue
+		}
+
+		f.filtered[label] = true
+		delete(f.Database[label[0]], label[1])
+	}
+
+	return nil
+}
+
+// matchesRecord returns whether any of the patterns matches the table name
+// or the "table.key" label of a record.
+func matchesRecord(patterns []string, label [2]string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, label[0]); ok {
+			return true
+		}
+
+		if ok, _ := path.Match(pattern, label[0]+"."+label[1]); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -3,34 +3,63 @@ package fixture
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"math/rand"
 	"os"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"text/template"
+	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/oklog/ulid/v2"
 	"github.com/rs/zerolog"
 	"gonum.org/v1/gonum/graph"
 	"gonum.org/v1/gonum/graph/topo"
 	"gopkg.in/yaml.v3"
 )
 
+const (
+	OutputJSON = 1
+	OutputYAML = 2
+	OutputTOML = 3
+)
+
 type Record map[string]any
 type Table map[string]Record
 type Database map[string]Table
 
+// Raw is a value that writers embed verbatim instead of binding it as a
+// parameter, e.g. a SQL expression such as NOW() for PostgresWriter.
+type Raw string
+
 // Writer is an interface that handles inserting or updating database records.
 type Writer interface {
 	Insert(f *Fixture, table, key string, record Record) error
 	Update(f *Fixture, table, key string, record Record) error
 }
 
+// Finder is an optional interface implemented by writers that can look up
+// existing records, such as the ones referenced by the =lookup command.
+// Find returns ErrRecordNotFound if no record matches.
+type Finder interface {
+	Find(f *Fixture, table string, where Record) (Record, error)
+}
+
+// Querier is an optional interface implemented by writers that can run
+// arbitrary queries, such as the ones used by the =sql command.
+type Querier interface {
+	Query(f *Fixture, sql string, args ...any) ([]Record, error)
+}
+
 type Fixture struct {
 	Context context.Context
 	Logger  *zerolog.Logger
@@ -42,8 +71,17 @@ type Fixture struct {
 	// The writer used for this runner.
 	Writer Writer
 
+	// Writers are named writers, used instead of Writer for the tables
+	// whose options set Writer, e.g. with the _options directive.
+	Writers map[string]Writer
+
+	// FS, if set, is the file system fixture files are loaded from instead
+	// of the operating system's, e.g. an embed.FS. Paths are then slash
+	// separated and relative to the FS root.
+	FS fs.FS
+
 	// The directory where fixture files are located.
-	// If non-empty, will be prepended to File.
+	// If non-empty, will be prepended to File and Files.
 	Dir string
 
 	// TODO: Should check for one of?
@@ -51,6 +89,29 @@ type Fixture struct {
 	Body       io.Reader
 	BodyFormat string
 
+	// Files are fixture files or directories, relative to Dir, loaded in
+	// order after File/Body. Dependencies are resolved across all of them,
+	// and records with the same key are replaced by the last one.
+	Files []string
+
+	// IncludeFiles are fixture files or directories, relative to Dir,
+	// loaded before File/Body as if listed in their _include directive.
+	IncludeFiles []string
+
+	// Overlays are fixture files or directories, relative to Dir, loaded
+	// after File/Body. Their records are deep merged over the existing ones,
+	// adding or overriding fields (use =omit to remove one) and records.
+	Overlays []string
+
+	// Expectations are fixture files or directories, relative to Dir, whose
+	// records are expected in the database by Assert. Their values can be
+	// matchers such as =notnull or =regex, see Assert.
+	Expectations []string
+
+	// Suite, if set, is the name of a Config.Suites entry whose files are
+	// loaded before File/Body, with its TemplateData.
+	Suite string
+
 	// Database can be used to set an initial database state.
 	// Any records defined in the File/Body will be merged with
 	// the ones defined here.
@@ -59,56 +120,235 @@ type Fixture struct {
 	// If defined, the fixture body will be parsed as a Go text/template string
 	// and executed with TemplateData as its data.
 	TemplateData map[string]any
-	templateBuf  *bytes.Buffer
 
-	PrintJSON               bool
+	// TemplateDataProviders load values merged, in order, before TemplateData
+	// on every Apply. E.g.:
+	//
+	// 	TemplateDataProviders: []fixture.TemplateDataProvider{
+	// 		fixture.EnvTemplateData("FIXTURE_"),
+	// 		fixture.FileTemplateData("testdata/values.yaml"),
+	// 	}
+	TemplateDataProviders []TemplateDataProvider
+	templateData          map[string]any
+	templateBuf           *bytes.Buffer
+
+	// FuncMap adds or overrides template functions for this fixture only.
+	// Changes take effect on the next Apply. See AddFuncMap.
+	FuncMap template.FuncMap
+
+	templates        map[[sha256.Size]byte]*template.Template
+	templatesVersion int
+
+	// PrintJSON prints the resolved database to Output after Apply.
+	PrintJSON bool
+
+	// DoNotCreateDependencies doesn't create the records referenced but not
+	// defined, for every table, e.g. when all of them are defined. See
+	// TableOptions.DoNotCreateDependencies to make such references fail
+	// for some tables instead.
 	DoNotCreateDependencies bool
 
+	// Output is where the resolved database is printed after Apply, if set
+	// or if PrintJSON is true.
+	// Default: os.Stdout
+	Output io.Writer
+
+	// The format of the printed database.
+	// Default: OutputJSON
+	OutputFormat int
+
+	// Commands adds or overrides commands for this fixture only.
+	// See RegisterCommand.
+	Commands map[string]CommandFunc
+
+	// Include, if set, only writes the records matching any of these
+	// patterns, and the records they depend on. Patterns (see path.Match)
+	// match either table names or "table.key" labels, e.g. "users*" or
+	// "users.alice".
+	Include []string
+
+	// Exclude doesn't write the records matching any of these patterns,
+	// unless a written record depends on them. See Include.
+	Exclude []string
+
+	// ExcludeTags excludes the records tagged with any of these tags by
+	// their _tags field.
+	ExcludeTags []string
+
+	// ContinueOnError keeps writing the records when one fails to be
+	// written, skipping the records depending on it. Apply then returns an
+	// *ApplyError with every failure. Errors loading or parsing the fixture
+	// still stop Apply.
+	ContinueOnError bool
+
+	// Seed, if non-zero, makes commands that generate random values
+	// (e.g. =rand) deterministic across runs.
+	Seed int64
+
+	// Registry, if set, is where the =xref command looks up the fixtures
+	// referenced, and where the fixture is published by Apply if it has a
+	// Name. See Registry and DefaultRegistry.
+	Registry *Registry
+
+	// Name is the name the fixture is published under to its Registry.
+	Name string
+
+	// mu guards the state published by Apply for the accessors, see
+	// GetField. applyMu serializes the runs of Apply, which don't hold mu
+	// while loading and writing the records, in applyGoroutine.
+	mu             sync.RWMutex
+	applyMu        sync.Mutex
+	applyGoroutine atomic.Uint64
+	published      *Fixture
 	applied        bool
+	writeCtx       context.Context
+	body           []byte
+	bodyReader     io.Reader
+
+	initialDatabase    Database
+	hasInitialDatabase bool
+
+	// compiled, if set, holds the loaded files, see Compile.
+	compiled *Compiled
+
 	cmdNameBuilder *strings.Builder
 	nodeIDs        map[int64]*Node
+	nodes          []*Node
 	nodesByKey     map[[2]string]*Node
 	nodeSeq        int64
 	touchedNodes   map[[2]string]bool
+	sequences      map[string]int64
+	keys           map[[2]string]string
+	excluded       map[[2]string]string
+	tableOptions   map[string]*TableOptions
+	recordOptions  map[[2]string]*recordOptions
+	filtered       map[[2]string]bool
+	loaded         map[string]bool
+	recordLayers   map[[2]string][]int
+	recordSources  map[[2]string]*recordSource
+	columns        map[columnsKey]map[string]Column
+	layer          []int
+	snowflakes     map[int64]*snowflake
+	rand           *rand.Rand
+	ulidEntropy    *ulid.MonotonicEntropy
 }
 
 var defaultLogger = zerolog.Nop()
 
+// omitted is returned by parseField when the field should be removed from
+// its record (or parent map).
+var omitted = struct{ omitted bool }{true}
+
+// random returns the fixture's random source, seeded with Seed when defined.
+func (f *Fixture) random() *rand.Rand {
+	if f.rand == nil {
+		seed := f.Seed
+
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+
+		f.rand = rand.New(rand.NewSource(seed))
+	}
+
+	return f.rand
+}
+
+// ulidRandom returns the entropy used to generate ULIDs, which follows Seed
+// when defined.
+func (f *Fixture) ulidRandom() io.Reader {
+	if f.Seed != 0 {
+		return f.random()
+	}
+
+	return ulid.DefaultEntropy()
+}
+
 func (f *Fixture) Applied() bool {
-	return f.applied
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.view().applied
 }
 
+// Apply loads, resolves and writes the fixture's records. Accessors such as
+// GetField can be called by its commands and writers, which read the records
+// being applied. Other goroutines don't wait for Apply: the fixture reads as
+// not applied to them until it completes.
 func (f *Fixture) Apply() error {
-	if f.Config == nil {
-		f.Config = &Config{}
-	}
+	f.applyMu.Lock()
+	defer f.applyMu.Unlock()
 
-	if err := f.Config.init(); err != nil {
+	f.mu.Lock()
+	f.published = new(Fixture)
+	f.mu.Unlock()
+
+	f.applyGoroutine.Store(goroutineID())
+
+	defer func() {
+		f.applyGoroutine.Store(0)
+		f.publish()
+	}()
+
+	if err := f.init(); err != nil {
 		return err
 	}
 
-	if f.Writer == nil {
+	if f.Writer == nil && len(f.Writers) == 0 {
 		return fmt.Errorf("missing writer")
 	}
 
-	if f.Context == nil {
-		f.Context = context.Background()
+	if f.Registry != nil && f.Name != "" {
+		// Published before writing, so the name is taken, but the
+		// =xref commands of other fixtures fail until Apply completes.
+		if err := f.Registry.publish(f.Name, f); err != nil {
+			return err
+		}
 	}
 
-	if f.Logger == nil {
-		f.Logger = &defaultLogger
+	if !f.hasInitialDatabase {
+		// Kept for Reset.
+		f.initialDatabase = copyDatabase(f.Database)
+		f.hasInitialDatabase = true
 	}
 
-	f.cmdNameBuilder = new(strings.Builder)
-	f.nodeIDs = make(map[int64]*Node)
-	f.nodesByKey = make(map[[2]string]*Node)
-	f.touchedNodes = make(map[[2]string]bool)
+	f.resetState()
 
-	if f.Database == nil {
-		f.Database = make(Database)
+	if f.compiled != nil {
+		f.loadCompiled(f.compiled)
+	} else {
+		if f.Database == nil {
+			f.Database = make(Database)
+		}
+
+		if err := f.loadTemplateData(); err != nil {
+			return err
+		}
+
+		if err := f.handleFiles(); err != nil {
+			return err
+		}
+	}
+
+	if err := f.validateConfig(); err != nil {
+		return err
 	}
 
-	if err := f.handleFiles(); err != nil {
+	if err := f.handleDatabase(f.Database); err != nil {
+		return err
+	}
+
+	f.addLayerBarriers()
+
+	if err := f.filterRecords(); err != nil {
+		return err
+	}
+
+	if err := f.checkWriters(); err != nil {
+		return err
+	}
+
+	if err := f.validateSchema(); err != nil {
 		return err
 	}
 
@@ -119,61 +359,377 @@ func (f *Fixture) Apply() error {
 		return fmt.Errorf("failed to sort records topologically: %w", err)
 	}
 
+	var applyErr *ApplyError
+
 	for i := range nodes {
 		node := nodes[i].(*Node)
 		label := node.Label()
 		table, key := label[0], label[1]
-		record := f.Database[table][key]
-		tableOptions := f.Config.TableOptions[table]
 
-		if tableOptions != nil && tableOptions.BeforeWrite != nil {
-			if err := tableOptions.BeforeWrite(f.Context, record); err != nil {
-				return fmt.Errorf("failed to execute BeforeWrite func: %w", err)
+		if table == "" || f.filtered[label] {
+			// Layer barriers only order the records, and filtered
+			// records are not written.
+			continue
+		}
+
+		if !f.ContinueOnError {
+			if err := f.writeNode(node); err != nil {
+				return err
 			}
+
+			continue
 		}
 
-		if err := f.Writer.Insert(f, table, key, record); err != nil {
-			return fmt.Errorf("failed to insert record %q.%q: %w", table, key, err)
+		err := applyErr.failedDependency(node)
+		if err == nil {
+			err = f.writeNode(node)
 		}
 
-		for label, callback := range node.callbacks {
-			if err := callback(); err != nil {
-				return fmt.Errorf("failed to execute callback %v: %w", label, err)
+		if err != nil {
+			if applyErr == nil {
+				applyErr = &ApplyError{failed: make(map[[2]string]bool)}
 			}
+
+			applyErr.add(table, key, err)
 		}
 	}
 
-	if f.PrintJSON {
-		fjson, err := json.MarshalIndent(f.Database, "", "	")
-		if err != nil {
-			return fmt.Errorf("failed to marshal fixture items: %w", err)
+	if f.PrintJSON || f.Output != nil {
+		if err := f.printDatabase(); err != nil {
+			return err
 		}
-
-		fmt.Println(string(fjson))
 	}
 
 	f.applied = true
 
+	if applyErr != nil {
+		return applyErr
+	}
+
 	return nil
 }
 
-func (f *Fixture) parseTable(table string, databaseTable Table, recursiveDatabase Database) error {
-	tableOptions := f.Config.TableOptions[table]
-	hasTableOptions := tableOptions != nil
-	syncWrites := (f.Config.WriteMode == WriteSync && (!hasTableOptions || tableOptions.WriteMode == 0)) || (hasTableOptions && tableOptions.WriteMode == WriteSync)
+// publish publishes the state of f read by the accessors of the goroutines
+// other than the one running Apply.
+func (f *Fixture) publish() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	published := f.settings()
+	published.Writer = f.Writer
+	published.applied = f.applied
+	published.Database = f.Database
+	published.keys = f.keys
+	published.tableOptions = f.tableOptions
+	published.recordOptions = f.recordOptions
+	published.templateData = f.templateData
+
+	f.published = published
+}
 
-	keys := make([]string, len(databaseTable))
-	i := 0
+// view returns the state read by the accessors: the one published by Apply,
+// or else f itself when Apply never ran or when called by Apply, e.g. by its
+// commands and writers. Callers hold mu.
+func (f *Fixture) view() *Fixture {
+	if f.published == nil || f.applying() {
+		return f
+	}
 
-	for k := range databaseTable {
-		keys[i] = k
-		i++
+	return f.published
+}
+
+// applying returns whether the calling goroutine is running Apply.
+func (f *Fixture) applying() bool {
+	id := f.applyGoroutine.Load()
+
+	return id != 0 && id == goroutineID()
+}
+
+// writeNode resolves and writes the record of a node, unless skipped by its
+// table's BeforeWrite or PrepareWrite, then runs the callbacks of the records
+// depending on it.
+func (f *Fixture) writeNode(node *Node) error {
+	label := node.Label()
+	table, key := label[0], label[1]
+	record := f.Database[table][key]
+	tableOptions := f.getTableOptions(table)
+
+	if err := node.resolve(); err != nil {
+		return fmt.Errorf("failed to resolve record %q.%q: %w", table, key, err)
+	}
+
+	record, skip, err := f.prepareRecord(tableOptions, table, key, record)
+	if err != nil {
+		return err
+	}
+
+	if skip {
+		f.Logger.Debug().
+			Str("table", table).
+			Str("key", key).
+			Msg("record skipped by BeforeWrite")
+	} else if err := f.insertRecord(tableOptions, table, key, record); err != nil {
+		return err
 	}
 
-	if syncWrites {
-		sort.Strings(keys)
+	for label, callback := range node.callbacks {
+		if err := callback(); err != nil {
+			return fmt.Errorf("failed to execute callback %v: %w", label, err)
+		}
+	}
+
+	return nil
+}
+
+// prepareRecord runs the BeforeWrite and PrepareWrite funcs of a record's
+// table, returning the record to write, or whether to skip it.
+func (f *Fixture) prepareRecord(tableOptions *TableOptions, table, key string, record Record) (Record, bool, error) {
+	if tableOptions == nil {
+		return record, false, nil
+	}
+
+	if tableOptions.BeforeWrite != nil {
+		err := tableOptions.BeforeWrite(f.Context, record)
+		if errors.Is(err, ErrSkipRecord) {
+			return record, true, nil
+		} else if err != nil {
+			return nil, false, fmt.Errorf("failed to execute BeforeWrite func: %w", err)
+		}
+	}
+
+	if tableOptions.PrepareWrite != nil {
+		replacement, err := tableOptions.PrepareWrite(f.Context, record)
+		if errors.Is(err, ErrSkipRecord) {
+			return record, true, nil
+		} else if err != nil {
+			return nil, false, fmt.Errorf("failed to execute PrepareWrite func: %w", err)
+		}
+
+		if replacement != nil {
+			// Replaced in the database too, for the records referencing
+			// it and accessors such as GetField.
+			f.Database[table][key] = replacement
+			record = replacement
+		}
 	}
 
+	return record, false, nil
+}
+
+// insertRecord writes a record, without the OmitFields of its table and
+// with the column names of its fields (see Fixture.columnName), with its
+// writer, then runs the AfterWrite func of its table. With SkipExisting, a
+// record already in the database is loaded into record instead.
+func (f *Fixture) insertRecord(tableOptions *TableOptions, table, key string, record Record) error {
+	writer, err := f.getRecordWriter(table, key)
+	if err != nil {
+		return err
+	}
+
+	if tableOptions != nil {
+		for _, field := range tableOptions.OmitFields {
+			delete(record, field)
+		}
+	}
+
+	if err := f.convertRecord(tableOptions, table, record); err != nil {
+		return fmt.Errorf("failed to convert record %q.%q: %w", table, key, err)
+	}
+
+	columns := f.columnNames(tableOptions, record)
+	renameFields(record, columns)
+
+	if err := f.coerceRecord(writer, table, record); err != nil {
+		return fmt.Errorf("failed to coerce record %q.%q: %w", table, key, err)
+	}
+
+	var existing Record
+
+	if f.skipExisting(tableOptions) {
+		existing, err = f.findExisting(writer, tableOptions, table, record)
+	}
+
+	if existing != nil {
+		for k, v := range existing {
+			record[k] = v
+		}
+	} else if err == nil {
+		err = f.retry(func() error {
+			return f.write(func() error {
+				return writer.Insert(f, table, key, record)
+			})
+		})
+	}
+
+	// Back to the fixture names, including in the values the writer
+	// populated.
+	renameFields(record, invertNames(columns))
+
+	if err != nil {
+		return fmt.Errorf("failed to insert record %q.%q: %w", table, key, err)
+	}
+
+	if existing != nil {
+		f.Logger.Debug().
+			Str("table", table).
+			Str("key", key).
+			Msg("existing record loaded")
+
+		return nil
+	}
+
+	if tableOptions != nil && tableOptions.AfterWrite != nil {
+		if err := tableOptions.AfterWrite(f.Context, record); err != nil {
+			return fmt.Errorf("failed to execute AfterWrite func: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// write runs a writer operation once allowed by Config.WriteLimit, with a
+// Context timing out after Config.WriteTimeout if set.
+func (f *Fixture) write(op func() error) error {
+	done, err := f.Config.WriteLimit.wait(f.Context)
+	if err != nil {
+		return err
+	}
+
+	defer done()
+
+	timeout := f.Config.WriteTimeout
+	if timeout <= 0 {
+		return op()
+	}
+
+	ctx, cancel := context.WithTimeout(f.Context, timeout)
+
+	defer func() {
+		cancel()
+		f.writeCtx = nil
+	}()
+
+	f.writeCtx = ctx
+
+	err = op()
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("write timed out after %s: %w", timeout, err)
+	}
+
+	return err
+}
+
+// WriteContext returns the Context of the running writer operation, which
+// times out after Config.WriteTimeout if set, or else Context. Writers use it
+// instead of Context.
+func (f *Fixture) WriteContext() context.Context {
+	if f.writeCtx != nil {
+		return f.writeCtx
+	}
+
+	return f.Context
+}
+
+// init sets the defaults of the fixture's settings.
+func (f *Fixture) init() error {
+	if f.Config == nil {
+		f.Config = &Config{}
+	}
+
+	if err := f.Config.init(f.source()); err != nil {
+		return err
+	}
+
+	if f.Context == nil {
+		f.Context = context.Background()
+	}
+
+	if f.Logger == nil {
+		f.Logger = &defaultLogger
+	}
+
+	// Once the defaults are set, as the writer reads the foreign keys.
+	if err := f.Config.discoverReferences(f); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// resetState clears the state of a previous Apply.
+func (f *Fixture) resetState() {
+	if len(f.FuncMap) > 0 || f.Config != nil && len(f.Config.FuncMap) > 0 {
+		// Parsed with FuncMaps that may have changed since.
+		f.templates = nil
+	}
+
+	f.cmdNameBuilder = new(strings.Builder)
+	f.nodeIDs = make(map[int64]*Node)
+	f.nodes = nil
+	f.nodesByKey = make(map[[2]string]*Node)
+	f.touchedNodes = make(map[[2]string]bool)
+	f.sequences = make(map[string]int64)
+	f.keys = make(map[[2]string]string)
+	f.excluded = make(map[[2]string]string)
+	f.tableOptions = make(map[string]*TableOptions)
+	f.recordOptions = make(map[[2]string]*recordOptions)
+	f.filtered = make(map[[2]string]bool)
+	f.loaded = make(map[string]bool)
+	f.recordLayers = make(map[[2]string][]int)
+	f.recordSources = make(map[[2]string]*recordSource)
+	f.columns = make(map[columnsKey]map[string]Column)
+	f.layer = nil
+	f.snowflakes = make(map[int64]*snowflake)
+	f.rand = nil
+	f.ulidEntropy = nil
+}
+
+// printDatabase writes the resolved database to Output in OutputFormat.
+func (f *Fixture) printDatabase() error {
+	var b []byte
+	var err error
+
+	switch f.OutputFormat {
+	case 0, OutputJSON:
+		b, err = json.MarshalIndent(f.Database, "", "	")
+		b = append(b, '\n')
+	case OutputYAML:
+		b, err = yaml.Marshal(f.Database)
+	case OutputTOML:
+		buf := new(bytes.Buffer)
+		err = toml.NewEncoder(buf).Encode(databaseWithoutNulls(f.Database))
+		b = buf.Bytes()
+	default:
+		return fmt.Errorf("unsupported output format: %d", f.OutputFormat)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal fixture items: %w", err)
+	}
+
+	output := f.Output
+	if output == nil {
+		output = os.Stdout
+	}
+
+	if _, err := output.Write(b); err != nil {
+		return fmt.Errorf("failed to print fixture items: %w", err)
+	}
+
+	return nil
+}
+
+func (f *Fixture) parseTable(table string, databaseTable Table, recursiveDatabase Database) error {
+	tableOptions := f.getTableOptions(table)
+	hasTableOptions := tableOptions != nil
+	syncWrites := (f.Config.WriteMode == WriteSync && (!hasTableOptions || tableOptions.WriteMode == 0)) || (hasTableOptions && tableOptions.WriteMode == WriteSync)
+
+	// Sorting keeps the processing order stable, which sync writes and
+	// commands such as =seq and =rand rely on.
+	keys := sortedKeys(databaseTable)
+
+	var errs []error
+
 	for i := range keys {
 		key := keys[i]
 		record := databaseTable[key]
@@ -185,11 +741,28 @@ func (f *Fixture) parseTable(table string, databaseTable Table, recursiveDatabas
 			Str("key", key).
 			Send()
 
+		var defaultValues Record
+
 		if hasTableOptions {
-			for k, v := range tableOptions.DefaultValues {
-				if _, ok := record[k]; !ok {
-					record[k] = v
-				}
+			defaultValues = tableOptions.DefaultValues
+		}
+
+		if options := f.recordOptions[nodeKey]; options != nil && options.profile != "" {
+			profile := profileName(table, options.profile)
+
+			profileOptions := f.getTableOptions(profile)
+			if profileOptions == nil {
+				errs = append(errs, f.recordError(table, key, profileField, fmt.Errorf("undefined profile %s", profile)))
+				continue
+			}
+
+			defaultValues = profileOptions.DefaultValues
+		}
+
+		for k, v := range defaultValues {
+			if _, ok := record[k]; !ok {
+				// Copied, as parsing updates nested values.
+				record[k] = deepCopy(v)
 			}
 		}
 
@@ -197,7 +770,13 @@ func (f *Fixture) parseTable(table string, databaseTable Table, recursiveDatabas
 			f.touchedNodes[nodeKey] = true
 		}
 
-		if syncWrites && i > 0 {
+		recordSyncWrites := syncWrites
+
+		if options := f.recordOptions[nodeKey]; options != nil && options.writeMode != 0 {
+			recordSyncWrites = options.writeMode == WriteSync
+		}
+
+		if recordSyncWrites && i > 0 {
 			// When writing synchronously, add the previous key (node)
 			// as a dependency to ensure it is processed before this one.
 
@@ -208,25 +787,69 @@ func (f *Fixture) parseTable(table string, databaseTable Table, recursiveDatabas
 			node.AppendTo(dependencyNode)
 		}
 
-		for field := range record {
-			value := record[field]
+		// Fields set by record funcs, called once the other fields are
+		// parsed.
+		var funcFields []string
 
-			// Copy to prevent closure issues.
-			fieldCopy := field
+		for _, field := range sortedKeys(record) {
+			value := record[field]
 
 			f.Logger.Debug().
 				Str("field", field).
 				Send()
 
-			recordErr := func(e error) *RecordError {
-				return &RecordError{
-					Table: table,
-					Key:   key,
-					Field: field,
-					Err:   e,
-				}
+			if f.isPlainValue(table, field, value) {
+				// Fast path, most fields have nothing to parse.
+				continue
+			}
+
+			if _, ok := asDefaultValueFunc(value); ok {
+				funcFields = append(funcFields, field)
+				continue
+			}
+
+			// Copy to prevent closure issues.
+			fieldCopy := field
+
+			v, err := f.parseField(
+				table,
+				key,
+				field,
+				value,
+				node,
+				recursiveDatabase,
+				func(v any) {
+					record[fieldCopy] = v
+				},
+			)
+			if err != nil {
+				// Keep parsing, so every error is reported at once.
+				errs = append(errs, f.recordError(table, key, field, err))
+				continue
+			}
+
+			if v == omitted {
+				delete(record, field)
+				continue
 			}
 
+			record[field] = v
+		}
+
+		for _, field := range funcFields {
+			fn, _ := asDefaultValueFunc(record[field])
+
+			value, err := fn(f.Context, table, key, record)
+			if err != nil {
+				errs = append(errs, f.recordError(table, key, field, fmt.Errorf("failed to execute func: %w", err)))
+				delete(record, field)
+
+				continue
+			}
+
+			// Copy to prevent closure issues.
+			fieldCopy := field
+
 			v, err := f.parseField(
 				table,
 				key,
@@ -239,14 +862,58 @@ func (f *Fixture) parseTable(table string, databaseTable Table, recursiveDatabas
 				},
 			)
 			if err != nil {
-				return recordErr(err)
+				errs = append(errs, f.recordError(table, key, field, err))
+				continue
+			}
+
+			if v == omitted {
+				delete(record, field)
+				continue
 			}
 
 			record[field] = v
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
+}
+
+// asDefaultValueFunc returns value as a DefaultValueFunc, if it is one or a
+// func literal of the same signature.
+func asDefaultValueFunc(value any) (DefaultValueFunc, bool) {
+	switch fn := value.(type) {
+	case DefaultValueFunc:
+		return fn, true
+	case func(ctx context.Context, table, key string, record Record) (any, error):
+		return fn, true
+	}
+
+	return nil, false
+}
+
+// isPlainValue returns whether parseField would return value as is, without
+// running any command: scalars, and strings neither starting with "=" nor
+// set in a reference field.
+func (f *Fixture) isPlainValue(table, field string, value any) bool {
+	switch t := value.(type) {
+	case []any, map[string]any, func(string) (any, error), DefaultValueFunc,
+		func(ctx context.Context, table, key string, record Record) (any, error):
+		return false
+	case string:
+		if t == "" {
+			return true
+		}
+
+		if t[0] == '=' {
+			return false
+		}
+
+		refTable, _ := f.Config.getReference(table, field, f.Database)
+
+		return refTable == "" && f.Config.GetCompositeReference(table, field) == nil
+	}
+
+	return true
 }
 
 func (f *Fixture) parseField(table, key, field string, value any, node *Node, recursiveDatabase Database, updateCallback func(v any)) (any, error) {
@@ -279,11 +946,17 @@ func (f *Fixture) parseField(table, key, field string, value any, node *Node, re
 					t[iCopy] = v
 				})
 			if err != nil {
-				return nil, fmt.Errorf("failed to parse field %s.%d: %w", field, i, err)
+				return nil, &fieldError{field: field + "." + strconv.Itoa(i), err: err}
+			}
+
+			if a == omitted {
+				return nil, fmt.Errorf("list element %s.%d cannot be omitted", field, i)
 			}
 
 			t[i] = a
 		}
+
+		return t, nil
 	case map[string]any:
 		for k := range t {
 			// Copy to prevent closure issues.
@@ -300,7 +973,12 @@ func (f *Fixture) parseField(table, key, field string, value any, node *Node, re
 					t[kCopy] = v
 				})
 			if err != nil {
-				return nil, fmt.Errorf("failed to parse field %s.%s: %w", field, k, err)
+				return nil, &fieldError{field: field + "." + k, err: err}
+			}
+
+			if a == omitted {
+				delete(t, k)
+				continue
 			}
 
 			t[k] = a
@@ -312,18 +990,24 @@ func (f *Fixture) parseField(table, key, field string, value any, node *Node, re
 			return value, nil
 		}
 
+		if strings.HasPrefix(t, escapedCommandPrefix) {
+			// A literal value starting with "=", see escapeValue.
+			return t[1:], nil
+		}
+
 		v = t
 	default:
 		return value, nil
 	}
 
 	if v[0] != '=' {
-		refTable, _, err := f.Config.GetReference(table, field)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get reference for %s.%s: %w", table, field, err)
-		}
+		refTable, refField := f.Config.getReference(table, field, f.Database)
 
-		if refTable != "" {
+		if composite := f.Config.GetCompositeReference(table, field); composite != nil {
+			v = "=ref " + composite.Table + " " + v
+		} else if refTable != "" && refField != "" {
+			v = "=ref " + refTable + " " + v + " " + refField
+		} else if refTable != "" {
 			v = "=ref " + refTable + " " + v
 		} else {
 			// This can only be false if v is unchanged, meaning
@@ -332,40 +1016,44 @@ func (f *Fixture) parseField(table, key, field string, value any, node *Node, re
 		}
 	}
 
-	cmdName := new(strings.Builder)
+	var cmdOut *CommandOutput
+	var err error
 
-	var breakIndex int
+	for i, stage := range strings.Split(v, pipeSeparator) {
+		var input any
 
-	for i := 1; i < len(v); i++ {
-		c := v[i]
+		if i > 0 {
+			if cmdOut.Omit || cmdOut.Resolve != nil || len(cmdOut.Dependencies) > 0 {
+				return nil, fmt.Errorf("command output cannot be piped: %s", v)
+			}
 
-		if c == ' ' || c == '\n' || c == '\t' {
-			breakIndex = i
-			break
+			input = cmdOut.Value
+			stage = "=" + stage
 		}
 
-		cmdName.WriteByte(c)
+		cmdOut, err = f.runCommand(table, key, field, stage, i > 0, input)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	cmdFunc, ok := commands[cmdName.String()]
-	if !ok {
-		return nil, fmt.Errorf("unknown command: %s", cmdName.String())
+	if cmdOut.Omit && len(cmdOut.Dependencies) == 0 {
+		return omitted, nil
 	}
 
-	cmdIn := &CommandInput{
-		Fixture: f,
-		Table:   table,
-		Key:     key,
-		Field:   field,
-	}
+	if cmdOut.Resolve != nil {
+		node.addResolver(field, func() error {
+			v, err := cmdOut.Resolve()
+			if err != nil {
+				return fmt.Errorf("table %s, key %s, field %s: %w", table, key, field, err)
+			}
 
-	if breakIndex > 0 {
-		cmdIn.Line = v[breakIndex:]
-	}
+			updateCallback(v)
 
-	cmdOut, err := cmdFunc(cmdIn)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute command %s: %w", cmdName.String(), err)
+			return nil
+		})
+
+		return value, nil
 	}
 
 	if len(cmdOut.Dependencies) == 0 {
@@ -375,6 +1063,15 @@ func (f *Fixture) parseField(table, key, field string, value any, node *Node, re
 	for j := range cmdOut.Dependencies {
 		dependency := cmdOut.Dependencies[j]
 		dependencyNodeKey := cmdOut.Dependencies[j].Label
+
+		if directive, ok := f.excluded[dependencyNodeKey]; ok {
+			return nil, fmt.Errorf("reference to %s.%s, which is excluded by %s", dependencyNodeKey[0], dependencyNodeKey[1], directive)
+		}
+
+		if err := f.checkDefined(dependencyNodeKey); err != nil {
+			return nil, err
+		}
+
 		dependencyNode := f.GetNode(dependencyNodeKey)
 
 		if dependency.Callback != nil {
@@ -392,6 +1089,20 @@ func (f *Fixture) parseField(table, key, field string, value any, node *Node, re
 			})
 		}
 
+		for depField, callback := range dependency.FieldCallbacks {
+			// Copy to prevent closure issues.
+			depFieldCopy, callbackCopy := depField, callback
+
+			dependencyNode.callbacks = append(dependencyNode.callbacks, func() error {
+				v, err := callbackCopy()
+				if err != nil {
+					return err
+				}
+
+				return f.setField(table, key, depFieldCopy, v)
+			})
+		}
+
 		dependencyNode.AppendFrom(node)
 		node.AppendTo(dependencyNode)
 
@@ -416,6 +1127,10 @@ func (f *Fixture) parseField(table, key, field string, value any, node *Node, re
 			// and more consistent to add it to the recursiveDatabase.
 		}
 
+		if options := f.getTableOptions(depTableName); options != nil && options.DoNotCreateDependencies {
+			return nil, fmt.Errorf("reference to undefined record %s.%s, whose table does not create dependencies", depTableName, depKey)
+		}
+
 		// Add table/key to post-processing.
 		//
 		// The recursive database contains only the tables and records required to resolve
@@ -426,12 +1141,90 @@ func (f *Fixture) parseField(table, key, field string, value any, node *Node, re
 			recursiveDatabase[depTableName] = make(Table)
 		}
 
-		recursiveDatabase[depTableName][depKey] = make(Record)
+		record := make(Record)
+
+		if options := f.getTableOptions(depTableName); options != nil {
+			for k, v := range options.DependencyValues {
+				// Copied, as parsing updates nested values.
+				record[k] = deepCopy(v)
+			}
+		}
+
+		recursiveDatabase[depTableName][depKey] = record
+	}
+
+	if cmdOut.Omit {
+		return omitted, nil
 	}
 
 	return value, nil
 }
 
+// pipeSeparator separates the commands of a pipeline, e.g. "=ulid | =sha256".
+const pipeSeparator = " | ="
+
+// runCommand executes the command line v (starting with "="). If piped,
+// input is passed to the command and also prepended to the command's
+// positional arguments, quoted.
+func (f *Fixture) runCommand(table, key, field, v string, piped bool, input any) (*CommandOutput, error) {
+	cmdName := new(strings.Builder)
+
+	var breakIndex int
+
+	for i := 1; i < len(v); i++ {
+		c := v[i]
+
+		if c == ' ' || c == '\n' || c == '\t' {
+			breakIndex = i
+			break
+		}
+
+		cmdName.WriteByte(c)
+	}
+
+	cmdFunc, ok := f.command(cmdName.String())
+	if !ok {
+		return nil, fmt.Errorf("unknown command: %s", cmdName.String())
+	}
+
+	cmdIn := &CommandInput{
+		Fixture: f,
+		Table:   table,
+		Key:     key,
+		Field:   field,
+	}
+
+	if breakIndex > 0 {
+		cmdIn.Line = v[breakIndex:]
+	}
+
+	if piped {
+		cmdIn.Input = input
+		cmdIn.Piped = true
+		cmdIn.Line = " " + strconv.Quote(pipeString(input)) + cmdIn.Line
+	}
+
+	cmdOut, err := cmdFunc(cmdIn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute command %s: %w", cmdName.String(), err)
+	}
+
+	return cmdOut, nil
+}
+
+func pipeString(v any) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case []byte:
+		return string(t)
+	case fmt.Stringer:
+		return t.String()
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
 func (f *Fixture) ParseTemplate(body []byte) ([]byte, error) {
 	if f.templateBuf == nil {
 		f.templateBuf = new(bytes.Buffer)
@@ -439,28 +1232,156 @@ func (f *Fixture) ParseTemplate(body []byte) ([]byte, error) {
 		f.templateBuf.Reset()
 	}
 
-	fixtureTemplate, err := template.New("fixture").Funcs(funcMap).Parse(string(body))
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse template: %w", err)
+	fixtureTemplate, err := f.parseTemplate(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	if err := fixtureTemplate.Execute(f.templateBuf, f.templateValues()); err != nil {
+		return nil, fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return f.templateBuf.Bytes(), nil
+}
+
+// executeBodyTemplate executes data as a template if the fixture has
+// template data, or returns it unchanged.
+func (f *Fixture) executeBodyTemplate(data []byte) ([]byte, error) {
+	if f.templateValues() == nil {
+		return data, nil
+	}
+
+	return f.ParseTemplate(data)
+}
+
+// parseTableBody decodes the body of a table file, see cachedBody.
+func (f *Fixture) parseTableBody(format int, data []byte) (*decodedBody, error) {
+	data, err := f.executeBodyTemplate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := cachedBody(format, false, data, func() (*decodedBody, error) {
+		return unmarshalTable(format, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if f.Config.DecimalNumbers {
+		setDecimals(body.raw, body.numbers)
+	}
+
+	return body, nil
+}
+
+// parseDatabaseBody is like parseTableBody, but decodes a whole database,
+// whose tables are map[string]any and directives keep their own type.
+func (f *Fixture) parseDatabaseBody(format int, data []byte) (*decodedBody, error) {
+	data, err := f.executeBodyTemplate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := cachedBody(format, true, data, func() (*decodedBody, error) {
+		return unmarshalDatabase(format, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if f.Config.DecimalNumbers {
+		setDecimals(body.raw, body.numbers)
+	}
+
+	return body, nil
+}
+
+func unmarshalTable(format int, data []byte) (*decodedBody, error) {
+	body := &decodedBody{raw: make(map[string]any)}
+
+	if format != yamlFormat {
+		if err := unmarshalBody(format, data, &body.raw); err != nil {
+			return nil, err
+		}
+
+		body.sources = tomlSources(data, true)
+
+		return body, nil
+	}
+
+	var document yaml.Node
+
+	if err := unmarshalBody(format, data, &document); err != nil {
+		return nil, err
+	}
+
+	if len(document.Content) == 0 {
+		// Empty file.
+		return body, nil
+	}
+
+	if err := document.Decode(&body.raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal yaml: %w", err)
+	}
+
+	body.sources = make(map[[2]string]*recordSource)
+	yamlTableSources(body.sources, "", document.Content[0])
+	body.numbers = yamlNumbers(nil, document.Content[0])
+
+	return body, nil
+}
+
+func unmarshalDatabase(format int, data []byte) (*decodedBody, error) {
+	if format != yamlFormat {
+		raw := make(map[string]any)
+
+		if err := unmarshalBody(format, data, &raw); err != nil {
+			return nil, err
+		}
+
+		return &decodedBody{raw: raw, sources: tomlSources(data, false)}, nil
+	}
+
+	// Decoding YAML into map[string]any would keep non-string record
+	// keys (e.g. 1: ...) as map[any]any, so tables are decoded one by one.
+	var nodes map[string]yaml.Node
+
+	if err := unmarshalBody(format, data, &nodes); err != nil {
+		return nil, err
 	}
 
-	if err := fixtureTemplate.Execute(f.templateBuf, f.TemplateData); err != nil {
-		return nil, fmt.Errorf("failed to execute template: %w", err)
+	body := &decodedBody{
+		raw:     make(map[string]any, len(nodes)),
+		sources: make(map[[2]string]*recordSource),
 	}
 
-	return f.templateBuf.Bytes(), nil
-}
+	for name := range nodes {
+		node := nodes[name]
 
-func (f *Fixture) parseBody(format int, data []byte, v any) error {
-	if f.TemplateData != nil {
-		var err error
+		var v any
 
-		data, err = f.ParseTemplate(data)
-		if err != nil {
-			return err
+		if node.Kind == yaml.MappingNode {
+			table := make(map[string]any)
+
+			if err := node.Decode(&table); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal yaml table %s: %w", name, err)
+			}
+
+			yamlTableSources(body.sources, name, &node)
+			body.numbers = append(body.numbers, yamlNumbers([]string{name}, &node)...)
+			v = table
+		} else if err := node.Decode(&v); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal yaml %s: %w", name, err)
 		}
+
+		body.raw[name] = v
 	}
 
+	return body, nil
+}
+
+func unmarshalBody(format int, data []byte, v any) error {
 	switch format {
 	case tomlFormat:
 		if err := toml.Unmarshal(data, v); err != nil {
@@ -478,31 +1399,72 @@ func (f *Fixture) parseBody(format int, data []byte, v any) error {
 	return nil
 }
 
-func (f *Fixture) handleTableFile(format int, name string, body []byte, recursiveDatabase Database) error {
-	table := make(Table)
-
-	if err := f.parseBody(format, body, &table); err != nil {
+// handleTableFile loads the records of a table file. file is its path, used
+// in the errors of its records.
+func (f *Fixture) handleTableFile(format int, name string, body []byte, file string) error {
+	decoded, err := f.parseTableBody(format, body)
+	if err != nil {
 		return fmt.Errorf("failed to unmarshal Table: %w", err)
 	}
 
-	if err := f.parseTable(name, table, recursiveDatabase); err != nil {
-		return fmt.Errorf("failed to parse table %s: %w", name, err)
+	raw := decoded.raw
+
+	options, err := decodeFileOptions(raw)
+	if err != nil {
+		return err
+	}
+
+	table, ok, err := f.decodeTable(name, raw)
+	if err != nil || !ok {
+		return err
+	}
+
+	database := options.apply(f, Database{name: table})
+	name = options.tableName(name)
+
+	f.setRecordSources(file, name, "", table, decoded)
+
+	for k := range table {
+		f.setRecordLayer(name, k)
 	}
 
-	f.Database[name] = table
+	f.mergeDatabase(database)
 
 	return nil
 }
 
 func (f *Fixture) handleDatabase(database Database) error {
+	if err := f.evaluateKeys(database); err != nil {
+		return err
+	}
+
+	var errs []error
+
+	// Exclude records before parsing any, so references to them fail.
+	for _, name := range sortedKeys(database) {
+		if err := f.applyRecordDirectives(name, database[name]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
 	recursiveDatabase := make(Database)
 
-	for name, table := range database {
-		if err := f.parseTable(name, table, recursiveDatabase); err != nil {
-			return fmt.Errorf("failed to parse table %s: %w", name, err)
+	// Every table is parsed, so the errors of all the records are reported
+	// at once.
+	for _, name := range sortedKeys(database) {
+		if err := f.parseTable(name, database[name], recursiveDatabase); err != nil {
+			errs = append(errs, err)
 		}
 	}
 
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
 	if len(recursiveDatabase) > 0 {
 		if err := f.handleDatabase(recursiveDatabase); err != nil {
 			return err
@@ -528,65 +1490,233 @@ func (f *Fixture) handleDatabase(database Database) error {
 	return nil
 }
 
-func (f *Fixture) handleDatabaseFile(format int, body []byte) error {
-	if err := f.parseBody(format, body, &f.Database); err != nil {
-		return fmt.Errorf("failed to unmarshal Database: %w", err)
+// evaluateKeys replaces the record keys starting with "=" (e.g. "=ulid") by
+// the value of the command, before any record is parsed. Other records keep
+// referencing them by the original key, e.g. =ref sessions "=ulid".
+func (f *Fixture) evaluateKeys(database Database) error {
+	for _, table := range sortedKeys(database) {
+		for _, key := range sortedKeys(database[table]) {
+			if !strings.HasPrefix(key, "=") {
+				continue
+			}
+
+			cmdOut, err := f.runCommand(table, key, "", key, false, nil)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate key %s.%s: %w", table, key, err)
+			}
+
+			if cmdOut.Omit || cmdOut.Resolve != nil || len(cmdOut.Dependencies) > 0 {
+				return fmt.Errorf("key %s.%s: command output cannot be used as a key", table, key)
+			}
+
+			generated := pipeString(cmdOut.Value)
+
+			if _, ok := database[table][generated]; ok {
+				return fmt.Errorf("key %s.%s: generated key %s already exists", table, key, generated)
+			}
+
+			database[table][generated] = database[table][key]
+			delete(database[table], key)
+
+			if source, ok := f.recordSources[[2]string{table, key}]; ok {
+				f.recordSources[[2]string{table, generated}] = source
+			}
+
+			f.keys[[2]string{table, key}] = generated
+		}
+	}
+
+	return nil
+}
+
+// resolveKey returns the key generated for a record keyed by a command,
+// or key itself.
+func (f *Fixture) resolveKey(table, key string) string {
+	if generated, ok := f.keys[[2]string{table, key}]; ok {
+		return generated
 	}
 
-	return f.handleDatabase(f.Database)
+	return key
 }
 
-func (f *Fixture) handleFiles() error {
-	if f.Body != nil {
-		b, err := io.ReadAll(f.Body)
+// handleDatabaseFile loads the records of a fixture file. file is its path,
+// used in the errors of its records, and dir the directory of src its
+// includes are relative to.
+func (f *Fixture) handleDatabaseFile(src *fileSource, format int, body []byte, file, dir string, includeStack []string) error {
+	decoded, err := f.parseDatabaseBody(format, body)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal Database: %w", err)
+	}
+
+	raw := decoded.raw
+
+	if requires, ok := raw[requiresDirective]; ok {
+		paths, err := directivePaths(requiresDirective, requires)
 		if err != nil {
-			return fmt.Errorf("failed to read fixture body: %w", err)
+			return err
 		}
 
-		format, err := bodyFormat(f.BodyFormat)
+		for _, path := range paths {
+			path = src.includePath(dir, path)
+
+			if err := f.requirePath(src, path, includeStack); err != nil {
+				return fmt.Errorf("failed to require %s: %w", path, err)
+			}
+		}
+	}
+
+	if include, ok := raw[includeDirective]; ok {
+		paths, err := directivePaths(includeDirective, include)
 		if err != nil {
 			return err
 		}
 
-		return f.handleDatabaseFile(format, b)
+		// Included files are loaded first, so the including file
+		// overrides their records.
+		for _, path := range paths {
+			path = src.includePath(dir, path)
+
+			if err := f.loadPath(src, path, includeStack); err != nil {
+				return fmt.Errorf("failed to include %s: %w", path, err)
+			}
+		}
+	}
+
+	options, err := decodeFileOptions(raw)
+	if err != nil {
+		return err
+	}
+
+	database, err := f.decodeDatabase(raw)
+	if err != nil {
+		return err
+	}
+
+	for name, table := range database {
+		f.setRecordSources(file, options.tableName(name), name, table, decoded)
 	}
 
-	if f.File == "" {
-		if len(f.Database) > 0 {
-			// Apply the pre-defined database.
-			return f.handleDatabase(f.Database)
+	f.mergeDatabase(options.apply(f, database))
+
+	return nil
+}
+
+// mergeDatabase adds the tables and records of database to f.Database.
+// Records with the same key are replaced.
+func (f *Fixture) mergeDatabase(database Database) {
+	for name, table := range database {
+		t, ok := f.Database[name]
+		if !ok {
+			f.Database[name] = table
+			continue
 		}
 
-		return errors.New("missing fixture body or file")
+		for k := range table {
+			t[k] = table[k]
+		}
+	}
+}
+
+// requirePath loads a fixture file or directory with loadPath, unless it was
+// already loaded.
+func (f *Fixture) requirePath(src *fileSource, path string, includeStack []string) error {
+	id, err := f.loadedID(src, path)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	if f.loaded[id] {
+		return nil
+	}
+
+	return f.loadPath(src, path, includeStack)
+}
+
+// loadedID returns the identifier of a path of src, or of a remote path, see
+// requirePath.
+func (f *Fixture) loadedID(src *fileSource, path string) (string, error) {
+	if isRemotePath(path) {
+		src, path = f.remoteSource(path)
+	}
+
+	return src.id(path)
+}
+
+// loadPath loads a fixture file or a directory of table files of src into
+// f.Database. includeStack holds the files being included, to detect cycles.
+func (f *Fixture) loadPath(src *fileSource, path string, includeStack []string) error {
+	if isRemotePath(path) {
+		src, path = f.remoteSource(path)
+	}
+
+	id, err := src.id(path)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
 	}
 
-	file := filepath.Join(f.Dir, f.File)
+	f.loaded[id] = true
+
+	fsys := src.fsys
 
-	stat, err := os.Stat(file)
+	stat, err := fs.Stat(fsys, path)
 	if err != nil {
 		return fmt.Errorf("failed to stat file: %w", err)
 	}
 
 	if !stat.IsDir() {
-		b, err := os.ReadFile(file)
+		for _, included := range includeStack {
+			if included == id {
+				return fmt.Errorf("circular include of %s", path)
+			}
+		}
+
+		b, err := fs.ReadFile(fsys, path)
 		if err != nil {
 			return fmt.Errorf("failed to read fixture file: %w", err)
 		}
 
-		format, err := bodyFormat(filepath.Ext(file))
+		if isArchivePath(path) {
+			return f.loadArchive(path, id, b, append(includeStack, id))
+		}
+
+		format, err := bodyFormat(filepath.Ext(path))
 		if err != nil {
 			return err
 		}
 
-		return f.handleDatabaseFile(format, b)
+		return f.handleDatabaseFile(src, format, b, src.file(path), src.dir(path), append(includeStack, id))
 	}
 
-	dirEntries, err := os.ReadDir(file)
+	return f.loadTableDir(src, path, "")
+}
+
+// loadTableDir loads the table files of a directory, prefixing their table
+// names with prefix. Subdirectories are loaded according to
+// Config.SubdirectoryMode.
+//
+// With Config.OrderPrefixes, files named with a numeric prefix, e.g.
+// "01_users.yaml", hold the records of the table without the prefix ("users")
+// and are written in the order of their prefixes.
+func (f *Fixture) loadTableDir(src *fileSource, path, prefix string) error {
+	dirEntries, err := fs.ReadDir(src.fsys, path)
 	if err != nil {
 		return fmt.Errorf("failed to read fixture directory: %w", err)
 	}
 
-	recursiveDatabase := make(Database)
+	parentLayer := f.layer
+	dirLayer := f.layer
+
+	if dirLayer == nil && f.Config.SubdirectoryMode == SubdirectoryGroup {
+		// Every record of a group directory is layered, so groups are
+		// written after the files of the root directory.
+		dirLayer = []int{}
+	}
+
+	var groups int
+
+	defer func() {
+		f.layer = parentLayer
+	}()
 
 	for i := range dirEntries {
 		dirEntry := dirEntries[i]
@@ -594,6 +1724,23 @@ func (f *Fixture) handleFiles() error {
 		ext := filepath.Ext(name)
 
 		if dirEntry.IsDir() {
+			switch f.Config.SubdirectoryMode {
+			case SubdirectorySchema:
+				f.layer = dirLayer
+
+				if err := f.loadTableDir(src, src.join(path, name), prefix+name+"."); err != nil {
+					return err
+				}
+			case SubdirectoryGroup:
+				// Groups are ordered by name, after the files of their parent.
+				groups++
+				f.layer = appendLayer(dirLayer, 1, groups)
+
+				if err := f.loadTableDir(src, src.join(path, name), prefix); err != nil {
+					return err
+				}
+			}
+
 			continue
 		}
 
@@ -602,29 +1749,201 @@ func (f *Fixture) handleFiles() error {
 			continue
 		}
 
-		b, err := os.ReadFile(filepath.Join(file, name))
+		b, err := fs.ReadFile(src.fsys, src.join(path, name))
 		if err != nil {
 			return fmt.Errorf("failed to read fixture file: %w", err)
 		}
 
-		if err := f.handleTableFile(format, strings.TrimSuffix(name, ext), b, recursiveDatabase); err != nil {
+		tableName := strings.TrimSuffix(name, ext)
+		f.layer = dirLayer
+
+		if n, rest, ok := cutOrderPrefix(tableName); ok && f.Config.OrderPrefixes {
+			// Prefixed files are written after the other files of their
+			// directory, and before its groups.
+			tableName = rest
+			f.layer = appendLayer(dirLayer, 0, n)
+		}
+
+		if err := f.handleTableFile(format, prefix+tableName, b, src.file(src.join(path, name))); err != nil {
 			return err
 		}
 	}
 
-	if len(recursiveDatabase) > 0 {
-		return f.handleDatabase(recursiveDatabase)
+	return nil
+}
+
+// loadOverlay loads a fixture file or directory and deep merges its records
+// over f.Database.
+func (f *Fixture) loadOverlay(src *fileSource, path string) error {
+	base := f.Database
+	f.Database = make(Database)
+
+	err := f.loadPath(src, path, nil)
+	overlay := f.Database
+	f.Database = base
+
+	if err != nil {
+		return err
+	}
+
+	for name, table := range overlay {
+		baseTable, ok := base[name]
+		if !ok {
+			base[name] = table
+			continue
+		}
+
+		for key, record := range table {
+			if baseRecord, ok := baseTable[key]; ok {
+				deepMerge(baseRecord, record)
+			} else {
+				baseTable[key] = record
+			}
+		}
+	}
+
+	return nil
+}
+
+// handleFiles loads the fixture files into f.Database, without parsing
+// their records.
+func (f *Fixture) handleFiles() error {
+	for _, path := range f.IncludeFiles {
+		if err := f.loadPath(f.source(), f.fixturePath(path), nil); err != nil {
+			return fmt.Errorf("failed to include %s: %w", path, err)
+		}
+	}
+
+	if f.Suite != "" {
+		suite, err := f.Config.GetSuite(f.Suite)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range suite.Files {
+			if err := f.loadPath(f.source(), f.fixturePath(path), nil); err != nil {
+				return fmt.Errorf("failed to load suite %s file %s: %w", f.Suite, path, err)
+			}
+		}
+	}
+
+	switch {
+	case f.Body != nil:
+		if f.Body != f.bodyReader {
+			// Read once, so the fixture can be applied again after Reset.
+			b, err := io.ReadAll(f.Body)
+			if err != nil {
+				return fmt.Errorf("failed to read fixture body: %w", err)
+			}
+
+			f.body = b
+			f.bodyReader = f.Body
+		}
+
+		b := f.body
+
+		format, err := bodyFormat(f.BodyFormat)
+		if err != nil {
+			return err
+		}
+
+		if err := f.handleDatabaseFile(f.source(), format, b, "", f.Dir, nil); err != nil {
+			return err
+		}
+	case f.File != "":
+		if err := f.loadPath(f.source(), f.fixturePath(f.File), nil); err != nil {
+			return err
+		}
+	case len(f.Database) == 0 && len(f.Files) == 0 && f.Suite == "":
+		return errors.New("missing fixture body or file")
+	}
+
+	// Every table is loaded before parsing them, so commands can see
+	// records defined in any of the files.
+	for _, path := range f.Files {
+		if err := f.loadPath(f.source(), f.fixturePath(path), nil); err != nil {
+			return fmt.Errorf("failed to load %s: %w", path, err)
+		}
+	}
+
+	for _, path := range f.Overlays {
+		if err := f.loadOverlay(f.source(), f.fixturePath(path)); err != nil {
+			return fmt.Errorf("failed to load overlay %s: %w", path, err)
+		}
 	}
 
 	return nil
 }
 
+// referenceOf returns the table and key referenced by the given field, based
+// on its unresolved value: either a =ref command or a plain key of a
+// configured reference.
+func (f *Fixture) referenceOf(table, key, field string) (string, string, error) {
+	key = f.resolveKey(table, key)
+
+	record, ok := f.Database[table][key]
+	if !ok {
+		return "", "", fmt.Errorf("%s.%s: %w", table, key, ErrRecordNotFound)
+	}
+
+	value, ok := record[field]
+	if !ok {
+		if tableOptions := f.getTableOptions(table); tableOptions != nil {
+			value, ok = tableOptions.DefaultValues[field]
+		}
+	}
+
+	if !ok {
+		return "", "", fmt.Errorf("%s.%s.%s: %w", table, key, field, ErrFieldNotFound)
+	}
+
+	if v, ok := value.(string); ok && strings.HasPrefix(v, "=ref ") {
+		in := &CommandInput{Line: v[len("=ref "):]}
+
+		args, _, err := in.ScanLine()
+		if err != nil {
+			return "", "", err
+		}
+
+		if len(args) < 2 {
+			return "", "", fmt.Errorf("%s.%s.%s: invalid reference %q", table, key, field, v)
+		}
+
+		refKey := args[1]
+
+		if refKey == "#" {
+			refKey = key
+		}
+
+		return args[0], refKey, nil
+	}
+
+	refTable, _ := f.Config.getReference(table, field, f.Database)
+
+	if refTable == "" {
+		return "", "", fmt.Errorf("%s.%s.%s: %w", table, key, field, errNotReference)
+	}
+
+	return refTable, fmt.Sprint(value), nil
+}
+
+var errNotReference = errors.New("field is not a reference")
+
 var ErrDatabaseNotFound = errors.New("database not found")
 var ErrTableNotFound = errors.New("table not found")
 var ErrRecordNotFound = errors.New("record not found")
 var ErrFieldNotFound = errors.New("field not found")
 
+// GetField returns the value of a record field. It is safe to call
+// concurrently with the other accessors and with Apply, see Apply.
 func (f *Fixture) GetField(table, key, field string) (any, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.view().getField(table, key, field)
+}
+
+func (f *Fixture) getField(table, key, field string) (any, error) {
 	if f.Database == nil {
 		return nil, ErrDatabaseNotFound
 	}
@@ -647,7 +1966,107 @@ func (f *Fixture) GetField(table, key, field string) (any, error) {
 	return value, nil
 }
 
+// Export returns a deep copy of the Database, whose values are resolved
+// once the fixture is applied. Unlike Database, it can be freely mutated or
+// marshaled without affecting the fixture.
+func (f *Fixture) Export() Database {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return copyDatabase(f.view().Database)
+}
+
+// Reset clears the applied state of the fixture, including its records graph
+// and generated values, so it can be applied again, e.g. against a fresh
+// transaction per test. The Database is restored to its state before the
+// first Apply, and Body is not read again.
+func (f *Fixture) Reset() {
+	f.applyMu.Lock()
+	defer f.applyMu.Unlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.published = nil
+	f.applied = false
+	f.Database = copyDatabase(f.initialDatabase)
+	f.nodeIDs = nil
+	f.nodes = nil
+	f.nodesByKey = nil
+	f.nodeSeq = 0
+	f.touchedNodes = nil
+	f.sequences = nil
+	f.keys = nil
+	f.excluded = nil
+	f.tableOptions = nil
+	f.recordOptions = nil
+	f.filtered = nil
+	f.loaded = nil
+	f.recordLayers = nil
+	f.recordSources = nil
+	f.columns = nil
+	f.layer = nil
+	f.snowflakes = nil
+	f.rand = nil
+	f.ulidEntropy = nil
+}
+
+// GetFieldPath is like GetField, but path can also be a dot separated path
+// into nested values (maps and lists) of the field, e.g. "profile.settings.theme"
+// or "tags.0". A field whose name contains dots takes precedence.
+func (f *Fixture) GetFieldPath(table, key, path string) (any, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.view().getFieldPath(table, key, path)
+}
+
+func (f *Fixture) getFieldPath(table, key, path string) (any, error) {
+	value, err := f.getField(table, key, path)
+	if !errors.Is(err, ErrFieldNotFound) {
+		return value, err
+	}
+
+	parts := strings.Split(path, ".")
+
+	value, err = f.getField(table, key, parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 1; i < len(parts); i++ {
+		var ok bool
+
+		switch t := value.(type) {
+		case map[string]any:
+			value, ok = t[parts[i]]
+		case Record:
+			value, ok = t[parts[i]]
+		case []any:
+			j, err := strconv.Atoi(parts[i])
+			if err == nil && j >= 0 && j < len(t) {
+				value, ok = t[j], true
+			}
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", strings.Join(parts[:i+1], "."), ErrFieldNotFound)
+		}
+	}
+
+	return value, nil
+}
+
+// SetField sets the value of a record field, creating the table and record
+// if needed. See GetField.
 func (f *Fixture) SetField(table, key, field string, value any) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.view().setField(table, key, field, value)
+}
+
+func (f *Fixture) setField(table, key, field string, value any) error {
 	if f.Database == nil {
 		return ErrDatabaseNotFound
 	}
@@ -675,17 +2094,9 @@ func (f *Fixture) Node(id int64) graph.Node {
 	return f.nodeIDs[id]
 }
 
+// Nodes returns the nodes of the graph, in the order they were created.
 func (f *Fixture) Nodes() graph.Nodes {
-	l := make([]*Node, len(f.nodeIDs))
-
-	var i int
-
-	for _, v := range f.nodeIDs {
-		l[i] = v
-		i++
-	}
-
-	return &Nodes{l: l}
+	return &Nodes{l: f.nodes}
 }
 
 func (f *Fixture) From(id int64) graph.Nodes {
@@ -705,21 +2116,20 @@ func (f *Fixture) edgeBetween(uid, vid int64) (bool, bool) {
 		return false, false
 	}
 
-	for i := range na.from {
-		if na.from[i].ID() == vid {
-			return true, true
-		}
+	// Edges go from dependencies to the nodes depending on them.
+	if na.hasFrom(vid) {
+		return true, true
 	}
 
-	for i := range nb.to {
-		if nb.to[i].ID() == uid {
-			return true, false
-		}
+	if nb.hasFrom(uid) {
+		return true, false
 	}
 
 	return false, false
 }
 
+// HasEdgeBetween returns whether an edge exists between the nodes, in either
+// direction, as graph.Graph requires.
 func (f *Fixture) HasEdgeBetween(xid, yid int64) bool {
 	has, _ := f.edgeBetween(xid, yid)
 	return has
@@ -775,6 +2185,7 @@ func (f *Fixture) GetNode(label [2]string) *Node {
 	}
 
 	f.nodeIDs[f.nodeSeq] = n
+	f.nodes = append(f.nodes, n)
 	f.nodesByKey[label] = n
 
 	return n
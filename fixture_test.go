@@ -1,17 +1,25 @@
 package fixture
 
 import (
+	"bytes"
 	"context"
+	"embed"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 	"sync"
 	"testing"
+	"testing/fstest"
+	"text/template"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
 )
 
 var pgxPool *pgxpool.Pool
@@ -79,6 +87,86 @@ func getPgxPool(t *testing.T) *pgxpool.Pool {
 	return pgxPool
 }
 
+// memoryWriter is a Writer that keeps inserted records in memory, assigning
+// a sequential id to records that don't have one.
+type memoryWriter struct {
+	inserted []string
+	records  map[string][]Record
+	ids      map[string]int64
+
+	// Results returned by Query, keyed by sql.
+	queries map[string][]Record
+	args    [][]any
+}
+
+func (w *memoryWriter) Insert(f *Fixture, table string, key string, record Record) error {
+	if w.records == nil {
+		w.records = make(map[string][]Record)
+	}
+
+	if w.ids == nil {
+		w.ids = make(map[string]int64)
+	}
+
+	if v := f.Config.TableAlias(table); v != "" {
+		table = v
+	}
+
+	if _, ok := record["id"]; !ok {
+		w.ids[table]++
+		record["id"] = w.ids[table]
+	}
+
+	written := make(Record, len(record))
+
+	for k, v := range record {
+		written[k] = v
+	}
+
+	w.inserted = append(w.inserted, table+"."+key)
+	w.records[table] = append(w.records[table], written)
+
+	return nil
+}
+
+func (w *memoryWriter) Update(f *Fixture, table string, key string, record Record) error {
+	return nil
+}
+
+func (w *memoryWriter) Find(f *Fixture, table string, where Record) (Record, error) {
+	if v := f.Config.TableAlias(table); v != "" {
+		table = v
+	}
+
+	for _, record := range w.records[table] {
+		matches := true
+
+		for k, v := range where {
+			if fmt.Sprint(record[k]) != fmt.Sprint(v) {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			return record, nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+func (w *memoryWriter) Query(f *Fixture, sql string, args ...any) ([]Record, error) {
+	w.args = append(w.args, args)
+
+	rows, ok := w.queries[sql]
+	if !ok {
+		return nil, fmt.Errorf("unexpected query: %s", sql)
+	}
+
+	return rows, nil
+}
+
 func TestMain(m *testing.M) {
 	var logLevel zerolog.Level
 
@@ -399,4 +487,2139 @@ func TestFixtureApply(t *testing.T) {
 			}
 		})
 	}
+
+	// The SQL of the optional writer interfaces, and of the features using
+	// them, runs against the same database.
+	if _, err := conn.Exec(
+		context.Background(),
+		`
+		DO $$ BEGIN
+			CREATE TYPE iota_status AS ENUM ('draft', 'published');
+		EXCEPTION WHEN duplicate_object THEN NULL;
+		END $$;
+
+		CREATE TABLE IF NOT EXISTS iota (
+			id       bigserial,
+			alpha_id bigint REFERENCES alpha (id),
+			slug     text NOT NULL UNIQUE,
+			status   iota_status NOT NULL DEFAULT 'draft',
+			tags     uuid[],
+
+			PRIMARY KEY (id)
+		);
+		`,
+	); err != nil {
+		t.Fatalf("failed to create schema: %s", err)
+	}
+
+	t.Run("schema", func(st *testing.T) {
+		f := &Fixture{
+			Config:  &Config{},
+			Writer:  postgresWriter,
+			Context: context.Background(),
+			Logger:  &defaultLogger,
+		}
+
+		columns, err := postgresWriter.Columns(f, "iota")
+		assert.NoError(st, err)
+		assert.Contains(st, columns, Column{Name: "status", Type: "iota_status", Values: []string{"draft", "published"}})
+		assert.Contains(st, columns, Column{Name: "tags", Type: "uuid[]"})
+
+		_, err = postgresWriter.Columns(f, "missing")
+		assert.ErrorIs(st, err, ErrTableNotFound)
+
+		foreignKeys, err := postgresWriter.ForeignKeys(f)
+		assert.NoError(st, err)
+		assert.Contains(st, foreignKeys, ForeignKey{Table: "iota", Column: "alpha_id", RefTable: "alpha", RefColumn: "id"})
+		assert.Contains(st, foreignKeys, ForeignKey{Table: "eta", Column: "the_zeta_id", RefTable: "zeta", RefColumn: "zeta_id"})
+	})
+
+	t.Run("lookup", func(st *testing.T) {
+		slug := "iota-" + uuid.NewString()
+
+		newFixture := func() *Fixture {
+			return &Fixture{
+				Config: &Config{
+					ValidateSchema: true,
+					CoerceValues:   true,
+					TableOptions: map[string]*TableOptions{
+						"iota": {LookupFields: []string{"slug"}},
+					},
+				},
+				Writer: postgresWriter,
+				Database: Database{
+					"alpha": {"1": {"text_field": "iota alpha"}},
+					"iota": {
+						"1": {
+							"alpha_id": "=ref alpha 1",
+							"slug":     slug,
+							"status":   "published",
+						},
+					},
+				},
+			}
+		}
+
+		first := newFixture()
+		first.MustApply(st)
+
+		// Found by its slug instead of inserted again.
+		second := newFixture()
+		second.MustApply(st)
+
+		assert.Equal(st, first.Database["iota"]["1"]["id"], second.Database["iota"]["1"]["id"])
+
+		found, err := postgresWriter.Find(first, "iota", Record{"slug": slug})
+		assert.NoError(st, err)
+		assert.Equal(st, first.Database["iota"]["1"]["id"], found["id"])
+
+		_, err = postgresWriter.Find(first, "iota", Record{"slug": "missing"})
+		assert.ErrorIs(st, err, ErrRecordNotFound)
+
+		diff, err := first.Diff()
+		assert.NoError(st, err)
+		assert.Empty(st, diff.Missing)
+		assert.Empty(st, diff.Changed)
+	})
+
+	t.Run("snapshot", func(st *testing.T) {
+		database, err := Snapshot(context.Background(), conn, "alpha", "iota")
+		assert.NoError(st, err)
+		assert.NotEmpty(st, database["alpha"])
+		assert.NotEmpty(st, database["iota"])
+
+		for key, record := range database["iota"] {
+			if record["alpha_id"] != nil {
+				assert.Regexp(st, "^=ref alpha [0-9]+$", record["alpha_id"], key)
+			}
+		}
+
+		database, err = Snapshot(context.Background(), conn)
+		assert.NoError(st, err)
+		assert.Contains(st, database, "iota")
+	})
+}
+
+func TestFixtureOmit(t *testing.T) {
+	f := &Fixture{
+		Config: &Config{
+			TableOptions: map[string]*TableOptions{
+				"users": {
+					DefaultValues: Record{
+						"role": "member",
+					},
+				},
+			},
+		},
+		Writer: &memoryWriter{},
+		Database: Database{
+			"users": {
+				"1": {
+					"name": "alice",
+				},
+				"2": {
+					"name": "bob",
+					"role": "=omit",
+					"settings": map[string]any{
+						"theme": "dark",
+						"beta":  "=omit",
+					},
+				},
+			},
+		},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, Record{"id": f.Database["users"]["1"]["id"], "name": "alice", "role": "member"}, f.Database["users"]["1"])
+	assert.Equal(t, Record{"id": f.Database["users"]["2"]["id"], "name": "bob", "settings": map[string]any{"theme": "dark"}}, f.Database["users"]["2"])
+}
+
+func TestFixtureSQL(t *testing.T) {
+	writer := &memoryWriter{
+		queries: map[string][]Record{
+			"SELECT id FROM plans WHERE code = $1": {{"id": int64(42)}},
+		},
+	}
+
+	f := &Fixture{
+		Writer: writer,
+		Database: Database{
+			"subscriptions": {
+				"1": {
+					"plan_id": `=sql "SELECT id FROM plans WHERE code = $1" pro`,
+				},
+			},
+		},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, int64(42), f.Database["subscriptions"]["1"]["plan_id"])
+	assert.Equal(t, [][]any{{"pro"}}, writer.args)
+}
+
+func TestFixtureLookup(t *testing.T) {
+	writer := &memoryWriter{
+		records: map[string][]Record{
+			"users": {
+				{"id": int64(7), "email": "admin@example.com", "name": "admin"},
+			},
+		},
+	}
+
+	f := &Fixture{
+		Writer: writer,
+		Database: Database{
+			"posts": {
+				"1": {
+					"author_id":   `=lookup users email="admin@example.com"`,
+					"author_name": `=lookup users email="admin@example.com" name`,
+				},
+			},
+		},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, int64(7), f.Database["posts"]["1"]["author_id"])
+	assert.Equal(t, "admin", f.Database["posts"]["1"]["author_name"])
+
+	f = &Fixture{
+		Writer: writer,
+		Database: Database{
+			"posts": {
+				"2": {
+					"author_id": `=lookup users email="nobody@example.com"`,
+				},
+			},
+		},
+	}
+
+	assert.ErrorIs(t, f.Apply(), ErrRecordNotFound)
+}
+
+func TestFixtureDir(t *testing.T) {
+	for _, dir := range []string{"fixtures/ok-yaml", "fixtures/ok-toml"} {
+		t.Run(dir, func(st *testing.T) {
+			writer := &memoryWriter{}
+			f := &Fixture{
+				Writer:                  writer,
+				File:                    dir,
+				DoNotCreateDependencies: true,
+			}
+
+			if err := f.Apply(); err != nil {
+				st.Fatalf("failed to Apply: %s", err)
+			}
+
+			assert.Equal(st, f.Database["alpha"]["1"]["id"], f.Database["beta"]["1"]["alpha_id"])
+			assert.Equal(st, f.Database["beta"]["1"]["id"], f.Database["gamma"]["1"]["beta_id"])
+			assert.Equal(st, f.Database["gamma"]["1"]["id"], f.Database["delta"]["1"]["gamma_id"])
+
+			position := make(map[string]int)
+
+			for i, label := range writer.inserted {
+				position[label] = i
+			}
+
+			assert.Less(st, position["alpha.1"], position["beta.1"])
+			assert.Less(st, position["beta.1"], position["gamma.1"])
+			assert.Less(st, position["gamma.1"], position["delta.1"])
+		})
+	}
+}
+
+func TestFixtureTableDoNotCreateDependencies(t *testing.T) {
+	config := &Config{
+		TableOptions: map[string]*TableOptions{
+			"accounts": {DoNotCreateDependencies: true},
+		},
+	}
+
+	f := &Fixture{
+		Config:     config,
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("posts:\n  p1:\n    user_id: =ref users alice\n"),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Contains(t, f.Database["users"], "alice")
+
+	f = &Fixture{
+		Config:     config,
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("users:\n  alice:\n    account_id: =ref accounts main\n"),
+		BodyFormat: ".yaml",
+	}
+
+	assert.EqualError(t, f.Apply(), "table users, key alice, field account_id: reference to undefined record accounts.main, whose table does not create dependencies")
+}
+
+func TestFixtureDependencyValues(t *testing.T) {
+	f := &Fixture{
+		Config: &Config{
+			TableOptions: map[string]*TableOptions{
+				"users": {
+					DefaultValues:    Record{"role": "member", "active": true},
+					DependencyValues: Record{"name": "=key", "role": "guest"},
+				},
+			},
+		},
+		Writer: &memoryWriter{},
+		Body: strings.NewReader(`users:
+  alice: {}
+posts:
+  p1:
+    user_id: =ref users bob
+`),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, Record{"id": int64(1), "role": "member", "active": true}, f.Database["users"]["alice"])
+	assert.Equal(t, Record{"id": int64(2), "name": "bob", "role": "guest", "active": true}, f.Database["users"]["bob"])
+}
+
+func TestFixtureDefaultValueFunc(t *testing.T) {
+	f := &Fixture{
+		Config: &Config{
+			TableOptions: map[string]*TableOptions{
+				"users": {
+					DefaultValues: Record{
+						"email": DefaultValueFunc(func(ctx context.Context, table, key string, record Record) (any, error) {
+							return fmt.Sprintf("%s@%s.test", record["name"], table), nil
+						}),
+						"slug": func(ctx context.Context, table, key string, record Record) (any, error) {
+							return "=key", nil
+						},
+					},
+				},
+			},
+		},
+		Writer: &memoryWriter{},
+		Body: strings.NewReader(`users:
+  alice:
+    name: =key
+  bob:
+    name: Bob
+    email: bob@example.com
+`),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, "alice@users.test", f.Database["users"]["alice"]["email"])
+	assert.Equal(t, "alice", f.Database["users"]["alice"]["slug"])
+	assert.Equal(t, "bob@example.com", f.Database["users"]["bob"]["email"])
+}
+
+func TestFixtureOmitFields(t *testing.T) {
+	writer := &memoryWriter{}
+
+	f := &Fixture{
+		Config: &Config{
+			TableOptions: map[string]*TableOptions{
+				"users": {OmitFields: []string{"full_name"}},
+			},
+		},
+		Writer: writer,
+		Body: strings.NewReader(`_options:
+  tables:
+    posts:
+      omit: [search_vector, word_count]
+users:
+  alice:
+    name: Alice
+    full_name: Alice Smith
+posts:
+  p1:
+    title: Hello
+    search_vector: "'hello':1"
+    word_count: 1
+`),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, []Record{{"id": int64(1), "name": "Alice"}}, writer.records["users"])
+	assert.Equal(t, []Record{{"id": int64(1), "title": "Hello"}}, writer.records["posts"])
+}
+
+func TestFixtureFieldAliases(t *testing.T) {
+	writer := &memoryWriter{}
+
+	f := &Fixture{
+		Config: &Config{
+			TableOptions: map[string]*TableOptions{
+				"users": {FieldAliases: map[string]string{"email": "email_address"}},
+			},
+		},
+		Writer: writer,
+		Body: strings.NewReader(`_options:
+  tables:
+    posts:
+      field_aliases: {author_email: user_email}
+users:
+  alice:
+    email: alice@example.com
+posts:
+  p1:
+    author_email: =ref users alice email
+`),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, []Record{{"id": int64(1), "email_address": "alice@example.com"}}, writer.records["users"])
+	assert.Equal(t, []Record{{"id": int64(1), "user_email": "alice@example.com"}}, writer.records["posts"])
+	assert.Equal(t, Record{"id": int64(1), "email": "alice@example.com"}, f.Database["users"]["alice"])
+}
+
+func TestFixtureColumnName(t *testing.T) {
+	writer := &memoryWriter{}
+
+	f := &Fixture{
+		Config: &Config{
+			ColumnName: SnakeCase,
+			TableOptions: map[string]*TableOptions{
+				"users": {FieldAliases: map[string]string{"emailAddress": "email"}},
+			},
+		},
+		Writer: writer,
+		Body: strings.NewReader(`users:
+  alice:
+    firstName: Alice
+    emailAddress: alice@example.com
+`),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, []Record{{"id": int64(1), "first_name": "Alice", "email": "alice@example.com"}}, writer.records["users"])
+	assert.Equal(t, "Alice", f.Database["users"]["alice"]["firstName"])
+}
+
+func TestFixtureRecordProfile(t *testing.T) {
+	config := &Config{
+		Strict: true,
+		TableOptions: map[string]*TableOptions{
+			"users":       {DefaultValues: Record{"role": "member", "active": true}},
+			"users#admin": {TableName: "users", DefaultValues: Record{"role": "admin"}},
+		},
+	}
+
+	writer := &memoryWriter{}
+
+	f := &Fixture{
+		Config: config,
+		Writer: writer,
+		Body: strings.NewReader(`users:
+  alice: {}
+  bob:
+    _profile: admin
+  carol:
+    _profile: admin
+    role: owner
+`),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, "member", f.Database["users"]["alice"]["role"])
+	assert.Equal(t, true, f.Database["users"]["alice"]["active"])
+	assert.Equal(t, "admin", f.Database["users"]["bob"]["role"])
+	assert.NotContains(t, f.Database["users"]["bob"], "active")
+	assert.NotContains(t, f.Database["users"]["bob"], "_profile")
+	assert.Equal(t, "owner", f.Database["users"]["carol"]["role"])
+
+	f = &Fixture{
+		Config:     config,
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("users:\n  alice:\n    _profile: staff\n"),
+		BodyFormat: ".yaml",
+	}
+
+	assert.EqualError(t, f.Apply(), "unused table options users#admin")
+
+	f = &Fixture{
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("users:\n  alice:\n    _profile: staff\n"),
+		BodyFormat: ".yaml",
+	}
+
+	assert.EqualError(t, f.Apply(), "table users, key alice, field _profile: undefined profile users#staff")
+}
+
+func TestFixtureReferenceField(t *testing.T) {
+	f := &Fixture{
+		Config: &Config{
+			References: map[string]string{"author_email": "users.email"},
+			TableOptions: map[string]*TableOptions{
+				"audit.events": {References: map[string]string{"post_id": "posts"}},
+				"comments":     {References: map[string]string{"event": "audit.events"}},
+			},
+		},
+		Writer: &memoryWriter{},
+		Body: strings.NewReader(`users:
+  alice:
+    email: alice@example.com
+posts:
+  p1:
+    author_email: alice
+audit.events:
+  e1:
+    post_id: p1
+comments:
+  c1:
+    event: e1
+`),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, "alice@example.com", f.Database["posts"]["p1"]["author_email"])
+	assert.Equal(t, int64(1), f.Database["audit.events"]["e1"]["post_id"])
+	assert.Equal(t, int64(1), f.Database["comments"]["c1"]["event"])
+
+	refTable, refField, err := f.Config.GetReference("posts", "author_email")
+	assert.NoError(t, err)
+	assert.Equal(t, "users", refTable)
+	assert.Equal(t, "email", refField)
+}
+
+func TestFixtureRefany(t *testing.T) {
+	apply := func(seed int64) map[string]any {
+		f := &Fixture{
+			Writer: &memoryWriter{},
+			Seed:   seed,
+			Database: Database{
+				"orders": {
+					"a": {"id": "order a"},
+					"b": {"id": "order b"},
+					"c": {"id": "order c"},
+				},
+				"order_items": {
+					"1": {"order_id": "=refany orders"},
+					"2": {"order_id": "=refany orders"},
+					"3": {"order_id": "=refany orders"},
+					"4": {"order_id": "=refany orders"},
+				},
+			},
+		}
+
+		if err := f.Apply(); err != nil {
+			t.Fatalf("failed to Apply: %s", err)
+		}
+
+		picks := make(map[string]any)
+
+		for key, record := range f.Database["order_items"] {
+			picks[key] = record["order_id"]
+		}
+
+		return picks
+	}
+
+	picks := apply(3)
+
+	for key, v := range picks {
+		assert.Contains(t, []any{"order a", "order b", "order c"}, v, "order item %s", key)
+	}
+
+	assert.Equal(t, picks, apply(3))
+}
+
+func TestFixtureRefpath(t *testing.T) {
+	f := &Fixture{
+		Config: &Config{
+			References: map[string]string{
+				"beta_id": "beta",
+			},
+		},
+		Writer: &memoryWriter{},
+		Database: Database{
+			"alpha": {
+				"1": {"name": "alpha 1"},
+				"2": {"name": "alpha 2"},
+			},
+			"beta": {
+				"1": {"alpha_id": "=ref alpha 2"},
+			},
+			"gamma": {
+				"1": {"beta_id": "1"},
+			},
+			"delta": {
+				"1": {
+					"gamma_id":   "=ref gamma 1",
+					"alpha_id":   "=refpath gamma.1.beta_id.alpha_id",
+					"alpha_name": "=refpath delta.#.gamma_id.beta_id.alpha_id.name",
+					"beta_alpha": "=ref beta 1 alpha_id",
+				},
+			},
+		},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	delta := f.Database["delta"]["1"]
+
+	assert.Equal(t, f.Database["alpha"]["2"]["id"], delta["alpha_id"])
+	assert.Equal(t, "alpha 2", delta["alpha_name"])
+	assert.Equal(t, f.Database["alpha"]["2"]["id"], delta["beta_alpha"])
+}
+
+func TestFixtureRefNested(t *testing.T) {
+	f := &Fixture{
+		Config: &Config{
+			References: map[string]string{
+				"user_id": "users",
+			},
+		},
+		Writer: &memoryWriter{},
+		Database: Database{
+			"users": {
+				"1": {
+					"profile": map[string]any{
+						"settings": map[string]any{"theme": "dark"},
+						"tags":     []any{"admin", "beta"},
+					},
+				},
+			},
+			"orders": {
+				"1": {"user_id": "1"},
+			},
+			"preferences": {
+				"1": {
+					"theme":     "=ref users 1 profile.settings.theme",
+					"tag":       "=ref users 1 profile.tags.1",
+					"via_order": "=refpath orders.1.user_id.profile.settings.theme",
+				},
+			},
+		},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, Record{
+		"id":        f.Database["preferences"]["1"]["id"],
+		"theme":     "dark",
+		"tag":       "beta",
+		"via_order": "dark",
+	}, f.Database["preferences"]["1"])
+
+	_, err := f.GetFieldPath("users", "1", "profile.missing")
+	assert.ErrorIs(t, err, ErrFieldNotFound)
+}
+
+func TestFixtureListField(t *testing.T) {
+	// Top level lists are parsed element by element, and kept as is.
+	f := &Fixture{
+		Config: &Config{},
+		Writer: &memoryWriter{},
+		Database: Database{
+			"users": {"alice": {"name": "Alice"}},
+			"teams": {
+				"core": {
+					"members": []any{"=ref users alice", "=ref users alice name", int64(3)},
+					"labels":  []any{},
+				},
+			},
+		},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, []any{f.Database["users"]["alice"]["id"], "Alice", int64(3)}, f.Database["teams"]["core"]["members"])
+	assert.Equal(t, []any{}, f.Database["teams"]["core"]["labels"])
+}
+
+func TestFixtureCompositeRef(t *testing.T) {
+	f := &Fixture{
+		Config: &Config{
+			TableOptions: map[string]*TableOptions{
+				"orders": {
+					CompositeReferences: map[string]*CompositeReference{
+						"user": {
+							Table:  "users",
+							Fields: map[string]string{"tenant_id": "tenant_id", "user_id": "id"},
+						},
+					},
+				},
+			},
+		},
+		Writer: &memoryWriter{},
+		Database: Database{
+			"users": {
+				"alice": {"id": 10, "tenant_id": 7},
+			},
+			"orders": {
+				"1": {"user": "=ref users alice"},
+				"2": {"user": "alice"},
+			},
+		},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	for _, key := range []string{"1", "2"} {
+		record := f.Database["orders"][key]
+		assert.NotContains(t, record, "user")
+		assert.Equal(t, 7, record["tenant_id"])
+		assert.Equal(t, 10, record["user_id"])
+	}
+
+	nodes := 0
+	for _, node := range f.nodeIDs {
+		if node.label[0] == "users" {
+			assert.Equal(t, 2, node.LenFrom())
+			nodes++
+		}
+	}
+	assert.Equal(t, 1, nodes)
+
+	f2 := &Fixture{
+		Config: f.Config,
+		Writer: &memoryWriter{},
+		Database: Database{
+			"orders": {
+				"1": {"user": "=ref accounts alice"},
+			},
+		},
+	}
+
+	assert.Error(t, f2.Apply())
+}
+
+func TestFixtureSelf(t *testing.T) {
+	f := &Fixture{
+		Config: &Config{},
+		Writer: &memoryWriter{},
+		Database: Database{
+			"teams": {
+				"1": {"id": 5},
+			},
+			"users": {
+				"1": {
+					"username": "alice",
+					"team":     "=ref teams 1",
+					"alias":    "=self username",
+					"email":    "=self {{ .alias }}@{{ .team }}.example.com",
+					"login":    "=self email",
+				},
+			},
+		},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	record := f.Database["users"]["1"]
+	assert.Equal(t, "alice", record["alias"])
+	assert.Equal(t, "alice@5.example.com", record["email"])
+	assert.Equal(t, "alice@5.example.com", record["login"])
+
+	f = &Fixture{
+		Config: &Config{},
+		Writer: &memoryWriter{},
+		Database: Database{
+			"users": {
+				"1": {
+					"a": "=self b",
+					"b": "=self a",
+				},
+			},
+		},
+	}
+
+	assert.ErrorContains(t, f.Apply(), "depends on itself")
+}
+
+func TestFixturePipe(t *testing.T) {
+	f := &Fixture{
+		Config: &Config{},
+		Writer: &memoryWriter{},
+		Database: Database{
+			"users": {
+				"1": {
+					"digest": "=bytes 68656c6c6f encoding=hex | =sha256",
+					"b64":    "=key | =sha256 base64",
+					"input":  "=key | =test_pipe_input",
+				},
+			},
+		},
+		Commands: map[string]CommandFunc{
+			"test_pipe_input": func(in *CommandInput) (*CommandOutput, error) {
+				return &CommandOutput{Value: []any{in.Piped, in.Input, in.Line}}, nil
+			},
+		},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	record := f.Database["users"]["1"]
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", record["digest"])
+	assert.Equal(t, "a4ayc/80/OGda4BO/1o/V0etpOqiLx1JwB5S3beHW0s=", record["b64"])
+	assert.Equal(t, []any{true, "1", ` "1"`}, record["input"])
+
+	f = &Fixture{
+		Config: &Config{},
+		Writer: &memoryWriter{},
+		Database: Database{
+			"users":  {"1": {}},
+			"orders": {"1": {"user": "=ref users 1 | =sha256"}},
+		},
+	}
+
+	assert.ErrorContains(t, f.Apply(), "cannot be piped")
+}
+
+func TestFixtureDynamicKeys(t *testing.T) {
+	writer := &memoryWriter{}
+	f := &Fixture{
+		Config: &Config{},
+		Writer: writer,
+		Seed:   1,
+		Database: Database{
+			"sessions": {
+				"=ulid toString=true": {"token": "=key"},
+				"static":              {"token": "=key"},
+			},
+			"users": {
+				"1": {"session": `=ref sessions "=ulid toString=true" token`},
+			},
+		},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Len(t, f.Database["sessions"], 2)
+	assert.NotContains(t, f.Database["sessions"], "=ulid toString=true")
+
+	var generated string
+	for key := range f.Database["sessions"] {
+		if key != "static" {
+			generated = key
+		}
+	}
+
+	_, err := ulid.Parse(generated)
+	assert.NoError(t, err)
+	assert.Equal(t, generated, f.Database["sessions"][generated]["token"])
+	assert.Equal(t, generated, f.Database["users"]["1"]["session"])
+	assert.Contains(t, writer.inserted, "sessions."+generated)
+}
+
+func TestFixtureTemplateSprig(t *testing.T) {
+	f := &Fixture{
+		Config:       &Config{},
+		Writer:       &memoryWriter{},
+		Body:         strings.NewReader("users:\n  '1':\n    name: {{ .name | upper | quote }}\n    tags: {{ list \"a\" \"b\" | join \",\" }}\n"),
+		BodyFormat:   ".yaml",
+		TemplateData: map[string]any{"name": "alice"},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, "ALICE", f.Database["users"]["1"]["name"])
+	assert.Equal(t, "a,b", f.Database["users"]["1"]["tags"])
+}
+
+func TestFixtureFuncMap(t *testing.T) {
+	AddFuncMap(template.FuncMap{"testScope": func() string { return "global" }})
+
+	f := &Fixture{
+		Config: &Config{
+			FuncMap: template.FuncMap{
+				"testScope": func() string { return "config" },
+				"testOther": func() string { return "config" },
+			},
+		},
+		FuncMap: template.FuncMap{
+			"testScope": func() string { return "fixture" },
+		},
+		Writer: &memoryWriter{},
+		Database: Database{
+			"t": {
+				"1": {
+					"scope": "=template {{- testScope }}",
+					"other": "=template {{- testOther }}",
+				},
+			},
+		},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, "fixture", f.Database["t"]["1"]["scope"])
+	assert.Equal(t, "config", f.Database["t"]["1"]["other"])
+
+	cached, err := f.parseTemplate("{{ testScope }}")
+	assert.NoError(t, err)
+	again, err := f.parseTemplate("{{ testScope }}")
+	assert.NoError(t, err)
+	assert.Same(t, cached, again)
+
+	AddFuncMap(template.FuncMap{"testInvalidate": func() string { return "" }})
+
+	reparsed, err := f.parseTemplate("{{ testScope }}")
+	assert.NoError(t, err)
+	assert.NotSame(t, cached, reparsed)
+
+	// Templates parsed with a previous FuncMap are not reused, even with
+	// closures of the same function.
+	scope := func(s string) func() string {
+		return func() string { return s }
+	}
+
+	for _, want := range []string{"replaced", "replaced again"} {
+		f.FuncMap = template.FuncMap{"testScope": scope(want)}
+		f.Reset()
+
+		if err := f.Apply(); err != nil {
+			t.Fatalf("failed to Apply: %s", err)
+		}
+
+		assert.Equal(t, want, f.Database["t"]["1"]["scope"])
+	}
+
+	f.Config.FuncMap["testOther"] = func() string { return "changed" }
+	f.Config.FuncMap["testNew"] = func() string { return "" }
+	f.Reset()
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, "changed", f.Database["t"]["1"]["other"])
+}
+
+func TestFixtureTemplateOptions(t *testing.T) {
+	f := &Fixture{
+		Config: &Config{
+			TemplateDelims:     [2]string{"[[", "]]"},
+			TemplateMissingKey: "error",
+		},
+		Writer:       &memoryWriter{},
+		Body:         strings.NewReader("emails:\n  '1':\n    subject: '[[ .subject ]]'\n    body: 'Hello {{ .Name }}'\n"),
+		BodyFormat:   ".yaml",
+		TemplateData: map[string]any{"subject": "Welcome"},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	record := f.Database["emails"]["1"]
+	assert.Equal(t, "Welcome", record["subject"])
+	assert.Equal(t, "Hello {{ .Name }}", record["body"])
+
+	f = &Fixture{
+		Config: &Config{TemplateDelims: [2]string{"[[", "]]"}},
+		Writer: &memoryWriter{},
+		Database: Database{
+			"emails": {
+				"1": {
+					"subject": "Welcome",
+					"slug":    "=self [[ .subject | lower ]]",
+					"body":    "=self subject",
+				},
+			},
+		},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, "welcome", f.Database["emails"]["1"]["slug"])
+	assert.Equal(t, "Welcome", f.Database["emails"]["1"]["body"])
+
+	f = &Fixture{
+		Config:       &Config{TemplateMissingKey: "error"},
+		Writer:       &memoryWriter{},
+		Body:         strings.NewReader("emails:\n  '1':\n    subject: '{{ .missing }}'\n"),
+		BodyFormat:   ".yaml",
+		TemplateData: map[string]any{},
+	}
+
+	assert.ErrorContains(t, f.Apply(), "map has no entry for key")
+}
+
+func TestFixtureTemplateDataProviders(t *testing.T) {
+	t.Setenv("FIXTURE_TEST_TENANT", "from-env")
+	t.Setenv("FIXTURE_TEST_USER", "alice")
+
+	f := &Fixture{
+		Config:     &Config{},
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("users:\n  '1':\n    tenant: '{{ .TENANT }}'\n    region: '{{ .region }}'\n    user: '{{ .USER }}'\n    scenario: '{{ .scenario }}'\n"),
+		BodyFormat: ".yaml",
+		TemplateDataProviders: []TemplateDataProvider{
+			EnvTemplateData("FIXTURE_TEST_"),
+			FileTemplateData("fixtures/template-data/values.yaml"),
+			TemplateDataFunc(func(ctx context.Context) (map[string]any, error) {
+				return map[string]any{"TENANT": "from-func", "scenario": "func"}, nil
+			}),
+		},
+		TemplateData: map[string]any{"scenario": "explicit"},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, Record{
+		"id":       f.Database["users"]["1"]["id"],
+		"tenant":   "from-func",
+		"region":   "eu",
+		"user":     "alice",
+		"scenario": "explicit",
+	}, f.Database["users"]["1"])
+}
+
+func TestFixtureWhen(t *testing.T) {
+	body := `
+users:
+  alice: {name: alice}
+  bob:
+    name: bob
+    _when: '{{ eq .scenario "team" }}'
+  carol:
+    name: carol
+    _when: true
+coupons:
+  _when: '{{ .withCoupons }}'
+  c1: {code: SAVE}
+`
+
+	f := &Fixture{
+		Config:       &Config{},
+		Writer:       &memoryWriter{},
+		Body:         strings.NewReader(body),
+		BodyFormat:   ".yaml",
+		TemplateData: map[string]any{"scenario": "solo", "withCoupons": false},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.NotContains(t, f.Database, "coupons")
+	assert.Len(t, f.Database["users"], 2)
+	assert.NotContains(t, f.Database["users"], "bob")
+	assert.NotContains(t, f.Database["users"]["carol"], whenField)
+
+	f = &Fixture{
+		Config:       &Config{},
+		Writer:       &memoryWriter{},
+		Body:         strings.NewReader(body),
+		BodyFormat:   ".yaml",
+		TemplateData: map[string]any{"scenario": "team", "withCoupons": true},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Contains(t, f.Database["coupons"], "c1")
+	assert.Len(t, f.Database["users"], 3)
+
+	f = &Fixture{
+		Config: &Config{},
+		Writer: &memoryWriter{},
+		Database: Database{
+			"orders": {"1": {"user": "=ref users bob"}},
+			"users":  {"bob": {"_when": false}},
+		},
+	}
+
+	assert.ErrorContains(t, f.Apply(), "excluded by _when")
+}
+
+func TestFixtureExpr(t *testing.T) {
+	f := &Fixture{
+		Config: &Config{},
+		Writer: &memoryWriter{},
+		Database: Database{
+			"products": {
+				"1": {"id": 7, "price": 2.5},
+			},
+			"items": {
+				"1": {
+					"quantity":   4,
+					"unit_price": "=ref products 1 price",
+					"total":      "=expr quantity * unit_price",
+					"label":      `=expr data.currency + " " + string(total)`,
+					"big":        "=expr total > 5",
+				},
+			},
+		},
+		TemplateData: map[string]any{"currency": "USD"},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	record := f.Database["items"]["1"]
+	assert.Equal(t, 10.0, record["total"])
+	assert.Equal(t, "USD 10", record["label"])
+	assert.Equal(t, true, record["big"])
+}
+
+func TestFixtureInclude(t *testing.T) {
+	f := &Fixture{
+		Config: &Config{},
+		Writer: &memoryWriter{},
+		Dir:    "fixtures/include",
+		File:   "main.yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Len(t, f.Database["plans"], 2)
+	assert.Equal(t, "Pro (override)", f.Database["plans"]["pro"]["name"])
+	assert.Equal(t, f.Database["plans"]["pro"]["id"], f.Database["users"]["alice"]["plan"])
+	assert.Equal(t, f.Database["roles"]["admin"]["id"], f.Database["users"]["alice"]["role"])
+
+	f = &Fixture{
+		Config:       &Config{},
+		Writer:       &memoryWriter{},
+		Dir:          "fixtures/include",
+		IncludeFiles: []string{"base.yaml"},
+		Body:         strings.NewReader("users:\n  bob:\n    plan: =ref plans free\n"),
+		BodyFormat:   ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, f.Database["plans"]["free"]["id"], f.Database["users"]["bob"]["plan"])
+
+	f = &Fixture{
+		Config: &Config{},
+		Writer: &memoryWriter{},
+		Dir:    "fixtures/include",
+		File:   "cycle-a.yaml",
+	}
+
+	assert.ErrorContains(t, f.Apply(), "circular include")
+}
+
+func TestFixtureOverlays(t *testing.T) {
+	f := &Fixture{
+		Config:   &Config{},
+		Writer:   &memoryWriter{},
+		Dir:      "fixtures/overlay",
+		File:     "base.yaml",
+		Overlays: []string{"staging.yaml"},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	alice := f.Database["users"]["alice"]
+	assert.NotContains(t, alice, "name")
+	assert.Equal(t, "pro", alice["plan"])
+	assert.Equal(t, map[string]any{"theme": "dark", "locale": "en"}, alice["settings"])
+	assert.Equal(t, []any{"c"}, alice["tags"])
+	assert.Equal(t, "Bob", f.Database["users"]["bob"]["name"])
+}
+
+func TestFixtureFiles(t *testing.T) {
+	writer := &memoryWriter{}
+	f := &Fixture{
+		Config: &Config{},
+		Writer: writer,
+		Files: []string{
+			"fixtures/include/base.yaml",
+			"fixtures/include/roles",
+			"fixtures/overlay/base.yaml",
+		},
+		Body:       strings.NewReader("memberships:\n  m1:\n    role: =ref roles admin\n    plan: =ref plans pro\n"),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Len(t, f.Database["plans"], 2)
+	assert.Contains(t, f.Database["users"], "alice")
+	assert.Equal(t, f.Database["roles"]["admin"]["id"], f.Database["memberships"]["m1"]["role"])
+	assert.Equal(t, f.Database["plans"]["pro"]["id"], f.Database["memberships"]["m1"]["plan"])
+	assert.Len(t, writer.inserted, 5)
+}
+
+//go:embed fixtures/include
+var includeFS embed.FS
+
+func TestFixtureFS(t *testing.T) {
+	f := &Fixture{
+		Config: &Config{},
+		Writer: &memoryWriter{},
+		FS:     includeFS,
+		Dir:    "fixtures/include",
+		File:   "main.yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, "Pro (override)", f.Database["plans"]["pro"]["name"])
+	assert.Equal(t, f.Database["roles"]["admin"]["id"], f.Database["users"]["alice"]["role"])
+
+	f = &Fixture{
+		Config: &Config{},
+		Writer: &memoryWriter{},
+		FS: fstest.MapFS{
+			"seed/users.yaml":   {Data: []byte("_include: /shared/plans.yaml\nusers:\n  bob:\n    plan: =ref plans free\n")},
+			"shared/plans.yaml": {Data: []byte("plans:\n  free: {name: Free}\n")},
+		},
+		File: "seed/users.yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, f.Database["plans"]["free"]["id"], f.Database["users"]["bob"]["plan"])
+}
+
+func TestFixtureSubdirectories(t *testing.T) {
+	fsys := fstest.MapFS{
+		"seed/users.yaml":            {Data: []byte("alice:\n  name: Alice\n")},
+		"seed/audit/events.yaml":     {Data: []byte("e1:\n  user_id: =ref users alice\n")},
+		"seed/b_late/comments.yaml":  {Data: []byte("c1:\n  body: late\n")},
+		"seed/a_early/comments.yaml": {Data: []byte("c0:\n  body: early\n")},
+	}
+
+	writer := &memoryWriter{}
+	f := &Fixture{
+		Config: &Config{},
+		Writer: writer,
+		FS:     fsys,
+		File:   "seed",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, []string{"users.alice"}, writer.inserted)
+
+	writer = &memoryWriter{}
+	f = &Fixture{
+		Config: &Config{SubdirectoryMode: SubdirectorySchema},
+		Writer: writer,
+		FS:     fsys,
+		File:   "seed",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, f.Database["users"]["alice"]["id"], f.Database["audit.events"]["e1"]["user_id"])
+	assert.Contains(t, f.Database["a_early.comments"], "c0")
+	assert.Contains(t, f.Database["b_late.comments"], "c1")
+
+	writer = &memoryWriter{}
+	f = &Fixture{
+		Config: &Config{SubdirectoryMode: SubdirectoryGroup},
+		Writer: writer,
+		FS:     fsys,
+		File:   "seed",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, f.Database["users"]["alice"]["id"], f.Database["events"]["e1"]["user_id"])
+
+	position := make(map[string]int)
+
+	for i, label := range writer.inserted {
+		position[label] = i
+	}
+
+	assert.Len(t, writer.inserted, 4)
+	assert.Less(t, position["users.alice"], position["comments.c0"])
+	assert.Less(t, position["comments.c0"], position["comments.c1"])
+}
+
+func TestFixtureOrderPrefixes(t *testing.T) {
+	writer := &memoryWriter{}
+	f := &Fixture{
+		Config: &Config{OrderPrefixes: true},
+		Writer: writer,
+		FS: fstest.MapFS{
+			"seed/02_orders.yaml": {Data: []byte("o1:\n  total: 10\n")},
+			"seed/10_items.yaml":  {Data: []byte("i1:\n  name: Item\n")},
+			"seed/01_users.yaml":  {Data: []byte("alice:\n  name: Alice\n")},
+			"seed/plans.yaml":     {Data: []byte("free:\n  name: Free\n")},
+		},
+		File: "seed",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Contains(t, f.Database, "users")
+	assert.Contains(t, f.Database, "orders")
+	assert.NotContains(t, f.Database, "01_users")
+
+	position := make(map[string]int)
+
+	for i, label := range writer.inserted {
+		position[label] = i
+	}
+
+	assert.Len(t, writer.inserted, 4)
+	assert.Less(t, position["users.alice"], position["orders.o1"])
+	assert.Less(t, position["orders.o1"], position["items.i1"])
+
+	// Without OrderPrefixes, prefixes are part of the table names.
+	f = &Fixture{
+		Config: &Config{},
+		Writer: &memoryWriter{},
+		FS: fstest.MapFS{
+			"seed/2024_events.yaml": {Data: []byte("e1:\n  name: Launch\n")},
+		},
+		File: "seed",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Contains(t, f.Database, "2024_events")
+	assert.NotContains(t, f.Database, "events")
+}
+
+func TestFixtureFileOptions(t *testing.T) {
+	writer := &memoryWriter{}
+	analytics := &memoryWriter{}
+	f := &Fixture{
+		Config:  &Config{},
+		Writer:  writer,
+		Writers: map[string]Writer{"analytics": analytics},
+		FS: fstest.MapFS{
+			"seed/users.yaml": {Data: []byte("alice:\n  name: Alice\n")},
+			"seed/events.yaml": {Data: []byte(`
+_options:
+  schema: audit
+  writer: analytics
+  defaults: {source: fixture}
+e1:
+  user_id: =ref users alice
+`)},
+		},
+		File: "seed",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, []string{"users.alice"}, writer.inserted)
+	assert.Equal(t, []string{"audit.events.e1"}, analytics.inserted)
+	assert.Equal(t, "fixture", f.Database["audit.events"]["e1"]["source"])
+	assert.Equal(t, f.Database["users"]["alice"]["id"], f.Database["audit.events"]["e1"]["user_id"])
+
+	writer = &memoryWriter{}
+	f = &Fixture{
+		Config: &Config{},
+		Writer: writer,
+		FS: fstest.MapFS{
+			"seed.yaml": {Data: []byte(`
+_options:
+  tables:
+    plans:
+      primary_key: code
+plans:
+  free: {code: FREE}
+subscriptions:
+  s1:
+    plan: =ref plans free
+`)},
+		},
+		File: "seed.yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, "FREE", f.Database["subscriptions"]["s1"]["plan"])
+
+	f = &Fixture{
+		Config:     &Config{},
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("_options:\n  write_mode: later\nusers:\n  alice: {}\n"),
+		BodyFormat: ".yaml",
+	}
+
+	assert.ErrorContains(t, f.Apply(), "write_mode must be async or sync")
+}
+
+func TestFixtureTableWriter(t *testing.T) {
+	sql := &memoryWriter{}
+	cache := &memoryWriter{}
+	config := &Config{
+		TableOptions: map[string]*TableOptions{
+			"users":   {Writer: "sql"},
+			"entries": {Writer: "cache"},
+		},
+	}
+
+	f := &Fixture{
+		Config:  config,
+		Writers: map[string]Writer{"sql": sql, "cache": cache},
+		Body: strings.NewReader(`entries:
+  session:
+    user_id: =ref users alice
+users:
+  alice: {}
+`),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, []string{"users.alice"}, sql.inserted)
+	assert.Equal(t, []string{"entries.session"}, cache.inserted)
+	assert.Equal(t, f.Database["users"]["alice"]["id"], f.Database["entries"]["session"]["user_id"])
+
+	sql = &memoryWriter{}
+	f = &Fixture{
+		Config:     config,
+		Writers:    map[string]Writer{"sql": sql},
+		Body:       strings.NewReader("users:\n  alice: {}\nentries:\n  session: {}\nposts:\n  p1: {}\n"),
+		BodyFormat: ".yaml",
+	}
+
+	assert.EqualError(t, f.Apply(), `unknown writer "cache" for table entries
+missing writer for table posts`)
+	assert.Empty(t, sql.inserted)
+}
+
+func TestFixtureAfterWrite(t *testing.T) {
+	var indexed []any
+
+	config := &Config{
+		TableOptions: map[string]*TableOptions{
+			"users": {
+				AfterWrite: func(ctx context.Context, record Record) error {
+					indexed = append(indexed, record["id"])
+					record["indexed"] = true
+
+					return nil
+				},
+			},
+			"posts": {
+				AfterWrite: func(ctx context.Context, record Record) error {
+					return errors.New("index unavailable")
+				},
+			},
+		},
+	}
+
+	f := &Fixture{
+		Config:     config,
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("users:\n  alice: {}\n  bob: {}\n"),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	// Called with the IDs set by the writer.
+	assert.Equal(t, []any{int64(1), int64(2)}, indexed)
+	assert.Equal(t, true, f.Database["users"]["bob"]["indexed"])
+
+	f = &Fixture{
+		Config:     config,
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("posts:\n  p1: {}\n"),
+		BodyFormat: ".yaml",
+	}
+
+	assert.EqualError(t, f.Apply(), "failed to execute AfterWrite func: index unavailable")
+}
+
+func TestFixtureSkipAndReplaceRecords(t *testing.T) {
+	writer := &memoryWriter{}
+	config := &Config{
+		TableOptions: map[string]*TableOptions{
+			"users": {
+				BeforeWrite: func(ctx context.Context, record Record) error {
+					if record["existing"] == true {
+						return ErrSkipRecord
+					}
+
+					return nil
+				},
+			},
+			"posts": {
+				PrepareWrite: func(ctx context.Context, record Record) (Record, error) {
+					return Record{"title": strings.ToUpper(record["title"].(string)), "user_id": record["user_id"]}, nil
+				},
+			},
+		},
+	}
+
+	f := &Fixture{
+		Config: config,
+		Writer: writer,
+		Body: strings.NewReader(`users:
+  admin:
+    id: 42
+    existing: true
+  alice: {}
+posts:
+  p1:
+    title: hello
+    user_id: =ref users admin
+    draft: true
+`),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, []string{"users.alice", "posts.p1"}, writer.inserted)
+	assert.Equal(t, Record{"id": int64(1), "title": "HELLO", "user_id": 42}, f.Database["posts"]["p1"])
+}
+
+func TestFixtureRecordDirectives(t *testing.T) {
+	writer := &memoryWriter{}
+	events := &memoryWriter{}
+	f := &Fixture{
+		Config:      &Config{},
+		Writer:      writer,
+		Writers:     map[string]Writer{"events": events},
+		ExcludeTags: []string{"slow"},
+		Body: strings.NewReader(`
+users:
+  alice:
+    _tags: [admin]
+  bob:
+    _skip: true
+  carol:
+    _skip: '{{ .local }}'
+    _tags: slow
+  dave:
+    _writer: events
+    _writeMode: sync
+`),
+		BodyFormat:   ".yaml",
+		TemplateData: map[string]any{"local": false},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, []string{"users.alice"}, writer.inserted)
+	assert.Equal(t, []string{"users.dave"}, events.inserted)
+	assert.Equal(t, []string{"admin"}, f.RecordTags("users", "alice"))
+	assert.NotContains(t, f.Database["users"], "bob")
+	assert.NotContains(t, f.Database["users"], "carol")
+	assert.Equal(t, Record{"id": int64(1)}, f.Database["users"]["dave"])
+
+	f = &Fixture{
+		Config:     &Config{},
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("users:\n  alice:\n    _skip: true\nposts:\n  p1:\n    user_id: =ref users alice\n"),
+		BodyFormat: ".yaml",
+	}
+
+	assert.ErrorContains(t, f.Apply(), "excluded by _skip")
+}
+
+func TestFixtureFilters(t *testing.T) {
+	body := `
+plans:
+  free: {name: Free}
+users:
+  alice:
+    plan: =ref plans free
+  bob: {}
+posts:
+  p1:
+    user_id: =ref users alice
+`
+
+	writer := &memoryWriter{}
+	f := &Fixture{
+		Config:     &Config{},
+		Writer:     writer,
+		Body:       strings.NewReader(body),
+		BodyFormat: ".yaml",
+		Include:    []string{"post*"},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.ElementsMatch(t, []string{"plans.free", "users.alice", "posts.p1"}, writer.inserted)
+	assert.NotContains(t, f.Database["users"], "bob")
+
+	writer = &memoryWriter{}
+	f = &Fixture{
+		Config:     &Config{},
+		Writer:     writer,
+		Body:       strings.NewReader(body),
+		BodyFormat: ".yaml",
+		Include:    []string{"users"},
+		Exclude:    []string{"users.bob"},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.ElementsMatch(t, []string{"plans.free", "users.alice"}, writer.inserted)
+
+	f = &Fixture{
+		Config:     &Config{},
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader(body),
+		BodyFormat: ".yaml",
+		Include:    []string{"users["},
+	}
+
+	assert.ErrorContains(t, f.Apply(), "invalid filter")
+}
+
+func TestFixtureSuite(t *testing.T) {
+	config := &Config{
+		Suites: map[string]*Suite{
+			"checkout": {
+				Files:        []string{"fixtures/include/base.yaml", "fixtures/include/roles"},
+				TemplateData: map[string]any{"plan": "pro", "paid": false},
+			},
+		},
+	}
+
+	writer := &memoryWriter{}
+	f := &Fixture{
+		Config:       config,
+		Writer:       writer,
+		Suite:        "checkout",
+		Body:         strings.NewReader("orders:\n  o1:\n    plan: =ref plans {{ .plan }}\n    paid: {{ .paid }}\n"),
+		BodyFormat:   ".yaml",
+		TemplateData: map[string]any{"paid": true},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, f.Database["plans"]["pro"]["id"], f.Database["orders"]["o1"]["plan"])
+	assert.Equal(t, true, f.Database["orders"]["o1"]["paid"])
+	assert.Contains(t, f.Database["roles"], "admin")
+
+	f = &Fixture{
+		Config: config,
+		Writer: &memoryWriter{},
+		Suite:  "unknown",
+	}
+
+	assert.ErrorContains(t, f.Apply(), `unknown suite "unknown"`)
+}
+
+func TestFixtureRequires(t *testing.T) {
+	writer := &memoryWriter{}
+	f := &Fixture{
+		Config: &Config{},
+		Writer: writer,
+		FS: fstest.MapFS{
+			"base-users.yaml": {Data: []byte("_requires: plans.yaml\nusers:\n  alice:\n    plan: =ref plans free\n")},
+			"plans.yaml":      {Data: []byte("plans:\n  free: {name: Free}\n")},
+			"orders.yaml":     {Data: []byte("_requires: [base-users.yaml, plans.yaml]\norders:\n  o1:\n    user_id: =ref users alice\n")},
+			"carts.yaml":      {Data: []byte("_requires: [base-users.yaml]\nplans:\n  free: {name: Free (cart)}\ncarts:\n  c1:\n    user_id: =ref users alice\n")},
+		},
+		Files: []string{"orders.yaml", "carts.yaml"},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Equal(t, f.Database["users"]["alice"]["id"], f.Database["orders"]["o1"]["user_id"])
+	assert.Equal(t, f.Database["users"]["alice"]["id"], f.Database["carts"]["c1"]["user_id"])
+
+	// Required files aren't loaded again, so they don't revert the plan.
+	assert.Equal(t, "Free (cart)", f.Database["plans"]["free"]["name"])
+	assert.Len(t, writer.inserted, 4)
+}
+
+func TestFixtureOutput(t *testing.T) {
+	for format, want := range map[int]string{
+		OutputJSON: "{\n\t\"users\": {\n\t\t\"alice\": {\n\t\t\t\"id\": 1,\n\t\t\t\"nickname\": null\n\t\t}\n\t}\n}\n",
+		OutputYAML: "users:\n    alice:\n        id: 1\n        nickname: null\n",
+		OutputTOML: "[users]\n  [users.alice]\n    id = 1\n",
+	} {
+		output := new(bytes.Buffer)
+		f := &Fixture{
+			Config:       &Config{},
+			Writer:       &memoryWriter{},
+			Body:         strings.NewReader("users:\n  alice:\n    nickname: null\n"),
+			BodyFormat:   ".yaml",
+			Output:       output,
+			OutputFormat: format,
+		}
+
+		if err := f.Apply(); err != nil {
+			t.Fatalf("failed to Apply: %s", err)
+		}
+
+		assert.Equal(t, want, output.String())
+	}
+}
+
+func TestFixtureExport(t *testing.T) {
+	f := &Fixture{
+		Config:     &Config{},
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("users:\n  alice:\n    tags: [a]\n    settings: {theme: dark}\n"),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	exported := f.Export()
+
+	assert.Equal(t, f.Database, exported)
+
+	exported["users"]["alice"]["tags"].([]any)[0] = "b"
+	exported["users"]["alice"]["settings"].(map[string]any)["theme"] = "light"
+	exported["users"]["bob"] = Record{}
+
+	assert.Equal(t, []any{"a"}, f.Database["users"]["alice"]["tags"])
+	assert.Equal(t, map[string]any{"theme": "dark"}, f.Database["users"]["alice"]["settings"])
+	assert.NotContains(t, f.Database["users"], "bob")
+}
+
+func TestFixtureConcurrentReads(t *testing.T) {
+	f := &Fixture{
+		Config:     &Config{},
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("users:\n  alice:\n    name: Alice\n"),
+		BodyFormat: ".yaml",
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			if i == 0 {
+				assert.NoError(t, f.Apply())
+				return
+			}
+
+			_, _ = f.GetField("users", "alice", "name")
+			_ = f.SetField("users", "bob", "name", "Bob")
+			_ = f.Export()
+		}(i)
+	}
+
+	wg.Wait()
+
+	assert.True(t, f.Applied())
+	assert.Equal(t, "Alice", f.MustGetField(t, "users", "alice", "name"))
+}
+
+// authorWriter is a memoryWriter setting the author of the posts it inserts
+// to the id of users.alice.
+type authorWriter struct {
+	memoryWriter
+}
+
+func (w *authorWriter) Insert(f *Fixture, table string, key string, record Record) error {
+	if table == "posts" {
+		id, err := f.GetField("users", "alice", "id")
+		if err != nil {
+			return err
+		}
+
+		record["author_id"] = id
+	}
+
+	return w.memoryWriter.Insert(f, table, key, record)
+}
+
+func TestFixtureGetFieldDuringApply(t *testing.T) {
+	f := &Fixture{
+		Config:     &Config{},
+		Writer:     &authorWriter{},
+		Body:       strings.NewReader("users:\n  alice:\n    name: Alice\n    nickname: =nickname\nposts:\n  1:\n    user: =ref users alice\n"),
+		BodyFormat: ".yaml",
+		Database:   Database{"users": {"bob": {"name": "Bob"}}},
+	}
+
+	f.Commands = map[string]CommandFunc{
+		// Commands read the records being applied, and other goroutines
+		// the fixture as not applied.
+		"nickname": func(in *CommandInput) (*CommandOutput, error) {
+			name, err := in.Fixture.GetField("users", "bob", "name")
+			if err != nil {
+				return nil, err
+			}
+
+			done := make(chan struct{})
+
+			go func() {
+				defer close(done)
+
+				_, err := in.Fixture.GetField("users", "bob", "name")
+				assert.ErrorIs(t, err, ErrDatabaseNotFound)
+				assert.False(t, in.Fixture.Applied())
+			}()
+
+			<-done
+
+			return &CommandOutput{Value: "friend of " + name.(string)}, nil
+		},
+	}
+
+	f.MustApply(t)
+
+	assert.Equal(t, "friend of Bob", f.MustGetField(t, "users", "alice", "nickname"))
+	assert.Equal(t, f.MustGetField(t, "users", "alice", "id"), f.MustGetField(t, "posts", "1", "author_id"))
+	assert.True(t, f.Applied())
+}
+
+func TestFixtureReset(t *testing.T) {
+	writer := &memoryWriter{}
+	f := &Fixture{
+		Config:     &Config{},
+		Writer:     writer,
+		Body:       strings.NewReader("users:\n  alice:\n    n: =seq users\n    plan: =ref plans free\n"),
+		BodyFormat: ".yaml",
+		Database:   Database{"plans": {"free": {"name": "Free"}}},
+	}
+
+	f.MustApply(t)
+
+	assert.True(t, f.Applied())
+	assert.Equal(t, int64(1), f.Database["users"]["alice"]["n"])
+
+	f.Reset()
+
+	assert.False(t, f.Applied())
+	assert.Equal(t, Database{"plans": {"free": {"name": "Free"}}}, f.Database)
+
+	f.MustApply(t)
+
+	assert.Equal(t, int64(1), f.Database["users"]["alice"]["n"])
+	assert.Equal(t, f.Database["plans"]["free"]["id"], f.Database["users"]["alice"]["plan"])
+	assert.Len(t, writer.inserted, 4)
+}
+
+func BenchmarkFixtureApplyWideRecords(b *testing.B) {
+	table := make(Table, 100)
+
+	for i := 0; i < 100; i++ {
+		record := make(Record, 50)
+
+		for j := 0; j < 50; j++ {
+			switch j % 3 {
+			case 0:
+				record[fmt.Sprint("name_", j)] = fmt.Sprint("value ", j)
+			case 1:
+				record[fmt.Sprint("count_", j)] = j
+			default:
+				record[fmt.Sprint("enabled_", j)] = true
+			}
+		}
+
+		record["plan"] = "=ref plans free"
+		table[fmt.Sprint(i)] = record
+	}
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+
+		// Apply updates the records.
+		database := copyDatabase(Database{
+			"plans": {"free": {"name": "Free"}},
+			"users": table,
+		})
+
+		b.StartTimer()
+
+		f := &Fixture{
+			Config:   &Config{},
+			Writer:   &memoryWriter{},
+			Database: database,
+		}
+
+		if err := f.Apply(); err != nil {
+			b.Fatalf("failed to Apply: %s", err)
+		}
+	}
+}
+
+// blockingWriter blocks until the context of the write is done.
+type blockingWriter struct {
+	memoryWriter
+	contexts []context.Context
+}
+
+func (w *blockingWriter) Insert(f *Fixture, table string, key string, record Record) error {
+	w.contexts = append(w.contexts, f.Context)
+
+	ctx := f.WriteContext()
+	<-ctx.Done()
+
+	return ctx.Err()
+}
+
+func TestFixtureWriteTimeout(t *testing.T) {
+	writer := &blockingWriter{}
+	f := &Fixture{
+		Config:     &Config{WriteTimeout: 10 * time.Millisecond},
+		Writer:     writer,
+		Body:       strings.NewReader("users:\n  alice:\n    name: Alice\n"),
+		BodyFormat: ".yaml",
+	}
+
+	err := f.Apply()
+	assert.EqualError(t, err, `failed to insert record "users"."alice": write timed out after 10ms: context deadline exceeded`)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The Context of the fixture is left as is during writes.
+	assert.Equal(t, []context.Context{context.Background()}, writer.contexts)
+	assert.Equal(t, context.Background(), f.WriteContext())
+}
+
+// failingWriter fails to insert the records in fail.
+type failingWriter struct {
+	memoryWriter
+	fail map[string]error
+}
+
+func (w *failingWriter) Insert(f *Fixture, table string, key string, record Record) error {
+	if err := w.fail[table+"."+key]; err != nil {
+		return err
+	}
+
+	return w.memoryWriter.Insert(f, table, key, record)
+}
+
+func TestFixtureContinueOnError(t *testing.T) {
+	errDuplicate := errors.New("duplicate key")
+
+	newFixture := func(writer Writer) *Fixture {
+		return &Fixture{
+			Config: &Config{},
+			Writer: writer,
+			Body: strings.NewReader(`
+users:
+  alice: {}
+  bob: {}
+orders:
+  1: {user_id: =ref users alice}
+  2: {user_id: =ref users bob}
+invoices:
+  1: {order_id: =ref orders 2}
+`),
+			BodyFormat: ".yaml",
+		}
+	}
+
+	writer := &failingWriter{fail: map[string]error{"users.bob": errDuplicate}}
+	f := newFixture(writer)
+
+	err := f.Apply()
+	assert.ErrorIs(t, err, errDuplicate)
+	assert.NotContains(t, writer.inserted, "orders.2")
+	assert.False(t, f.Applied())
+
+	writer = &failingWriter{fail: map[string]error{"users.bob": errDuplicate}}
+	f = newFixture(writer)
+	f.ContinueOnError = true
+
+	err = f.Apply()
+
+	var applyErr *ApplyError
+	if !errors.As(err, &applyErr) {
+		t.Fatalf("expected an ApplyError, got %v", err)
+	}
+
+	assert.ErrorIs(t, err, errDuplicate)
+	assert.ErrorIs(t, err, ErrDependencyFailed)
+	assert.EqualError(t, err, `failed to write 3 records:
+	table users, key bob: failed to insert record "users"."bob": duplicate key
+	table orders, key 2: dependency failed: users.bob
+	table invoices, key 1: dependency failed: orders.2`)
+	assert.ElementsMatch(t, []string{"users.alice", "orders.1"}, writer.inserted)
+	assert.True(t, f.Applied())
+}
+
+func TestFixtureRecordErrors(t *testing.T) {
+	f := &Fixture{
+		Config: &Config{},
+		Writer: &memoryWriter{},
+		FS: fstest.MapFS{
+			"fixture.yaml": {Data: []byte(`_include: orders
+users:
+  alice:
+    name: =unknown
+    email: alice@example.com
+  bob:
+    settings:
+      theme: =nope
+`)},
+			"orders/orders.toml": {Data: []byte("[1]\nuser_id = \"=ref\"\n")},
+		},
+		File: "fixture.yaml",
+	}
+
+	err := f.Apply()
+
+	var recordErr *RecordError
+	if !errors.As(err, &recordErr) {
+		t.Fatalf("expected a RecordError, got %v", err)
+	}
+
+	assert.EqualError(t, err, `orders/orders.toml:2:1: table orders, key 1, field user_id: failed to execute command ref: expected at least 2 positional arguments
+fixture.yaml:4:5: table users, key alice, field name: unknown command: unknown
+fixture.yaml:8:7: table users, key bob, field settings: failed to parse field settings.theme: unknown command: nope`)
+}
+
+func TestFixtureStrict(t *testing.T) {
+	body := `users:
+  alice:
+    plan_id: =ref plans pro
+orders:
+  1:
+    user_id: alice
+`
+
+	config := &Config{
+		References: map[string]string{"user_id": "users"},
+	}
+
+	f := &Fixture{
+		Config:     config,
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader(body),
+		BodyFormat: ".yaml",
+	}
+
+	f.MustApply(t)
+
+	// The missing plan is created.
+	assert.Contains(t, f.Database["plans"], "pro")
+
+	strict := &Config{
+		References: map[string]string{"user_id": "users", "team_id": "teams"},
+		TableOptions: map[string]*TableOptions{
+			"orders":   {References: map[string]string{"coupon_id": "coupons"}},
+			"payments": {},
+		},
+		Strict: true,
+	}
+
+	f = &Fixture{
+		Config:     strict,
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader(body),
+		BodyFormat: ".yaml",
+	}
+
+	assert.EqualError(t, f.Apply(), `reference team_id references undefined table teams
+table orders, reference coupon_id references undefined table coupons
+unused table options payments`)
+
+	strict = &Config{
+		References: map[string]string{"user_id": "users"},
+		Strict:     true,
+	}
+
+	f = &Fixture{
+		Config:     strict,
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader(body),
+		BodyFormat: ".yaml",
+	}
+
+	err := f.Apply()
+	assert.ErrorIs(t, err, ErrUndefinedRecord)
+	assert.EqualError(t, err, "table users, key alice, field plan_id: undefined record: plans.pro")
 }
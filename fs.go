@@ -0,0 +1,122 @@
+package fixture
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// osFS is the fs.FS used when Fixture.FS is nil, which accepts any
+// operating system path, relative to the working directory or absolute.
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (osFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+// fileSource is a file system fixture files are loaded from. The paths of
+// its files are qualified by prefix in their identifiers and errors, e.g.
+// s3://bucket/ for the files of an object store.
+type fileSource struct {
+	fsys   fs.FS
+	prefix string
+
+	// native is set for the operating system's file system, whose paths use
+	// the OS separator and can be absolute.
+	native bool
+}
+
+// source returns the file source of the paths given to the Fixture: FS if
+// set, or else the operating system's file system.
+func (f *Fixture) source() *fileSource {
+	if f.FS != nil {
+		return &fileSource{fsys: f.FS}
+	}
+
+	return &fileSource{fsys: osFS{}, native: true}
+}
+
+// join joins path elements, with slashes unless the source is native.
+func (s *fileSource) join(elem ...string) string {
+	if s.native {
+		return filepath.Join(elem...)
+	}
+
+	return path.Join(elem...)
+}
+
+// fixturePath returns the path of a fixture file given to the Fixture,
+// relative to Dir unless it is a remote URL.
+func (f *Fixture) fixturePath(name string) string {
+	if isRemotePath(name) {
+		return name
+	}
+
+	return f.source().join(f.Dir, name)
+}
+
+// includePath returns the path of an included file, relative to the
+// including file's dir unless it is absolute (or rooted, for a non-native
+// source).
+func (s *fileSource) includePath(dir, name string) string {
+	if isRemotePath(name) {
+		return name
+	}
+
+	if !s.native {
+		if strings.HasPrefix(name, "/") {
+			return path.Clean(name[1:])
+		}
+
+		return path.Join(dir, name)
+	}
+
+	if filepath.IsAbs(name) {
+		return name
+	}
+
+	return filepath.Join(dir, name)
+}
+
+// dir returns the directory of a file path.
+func (s *fileSource) dir(name string) string {
+	if s.native {
+		return filepath.Dir(name)
+	}
+
+	return path.Dir(name)
+}
+
+// file returns the name of a file path in errors, qualified by the source.
+func (s *fileSource) file(name string) string {
+	return s.prefix + name
+}
+
+// id returns a unique identifier for a file path, to detect circular
+// includes and already loaded files.
+func (s *fileSource) id(name string) (string, error) {
+	if s.native {
+		return filepath.Abs(name)
+	}
+
+	name = path.Clean(name)
+	if name == "." && s.prefix != "" {
+		return s.prefix, nil
+	}
+
+	return s.prefix + name, nil
+}
@@ -0,0 +1,138 @@
+package fixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UpdateGoldenEnv is the environment variable which, set to true (e.g. 1),
+// makes AssertGolden write the golden files instead of comparing them.
+const UpdateGoldenEnv = "FIXTURE_UPDATE_GOLDEN"
+
+// GoldenT is the part of testing.TB used by AssertGolden.
+type GoldenT interface {
+	Helper()
+	Errorf(format string, args ...any)
+	Fatalf(format string, args ...any)
+}
+
+// MarshalGolden returns the resolved Database as indented JSON, with sorted
+// keys and normalized values: times in UTC RFC 3339, UUIDs as strings and
+// Raw values as their SQL.
+func (f *Fixture) MarshalGolden() ([]byte, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	b, err := json.MarshalIndent(goldenValue(f.view().Database), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal database: %w", err)
+	}
+
+	return append(b, '\n'), nil
+}
+
+// AssertGolden compares the resolved Database (see MarshalGolden) with the
+// golden file at path, failing t if they differ. The golden file is written
+// instead when the UpdateGoldenEnv environment variable is true, or when the
+// test binary runs with an -update flag defined by the test package. Use Seed
+// to make generated values stable.
+func (f *Fixture) AssertGolden(t GoldenT, path string) {
+	t.Helper()
+
+	got, err := f.MarshalGolden()
+	if err != nil {
+		t.Fatalf("failed to marshal golden database: %s", err)
+	}
+
+	if shouldUpdateGolden() {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create golden file directory: %s", err)
+		}
+
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("failed to write golden file: %s", err)
+		}
+
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (set "+UpdateGoldenEnv+"=1 to create it): %s", err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("database differs from golden file %s:\n--- want\n%s\n--- got\n%s", path, want, got)
+	}
+}
+
+func shouldUpdateGolden() bool {
+	if update, err := strconv.ParseBool(os.Getenv(UpdateGoldenEnv)); err == nil && update {
+		return true
+	}
+
+	if fl := flag.Lookup("update"); fl != nil {
+		if getter, ok := fl.Value.(flag.Getter); ok {
+			update, _ := getter.Get().(bool)
+			return update
+		}
+	}
+
+	return false
+}
+
+// goldenValue returns a copy of v with normalized values, see MarshalGolden.
+func goldenValue(v any) any {
+	switch t := v.(type) {
+	case Database:
+		m := make(map[string]any, len(t))
+
+		for k := range t {
+			m[k] = goldenValue(t[k])
+		}
+
+		return m
+	case Table:
+		m := make(map[string]any, len(t))
+
+		for k := range t {
+			m[k] = goldenValue(t[k])
+		}
+
+		return m
+	case Record:
+		return goldenValue(map[string]any(t))
+	case map[string]any:
+		m := make(map[string]any, len(t))
+
+		for k := range t {
+			m[k] = goldenValue(t[k])
+		}
+
+		return m
+	case []any:
+		l := make([]any, len(t))
+
+		for i := range t {
+			l[i] = goldenValue(t[i])
+		}
+
+		return l
+	case time.Time:
+		return t.UTC().Format(time.RFC3339Nano)
+	case [16]uint8:
+		return uuid.UUID(t).String()
+	case Raw:
+		return string(t)
+	}
+
+	return v
+}
@@ -0,0 +1,91 @@
+package fixture
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixtureGolden(t *testing.T) {
+	f := &Fixture{
+		Config:     &Config{},
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("users:\n  alice:\n    name: Alice\n    tags: [a]\n"),
+		BodyFormat: ".yaml",
+		Database: Database{
+			"events": {"e1": {
+				"at":   time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600)),
+				"uuid": [16]uint8(uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")),
+				"sql":  Raw("NOW()"),
+			}},
+		},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	b, err := f.MarshalGolden()
+	if err != nil {
+		t.Fatalf("failed to marshal golden database: %s", err)
+	}
+
+	want := `{
+  "events": {
+    "e1": {
+      "at": "2024-01-02T02:04:05Z",
+      "id": 1,
+      "sql": "NOW()",
+      "uuid": "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+    }
+  },
+  "users": {
+    "alice": {
+      "id": 1,
+      "name": "Alice",
+      "tags": [
+        "a"
+      ]
+    }
+  }
+}
+`
+
+	assert.Equal(t, want, string(b))
+
+	path := filepath.Join(t.TempDir(), "golden.json")
+
+	if err := os.WriteFile(path, []byte(want), 0o644); err != nil {
+		t.Fatalf("failed to write golden file: %s", err)
+	}
+
+	f.AssertGolden(t, path)
+}
+
+func TestFixtureGoldenUpdate(t *testing.T) {
+	f := &Fixture{
+		Config:     &Config{},
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("users:\n  alice: {name: Alice}\n"),
+		BodyFormat: ".yaml",
+	}
+
+	f.MustApply(t)
+
+	path := filepath.Join(t.TempDir(), "testdata", "golden.json")
+
+	t.Setenv(UpdateGoldenEnv, "1")
+	f.AssertGolden(t, path)
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"name": "Alice"`)
+
+	t.Setenv(UpdateGoldenEnv, "")
+	f.AssertGolden(t, path)
+}
@@ -1,6 +1,11 @@
 package fixture
 
-import "gonum.org/v1/gonum/graph"
+import (
+	"fmt"
+	"strings"
+
+	"gonum.org/v1/gonum/graph"
+)
 
 type Node struct {
 	id    int64
@@ -8,7 +13,16 @@ type Node struct {
 	from  []*Node
 	to    []*Node
 
+	// fromIDs and toIDs index the IDs of from and to once they grow past
+	// nodeIndexThreshold, see appendNode.
+	fromIDs map[int64]struct{}
+	toIDs   map[int64]struct{}
+
 	callbacks []func() error
+
+	// resolvers update the node's record fields right before it is written.
+	resolvers map[string]func() error
+	resolving map[string]bool
 }
 
 func (r *Node) ID() int64 {
@@ -19,12 +33,113 @@ func (r *Node) Label() [2]string {
 	return r.label
 }
 
+func (r *Node) addResolver(field string, resolver func() error) {
+	if r.resolvers == nil {
+		r.resolvers = make(map[string]func() error)
+		r.resolving = make(map[string]bool)
+	}
+
+	r.resolvers[field] = resolver
+}
+
+// resolve runs the pending resolvers of the given fields (including their
+// nested and parent fields), or all of them if no field is given. Resolvers
+// can call resolve themselves to depend on other fields of the record.
+func (r *Node) resolve(fields ...string) error {
+	for _, field := range sortedKeys(r.resolvers) {
+		if len(fields) > 0 && !matchesAnyField(field, fields) {
+			continue
+		}
+
+		resolver, ok := r.resolvers[field]
+		if !ok {
+			// Already run by a previous resolver.
+			continue
+		}
+
+		if r.resolving[field] {
+			return fmt.Errorf("field %s depends on itself", field)
+		}
+
+		r.resolving[field] = true
+
+		if err := resolver(); err != nil {
+			return err
+		}
+
+		delete(r.resolvers, field)
+		delete(r.resolving, field)
+	}
+
+	return nil
+}
+
+func matchesAnyField(field string, fields []string) bool {
+	for _, f := range fields {
+		if field == f || strings.HasPrefix(field, f+".") || strings.HasPrefix(f, field+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AppendTo adds node to the nodes r depends on, unless already added.
 func (r *Node) AppendTo(node *Node) {
-	r.to = append(r.to, node)
+	r.to, r.toIDs = appendNode(r.to, r.toIDs, node)
 }
 
+// AppendFrom adds node to the nodes depending on r, unless already added.
 func (r *Node) AppendFrom(node *Node) {
-	r.from = append(r.from, node)
+	r.from, r.fromIDs = appendNode(r.from, r.fromIDs, node)
+}
+
+// hasFrom returns whether the node with the given ID depends on r.
+func (r *Node) hasFrom(id int64) bool {
+	return containsNode(r.from, r.fromIDs, id)
+}
+
+// nodeIndexThreshold is the number of edges of a node above which their IDs
+// are indexed in a set. Most nodes have a few edges, which are faster to scan,
+// but the records of a large table can all depend on the same one.
+const nodeIndexThreshold = 16
+
+// appendNode adds node to l if not already in it, indexing the IDs of l in
+// ids once it grows past nodeIndexThreshold.
+func appendNode(l []*Node, ids map[int64]struct{}, node *Node) ([]*Node, map[int64]struct{}) {
+	if containsNode(l, ids, node.id) {
+		return l, ids
+	}
+
+	l = append(l, node)
+
+	switch {
+	case ids != nil:
+		ids[node.id] = struct{}{}
+	case len(l) > nodeIndexThreshold:
+		ids = make(map[int64]struct{}, len(l))
+
+		for _, n := range l {
+			ids[n.id] = struct{}{}
+		}
+	}
+
+	return l, ids
+}
+
+func containsNode(l []*Node, ids map[int64]struct{}, id int64) bool {
+	if ids != nil {
+		_, ok := ids[id]
+		return ok
+	}
+
+	for _, n := range l {
+		if n.id == id {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (r *Node) LenTo() int {
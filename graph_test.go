@@ -0,0 +1,64 @@
+package fixture
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/graph"
+)
+
+func TestFixtureGraph(t *testing.T) {
+	f := &Fixture{}
+	f.resetState()
+
+	plan := f.GetNode([2]string{"plans", "free"})
+
+	for i := 0; i < nodeIndexThreshold*2; i++ {
+		user := f.GetNode([2]string{"users", fmt.Sprint(i)})
+
+		// Duplicated edges, e.g. from two references, are added once.
+		addDependency(user, plan)
+		addDependency(user, plan)
+
+		assert.True(t, f.HasEdgeFromTo(plan.ID(), user.ID()))
+		assert.False(t, f.HasEdgeFromTo(user.ID(), plan.ID()))
+		assert.True(t, f.HasEdgeBetween(user.ID(), plan.ID()))
+		assert.Equal(t, 1, user.LenTo())
+	}
+
+	assert.Equal(t, nodeIndexThreshold*2, plan.LenFrom())
+	assert.Equal(t, nodeIndexThreshold*2, f.From(plan.ID()).Len())
+	assert.NotNil(t, plan.fromIDs)
+	assert.False(t, f.HasEdgeBetween(f.nodes[1].ID(), f.nodes[2].ID()))
+
+	nodes := f.Nodes()
+	assert.Equal(t, nodeIndexThreshold*2+1, nodes.Len())
+
+	for i := int64(1); nodes.Next(); i++ {
+		assert.Equal(t, i, nodes.Node().ID())
+	}
+}
+
+func TestFixtureEdgeBetween(t *testing.T) {
+	f := &Fixture{}
+	f.resetState()
+
+	plan := f.GetNode([2]string{"plans", "free"})
+	user := f.GetNode([2]string{"users", "alice"})
+	other := f.GetNode([2]string{"users", "bob"})
+
+	addDependency(user, plan)
+
+	// Edges are undirected for HasEdgeBetween and Edge, either way round.
+	assert.True(t, f.HasEdgeBetween(user.ID(), plan.ID()))
+	assert.True(t, f.HasEdgeBetween(plan.ID(), user.ID()))
+	assert.False(t, f.HasEdgeBetween(user.ID(), other.ID()))
+
+	for _, edge := range []graph.Edge{f.Edge(plan.ID(), user.ID()), f.Edge(user.ID(), plan.ID())} {
+		assert.Equal(t, plan.ID(), edge.From().ID())
+		assert.Equal(t, user.ID(), edge.To().ID())
+	}
+
+	assert.Nil(t, f.Edge(user.ID(), other.ID()))
+}
@@ -0,0 +1,111 @@
+package fixture
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// setRecordLayer records the ordering layer of a record loaded from a
+// directory, if any. Records of a layer are written after the records of
+// every lower layer.
+func (f *Fixture) setRecordLayer(table, key string) {
+	if f.layer == nil {
+		delete(f.recordLayers, [2]string{table, key})
+		return
+	}
+
+	layer := make([]int, len(f.layer))
+	copy(layer, f.layer)
+
+	f.recordLayers[[2]string{table, key}] = layer
+}
+
+// appendLayer returns a copy of layer with the given elements appended.
+func appendLayer(layer []int, elems ...int) []int {
+	return append(layer[:len(layer):len(layer)], elems...)
+}
+
+// cutOrderPrefix splits a numeric ordering prefix, e.g. "01_" in
+// "01_users", from a file name.
+func cutOrderPrefix(name string) (int, string, bool) {
+	prefix, rest, ok := strings.Cut(name, "_")
+	if !ok || prefix == "" || rest == "" {
+		return 0, name, false
+	}
+
+	n, err := strconv.Atoi(prefix)
+	if err != nil || strings.ContainsAny(prefix, "+-") {
+		return 0, name, false
+	}
+
+	return n, rest, true
+}
+
+// addLayerBarriers makes the records of each layer depend on the records of
+// the previous one, through a barrier node between them. Barriers have an
+// empty table and are skipped when writing.
+func (f *Fixture) addLayerBarriers() {
+	if len(f.recordLayers) == 0 {
+		return
+	}
+
+	byLayer := make(map[string][][2]string)
+	layers := make(map[string][]int)
+
+	for label, layer := range f.recordLayers {
+		if _, ok := f.nodesByKey[label]; !ok {
+			// Not part of the database anymore, e.g. excluded by _when.
+			continue
+		}
+
+		id := fmt.Sprint(layer)
+		byLayer[id] = append(byLayer[id], label)
+		layers[id] = layer
+	}
+
+	ids := make([]string, 0, len(layers))
+
+	for id := range layers {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return compareLayers(layers[ids[i]], layers[ids[j]]) < 0
+	})
+
+	for i := 1; i < len(ids); i++ {
+		barrier := f.GetNode([2]string{"", "layer " + ids[i]})
+
+		for _, label := range byLayer[ids[i-1]] {
+			addDependency(barrier, f.nodesByKey[label])
+		}
+
+		for _, label := range byLayer[ids[i]] {
+			addDependency(f.nodesByKey[label], barrier)
+		}
+	}
+}
+
+// addDependency makes node depend on (be written after) dependency.
+func addDependency(node, dependency *Node) {
+	dependency.AppendFrom(node)
+	node.AppendTo(dependency)
+}
+
+// compareLayers compares layers lexicographically, a layer sorting before
+// the layers it is a prefix of.
+func compareLayers(a, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+
+			return 1
+		}
+	}
+
+	return len(a) - len(b)
+}
@@ -0,0 +1,93 @@
+package fixture
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WriteLimit limits the rate and concurrency of the writer operations of the
+// fixtures sharing a Config, e.g. when seeding a shared staging environment.
+// See Config.WriteLimit.
+type WriteLimit struct {
+	// The maximum number of writes per second.
+	// Default: no limit
+	PerSecond float64
+
+	// The maximum number of concurrent writes, e.g. of fixtures applied by
+	// parallel tests.
+	// Default: no limit
+	Concurrent int
+
+	initOnce sync.Once
+	slots    chan struct{}
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// wait blocks until a write is allowed, or ctx is done. The returned func
+// must be called when the write completes.
+func (l *WriteLimit) wait(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	l.initOnce.Do(func() {
+		if l.Concurrent > 0 {
+			l.slots = make(chan struct{}, l.Concurrent)
+		}
+	})
+
+	if l.PerSecond > 0 {
+		if err := sleep(ctx, l.reserve()); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	return func() { <-l.slots }, nil
+}
+
+// reserve returns the delay before the next write allowed by PerSecond.
+func (l *WriteLimit) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	if l.next.Before(now) {
+		l.next = now
+	}
+
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(time.Second) / l.PerSecond))
+
+	return delay
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
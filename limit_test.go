@@ -0,0 +1,80 @@
+package fixture
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// concurrencyWriter records the maximum number of concurrent inserts.
+type concurrencyWriter struct {
+	memoryWriter
+	mu      sync.Mutex
+	current int
+	max     int
+}
+
+func (w *concurrencyWriter) Insert(f *Fixture, table string, key string, record Record) error {
+	w.mu.Lock()
+	w.current++
+
+	if w.current > w.max {
+		w.max = w.current
+	}
+
+	w.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	w.mu.Lock()
+	w.current--
+	w.mu.Unlock()
+
+	return nil
+}
+
+func TestFixtureWriteLimit(t *testing.T) {
+	body := "users:\n  alice: {}\n  bob: {}\n  carol: {}\n  dave: {}\n  eve: {}\n"
+
+	f := &Fixture{
+		Config:     &Config{WriteLimit: &WriteLimit{PerSecond: 100}},
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader(body),
+		BodyFormat: ".yaml",
+	}
+
+	start := time.Now()
+	f.MustApply(t)
+
+	// The first write is immediate, the next ones 10ms apart.
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	config := &Config{WriteLimit: &WriteLimit{Concurrent: 1}}
+	writer := &concurrencyWriter{}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			f := &Fixture{
+				Config:     config,
+				Writer:     writer,
+				Body:       strings.NewReader(body),
+				BodyFormat: ".yaml",
+			}
+
+			assert.NoError(t, f.Apply())
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 1, writer.max)
+}
@@ -0,0 +1,47 @@
+package fixture
+
+import "testing"
+
+// MustApply is like Apply, but fails t on error.
+func (f *Fixture) MustApply(t testing.TB) {
+	t.Helper()
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to apply fixture: %s", err)
+	}
+}
+
+// MustGetField is like GetField, but fails t on error. E.g.:
+//
+//	userID := f.MustGetField(t, "users", "alice", "id")
+func (f *Fixture) MustGetField(t testing.TB, table, key, field string) any {
+	t.Helper()
+
+	v, err := f.GetField(table, key, field)
+	if err != nil {
+		t.Fatalf("failed to get field %s.%s.%s: %s", table, key, field, err)
+	}
+
+	return v
+}
+
+// MustGetFieldPath is like GetFieldPath, but fails t on error.
+func (f *Fixture) MustGetFieldPath(t testing.TB, table, key, path string) any {
+	t.Helper()
+
+	v, err := f.GetFieldPath(table, key, path)
+	if err != nil {
+		t.Fatalf("failed to get field %s.%s.%s: %s", table, key, path, err)
+	}
+
+	return v
+}
+
+// MustScanRecord is like ScanRecord, but fails t on error.
+func (f *Fixture) MustScanRecord(t testing.TB, table, key string, dst any) {
+	t.Helper()
+
+	if err := f.ScanRecord(table, key, dst); err != nil {
+		t.Fatalf("failed to scan record %s.%s: %s", table, key, err)
+	}
+}
@@ -0,0 +1,48 @@
+package fixture
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fatalRecorder is a testing.TB recording Fatalf calls instead of stopping
+// the test.
+type fatalRecorder struct {
+	testing.TB
+	fatal string
+}
+
+func (r *fatalRecorder) Helper() {}
+
+func (r *fatalRecorder) Fatalf(format string, args ...any) {
+	r.fatal = fmt.Sprintf(format, args...)
+}
+
+func TestFixtureMust(t *testing.T) {
+	f := &Fixture{
+		Config:     &Config{},
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("users:\n  alice:\n    name: Alice\n    settings: {theme: dark}\n"),
+		BodyFormat: ".yaml",
+	}
+
+	f.MustApply(t)
+
+	assert.Equal(t, "Alice", f.MustGetField(t, "users", "alice", "name"))
+	assert.Equal(t, "dark", f.MustGetFieldPath(t, "users", "alice", "settings.theme"))
+
+	var user struct {
+		Name string `db:"name"`
+	}
+
+	f.MustScanRecord(t, "users", "alice", &user)
+	assert.Equal(t, "Alice", user.Name)
+
+	recorder := &fatalRecorder{TB: t}
+
+	assert.Nil(t, f.MustGetField(recorder, "users", "bob", "name"))
+	assert.Equal(t, "failed to get field users.bob.name: record not found", recorder.fatal)
+}
@@ -0,0 +1,425 @@
+package fixture
+
+import (
+	"errors"
+	"fmt"
+)
+
+// fileOptions are the options of a fixture file, set by its _options
+// directive. E.g.:
+//
+//	_options:
+//	  schema: audit
+//	  write_mode: sync
+//	  writer: analytics
+//	  tables:
+//	    events:
+//	      primary_key: event_id
+//	      defaults: {source: fixture}
+//	      base64: [payload]
+//	      omit: [search_vector]
+//	      field_aliases: {kind: event_type}
+//	      skip_existing: true
+//	      lookup_fields: [event_id]
+//
+// The top-level options apply to every table of the file, and the ones of
+// tables override them.
+type fileOptions struct {
+	// schema prefixes the table names of the file, e.g. "audit.events".
+	schema string
+
+	// defaults holds the options of every table of the file.
+	defaults *TableOptions
+
+	// tables holds the options of specific tables, by their names in the
+	// file (without schema).
+	tables map[string]*TableOptions
+}
+
+// parseFileOptions parses the value of an _options directive.
+func parseFileOptions(value any) (*fileOptions, error) {
+	raw, ok := stringMap(value)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a map, got %T", optionsDirective, value)
+	}
+
+	options := &fileOptions{
+		tables: make(map[string]*TableOptions),
+	}
+
+	if v, ok := raw["schema"]; ok {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: schema must be a string, got %T", optionsDirective, v)
+		}
+
+		options.schema = s
+	}
+
+	rawDefaults := make(map[string]any, len(raw))
+
+	for k, v := range raw {
+		if k != "schema" && k != "tables" {
+			rawDefaults[k] = v
+		}
+	}
+
+	defaults, err := parseTableOptions(rawDefaults)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", optionsDirective, err)
+	}
+
+	options.defaults = defaults
+
+	if v, ok := raw["tables"]; ok {
+		tables, ok := stringMap(v)
+		if !ok {
+			return nil, fmt.Errorf("%s: tables must be a map, got %T", optionsDirective, v)
+		}
+
+		for _, name := range sortedKeys(tables) {
+			rawTable, ok := stringMap(tables[name])
+			if !ok {
+				return nil, fmt.Errorf("%s: table %s must be a map, got %T", optionsDirective, name, tables[name])
+			}
+
+			tableOptions, err := parseTableOptions(rawTable)
+			if err != nil {
+				return nil, fmt.Errorf("%s: table %s: %w", optionsDirective, name, err)
+			}
+
+			options.tables[name] = tableOptions
+		}
+	}
+
+	return options, nil
+}
+
+// parseTableOptions parses the table options of an _options directive.
+func parseTableOptions(raw map[string]any) (*TableOptions, error) {
+	options := new(TableOptions)
+
+	for _, key := range sortedKeys(raw) {
+		value := raw[key]
+
+		switch key {
+		case "write_mode":
+			writeMode, err := parseWriteMode(value)
+			if err != nil {
+				return nil, fmt.Errorf("write_mode %w", err)
+			}
+
+			options.WriteMode = writeMode
+		case "writer", "primary_key":
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a string, got %T", key, value)
+			}
+
+			if key == "writer" {
+				options.Writer = s
+			} else {
+				options.PrimaryKeyName = s
+			}
+		case "base64", "omit", "lookup_fields":
+			list, ok := value.([]any)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a list of fields, got %T", key, value)
+			}
+
+			fields := make([]string, 0, len(list))
+
+			for _, v := range list {
+				field, ok := v.(string)
+				if !ok {
+					return nil, fmt.Errorf("%s must be a list of fields, got %T element", key, v)
+				}
+
+				fields = append(fields, field)
+			}
+
+			switch key {
+			case "base64":
+				options.Base64Fields = fields
+			case "omit":
+				options.OmitFields = fields
+			default:
+				options.LookupFields = fields
+			}
+		case "skip_existing":
+			b, ok := value.(bool)
+			if !ok {
+				return nil, fmt.Errorf("skip_existing must be a boolean, got %T", value)
+			}
+
+			options.SkipExisting = b
+		case "defaults", "dependency_values":
+			values, ok := stringMap(value)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a map, got %T", key, value)
+			}
+
+			if key == "defaults" {
+				options.DefaultValues = values
+			} else {
+				options.DependencyValues = values
+			}
+		case "field_aliases":
+			aliases, ok := stringMap(value)
+			if !ok {
+				return nil, fmt.Errorf("field_aliases must be a map, got %T", value)
+			}
+
+			options.FieldAliases = make(map[string]string, len(aliases))
+
+			for field, v := range aliases {
+				column, ok := v.(string)
+				if !ok {
+					return nil, fmt.Errorf("field alias %s must be a column name, got %T", field, v)
+				}
+
+				options.FieldAliases[field] = column
+			}
+		default:
+			return nil, fmt.Errorf("unknown option %s", key)
+		}
+	}
+
+	return options, nil
+}
+
+// parseWriteMode parses a write mode option, either "async" or "sync".
+func parseWriteMode(value any) (int, error) {
+	switch value {
+	case "async":
+		return WriteAsync, nil
+	case "sync":
+		return WriteSync, nil
+	}
+
+	return 0, fmt.Errorf("must be async or sync, got %v", value)
+}
+
+// tableName returns the name of a table of the file, prefixed with its
+// schema.
+func (o *fileOptions) tableName(name string) string {
+	if o == nil || o.schema == "" {
+		return name
+	}
+
+	return o.schema + "." + name
+}
+
+// apply records the options of the tables of database, and returns it with
+// the table names prefixed with the schema.
+func (o *fileOptions) apply(f *Fixture, database Database) Database {
+	if o == nil {
+		return database
+	}
+
+	prefixed := make(Database, len(database))
+
+	for _, name := range sortedKeys(database) {
+		options := mergeTableOptions(o.defaults, o.tables[name])
+		table := o.tableName(name)
+
+		f.tableOptions[table] = mergeTableOptions(f.tableOptions[table], options)
+		prefixed[table] = database[name]
+	}
+
+	return prefixed
+}
+
+// decodeFileOptions parses the _options directive of a decoded fixture body,
+// if any, removing it from raw.
+func decodeFileOptions(raw map[string]any) (*fileOptions, error) {
+	value, ok := raw[optionsDirective]
+	if !ok {
+		return nil, nil
+	}
+
+	delete(raw, optionsDirective)
+
+	return parseFileOptions(value)
+}
+
+// getTableOptions returns the options of a table: the ones of the config,
+// overridden by the _options of the fixture files defining the table.
+func (f *Fixture) getTableOptions(table string) *TableOptions {
+	options := f.Config.tableOptions(table)
+
+	if fileOptions, ok := f.tableOptions[table]; ok {
+		return mergeTableOptions(options, fileOptions)
+	}
+
+	return options
+}
+
+// getPrimaryKeyName is like Config.GetPrimaryKeyName, but also considers
+// the _options of the fixture files.
+func (f *Fixture) getPrimaryKeyName(table string) (string, error) {
+	if options := f.getTableOptions(table); options != nil && options.PrimaryKeyName != "" {
+		return options.PrimaryKeyName, nil
+	}
+
+	return f.Config.GetPrimaryKeyName(table)
+}
+
+// getWriter returns the writer of a table's records.
+func (f *Fixture) getWriter(table string) (Writer, error) {
+	options := f.getTableOptions(table)
+	if options == nil || options.Writer == "" {
+		if f.Writer == nil {
+			return nil, fmt.Errorf("missing writer for table %s", table)
+		}
+
+		return f.Writer, nil
+	}
+
+	w, ok := f.Writers[options.Writer]
+	if !ok {
+		return nil, fmt.Errorf("unknown writer %q for table %s", options.Writer, table)
+	}
+
+	return w, nil
+}
+
+// getRecordWriter returns the writer of a record, which can be set by its
+// _writer field.
+func (f *Fixture) getRecordWriter(table, key string) (Writer, error) {
+	options := f.recordOptions[[2]string{table, key}]
+	if options == nil || options.writer == "" {
+		return f.getWriter(table)
+	}
+
+	w, ok := f.Writers[options.writer]
+	if !ok {
+		return nil, fmt.Errorf("unknown writer %q for record %s.%s", options.writer, table, key)
+	}
+
+	return w, nil
+}
+
+// checkWriters returns the errors of the records to write without a writer,
+// e.g. naming an unknown one, so none is written.
+func (f *Fixture) checkWriters() error {
+	var errs []error
+
+	for _, table := range sortedKeys(f.Database) {
+		for _, key := range sortedKeys(f.Database[table]) {
+			if f.filtered[[2]string{table, key}] {
+				continue
+			}
+
+			if _, err := f.getRecordWriter(table, key); err != nil {
+				errs = append(errs, err)
+
+				// The other records of the table likely fail the same way.
+				break
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// mergeTableOptions returns a copy of base with the non-zero options of
+// override. Default and dependency values, field aliases and references are
+// merged.
+func mergeTableOptions(base, override *TableOptions) *TableOptions {
+	merged := new(TableOptions)
+
+	if base != nil {
+		*merged = *base
+	}
+
+	if override == nil {
+		return merged
+	}
+
+	if override.TableName != "" {
+		merged.TableName = override.TableName
+	}
+
+	if override.PrimaryKeyName != "" {
+		merged.PrimaryKeyName = override.PrimaryKeyName
+	}
+
+	if override.WriteMode != 0 {
+		merged.WriteMode = override.WriteMode
+	}
+
+	if override.Writer != "" {
+		merged.Writer = override.Writer
+	}
+
+	if len(override.DependencyValues) > 0 {
+		merged.DependencyValues = mergeMaps(merged.DependencyValues, override.DependencyValues)
+	}
+
+	if override.DoNotCreateDependencies {
+		merged.DoNotCreateDependencies = true
+	}
+
+	if len(override.Base64Fields) > 0 {
+		merged.Base64Fields = override.Base64Fields
+	}
+
+	if len(override.OmitFields) > 0 {
+		merged.OmitFields = override.OmitFields
+	}
+
+	if override.SkipExisting {
+		merged.SkipExisting = true
+	}
+
+	if len(override.LookupFields) > 0 {
+		merged.LookupFields = override.LookupFields
+	}
+
+	if len(override.FieldAliases) > 0 {
+		merged.FieldAliases = mergeMaps(merged.FieldAliases, override.FieldAliases)
+	}
+
+	if len(override.DefaultValues) > 0 {
+		merged.DefaultValues = mergeMaps(merged.DefaultValues, override.DefaultValues)
+	}
+
+	if len(override.References) > 0 {
+		merged.References = mergeMaps(merged.References, override.References)
+	}
+
+	if len(override.CompositeReferences) > 0 {
+		merged.CompositeReferences = mergeMaps(merged.CompositeReferences, override.CompositeReferences)
+	}
+
+	if override.BeforeWrite != nil {
+		merged.BeforeWrite = override.BeforeWrite
+	}
+
+	if override.PrepareWrite != nil {
+		merged.PrepareWrite = override.PrepareWrite
+	}
+
+	if override.AfterWrite != nil {
+		merged.AfterWrite = override.AfterWrite
+	}
+
+	return merged
+}
+
+// mergeMaps returns a map with the entries of base and override, the ones of
+// override taking precedence.
+func mergeMaps[V any](base, override map[string]V) map[string]V {
+	merged := make(map[string]V, len(base)+len(override))
+
+	for k, v := range base {
+		merged[k] = v
+	}
+
+	for k, v := range override {
+		merged[k] = v
+	}
+
+	return merged
+}
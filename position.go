@@ -0,0 +1,277 @@
+package fixture
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// position is a line and column of a fixture file, starting at 1.
+type position struct {
+	line   int
+	column int
+}
+
+// recordSource is where a record is defined, for RecordError.
+type recordSource struct {
+	file string
+	position
+
+	// fields holds the positions of the record's fields.
+	fields map[string]position
+}
+
+// decodedBody is a decoded fixture body, see cachedBody.
+type decodedBody struct {
+	raw map[string]any
+
+	// sources holds the positions of the records, keyed by table (empty
+	// for table files) and key. Their file is not set.
+	sources map[[2]string]*recordSource
+
+	// numbers holds the YAML numbers decoded as float64, see
+	// Config.DecimalNumbers.
+	numbers []numberLiteral
+}
+
+// addPosition adds the position of a record (path of 2 elements: table and
+// key) or of one of its fields to sources.
+func addPosition(sources map[[2]string]*recordSource, path []string, pos position) {
+	if len(path) < 2 {
+		return
+	}
+
+	label := [2]string{path[0], path[1]}
+
+	source, ok := sources[label]
+	if !ok {
+		source = new(recordSource)
+		sources[label] = source
+	}
+
+	if len(path) == 2 {
+		source.position = pos
+		return
+	}
+
+	if source.fields == nil {
+		source.fields = make(map[string]position)
+	}
+
+	source.fields[strings.Join(path[2:], ".")] = pos
+}
+
+// yamlTableSources adds the positions of the records of a YAML table node,
+// and of their (nested) fields, to sources.
+func yamlTableSources(sources map[[2]string]*recordSource, table string, node *yaml.Node) {
+	var walk func(path []string, node *yaml.Node)
+
+	walk = func(path []string, node *yaml.Node) {
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				keyNode := node.Content[i]
+				keyPath := append(path[:len(path):len(path)], keyNode.Value)
+
+				addPosition(sources, keyPath, position{line: keyNode.Line, column: keyNode.Column})
+				walk(keyPath, node.Content[i+1])
+			}
+		case yaml.SequenceNode:
+			for i, item := range node.Content {
+				itemPath := append(path[:len(path):len(path)], strconv.Itoa(i))
+
+				addPosition(sources, itemPath, position{line: item.Line, column: item.Column})
+				walk(itemPath, item)
+			}
+		}
+	}
+
+	walk([]string{table}, node)
+}
+
+// tomlSources returns the positions of the records of a TOML body, and of
+// their fields, by scanning its table headers and keys. The top-level keys
+// of table files are records, of the empty table. Multi-line values are not
+// scanned, so lines of multi-line strings looking like keys are positioned
+// too, which is harmless.
+func tomlSources(data []byte, tableFile bool) map[[2]string]*recordSource {
+	sources := make(map[[2]string]*recordSource)
+	arrays := make(map[string]int)
+
+	var header []string
+
+	for i, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+
+		var path []string
+
+		switch {
+		case strings.HasPrefix(trimmed, "[["):
+			end := strings.Index(trimmed, "]]")
+			if end < 0 {
+				continue
+			}
+
+			// The elements of arrays of tables are numbered, as nested
+			// fields such as "limits.0".
+			keys := splitTOMLKey(trimmed[2:end])
+			id := strings.Join(keys, ".")
+
+			header = append(keys, strconv.Itoa(arrays[id]))
+			arrays[id]++
+			path = header
+		case trimmed[0] == '[':
+			end := strings.LastIndex(trimmed, "]")
+			if end < 0 {
+				continue
+			}
+
+			header = splitTOMLKey(trimmed[1:end])
+			path = header
+		default:
+			key, _, ok := cutTOMLKey(trimmed)
+			if !ok {
+				continue
+			}
+
+			path = append(header[:len(header):len(header)], splitTOMLKey(key)...)
+		}
+
+		if tableFile {
+			path = append([]string{""}, path...)
+		}
+
+		addPosition(sources, path, position{
+			line:   i + 1,
+			column: len(line) - len(strings.TrimLeft(line, " \t")) + 1,
+		})
+	}
+
+	return sources
+}
+
+// cutTOMLKey splits a TOML key/value line at its "=", outside of quotes.
+func cutTOMLKey(line string) (string, string, bool) {
+	var quote byte
+
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '=':
+			return line[:i], line[i+1:], true
+		}
+	}
+
+	return "", "", false
+}
+
+// splitTOMLKey splits a dotted TOML key, e.g. users."alice.b", into its
+// unquoted parts.
+func splitTOMLKey(key string) []string {
+	var parts []string
+	var part strings.Builder
+	var quote byte
+
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				part.WriteByte(c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '.':
+			parts = append(parts, part.String())
+			part.Reset()
+		case c != ' ' && c != '\t':
+			part.WriteByte(c)
+		}
+	}
+
+	return append(parts, part.String())
+}
+
+// setRecordSources records that the records of table, named fileTable in the
+// decoded body, are defined in file.
+func (f *Fixture) setRecordSources(file, table, fileTable string, records Table, body *decodedBody) {
+	for key := range records {
+		source := &recordSource{file: file}
+
+		if s, ok := body.sources[[2]string{fileTable, key}]; ok {
+			source.position = s.position
+			source.fields = s.fields
+		}
+
+		f.recordSources[[2]string{table, key}] = source
+	}
+}
+
+// recordError returns a RecordError with the source of the record and field,
+// if known. field can be a path to a nested value, e.g. "settings.theme".
+func (f *Fixture) recordError(table, key, field string, err error) *RecordError {
+	recordErr := &RecordError{Table: table, Key: key, Field: field, Err: err}
+
+	source, ok := f.recordSources[[2]string{table, key}]
+	if !ok {
+		return recordErr
+	}
+
+	recordErr.File = source.file
+	recordErr.Line, recordErr.Column = source.line, source.column
+
+	// The error of a nested value holds its path.
+	path := field
+
+	var nested *fieldError
+
+	for e := err; errors.As(e, &nested); e = nested.err {
+		path = nested.field
+	}
+
+	// Fall back to the closest parent with a known position.
+	for path != "" {
+		if pos, ok := source.fields[path]; ok {
+			recordErr.Line, recordErr.Column = pos.line, pos.column
+			break
+		}
+
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			break
+		}
+
+		path = path[:i]
+	}
+
+	return recordErr
+}
+
+// fieldError is the error of a nested value of a field, e.g. the
+// "settings.theme" or "tags.0" field.
+type fieldError struct {
+	field string
+	err   error
+}
+
+func (e *fieldError) Error() string {
+	return fmt.Sprintf("failed to parse field %s: %s", e.field, e.err)
+}
+
+func (e *fieldError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,44 @@
+package fixture
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTOMLSources(t *testing.T) {
+	sources := tomlSources([]byte(`# Users
+[users.alice]
+name = "Alice"
+settings.theme = "dark"
+
+[users."bob.smith"]
+  email = 'bob@example.com'
+
+[plans]
+free = { name = "Free" }
+
+[[users.alice.addresses]]
+city = "Paris"
+
+[[users.alice.addresses]]
+city = "Lyon"
+`), false)
+
+	assert.Equal(t, position{2, 1}, sources[[2]string{"users", "alice"}].position)
+	assert.Equal(t, map[string]position{
+		"name":             {3, 1},
+		"settings.theme":   {4, 1},
+		"addresses.0":      {12, 1},
+		"addresses.0.city": {13, 1},
+		"addresses.1":      {15, 1},
+		"addresses.1.city": {16, 1},
+	}, sources[[2]string{"users", "alice"}].fields)
+	assert.Equal(t, map[string]position{"email": {7, 3}}, sources[[2]string{"users", "bob.smith"}].fields)
+	assert.Equal(t, position{10, 1}, sources[[2]string{"plans", "free"}].position)
+
+	sources = tomlSources([]byte("[1]\nuser_id = 2\n"), true)
+
+	assert.Equal(t, position{1, 1}, sources[[2]string{"", "1"}].position)
+	assert.Equal(t, map[string]position{"user_id": {2, 1}}, sources[[2]string{"", "1"}].fields)
+}
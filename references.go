@@ -0,0 +1,107 @@
+package fixture
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ReferenceReader is an optional interface implemented by writers that can
+// list the foreign keys of the database, used by Config.DiscoverReferences.
+type ReferenceReader interface {
+	ForeignKeys(f *Fixture) ([]ForeignKey, error)
+}
+
+// ForeignKey is a single column foreign key constraint.
+type ForeignKey struct {
+	Table  string
+	Column string
+
+	// The referenced table and column.
+	RefTable  string
+	RefColumn string
+}
+
+// discoverReferences reads the foreign keys with a writer of the fixture,
+// once per config, with DiscoverReferences. Failures aren't kept, so the next
+// fixture using the config tries again, e.g. with another writer.
+func (c *Config) discoverReferences(f *Fixture) error {
+	if !c.DiscoverReferences {
+		return nil
+	}
+
+	c.referencesMu.Lock()
+	defer c.referencesMu.Unlock()
+
+	if c.referencesRead {
+		return nil
+	}
+
+	reader, err := f.referenceReader()
+	if err != nil {
+		return err
+	}
+
+	foreignKeys, err := reader.ForeignKeys(f)
+	if err != nil {
+		return fmt.Errorf("failed to discover references: %w", err)
+	}
+
+	c.addForeignKeys(foreignKeys)
+	c.referencesRead = true
+
+	return nil
+}
+
+// referenceReader returns the Writer of the fixture if it implements
+// ReferenceReader, or else the first of its Writers, by name, that does.
+func (f *Fixture) referenceReader() (ReferenceReader, error) {
+	if reader, ok := f.Writer.(ReferenceReader); ok {
+		return reader, nil
+	}
+
+	for _, name := range sortedKeys(f.Writers) {
+		if reader, ok := f.Writers[name].(ReferenceReader); ok {
+			return reader, nil
+		}
+	}
+
+	if f.Writer == nil {
+		return nil, errors.New("no writer can discover references")
+	}
+
+	return nil, fmt.Errorf("writer %T cannot discover references", f.Writer)
+}
+
+// addForeignKeys adds foreign keys to the discovered references of their
+// table, and of the tables aliasing it. They are kept apart from the
+// TableOptions, whose references take precedence, see getReference.
+// Foreign keys to a column other than the primary key of their table are
+// skipped, as their values are rarely record keys; they can be declared as
+// "table.column" references by hand.
+func (c *Config) addForeignKeys(foreignKeys []ForeignKey) {
+	c.discoveredReferences = make(map[string]map[string]string)
+
+	for _, fk := range foreignKeys {
+		if pk, err := c.GetPrimaryKeyName(fk.RefTable); err != nil || pk != fk.RefColumn {
+			continue
+		}
+
+		tables := []string{fk.Table}
+
+		for _, table := range sortedKeys(c.tableAliases) {
+			if c.tableAliases[table] == fk.Table {
+				tables = append(tables, table)
+			}
+		}
+
+		for _, table := range tables {
+			references := c.discoveredReferences[table]
+			if references == nil {
+				references = make(map[string]string)
+				c.discoveredReferences[table] = references
+			}
+
+			references[fk.Column] = fk.RefTable
+		}
+	}
+}
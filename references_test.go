@@ -0,0 +1,111 @@
+package fixture
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// foreignKeyWriter is a memoryWriter with the foreign keys of its database.
+type foreignKeyWriter struct {
+	memoryWriter
+	foreignKeys []ForeignKey
+}
+
+func (w *foreignKeyWriter) ForeignKeys(f *Fixture) ([]ForeignKey, error) {
+	if err := f.Context.Err(); err != nil {
+		return nil, err
+	}
+
+	f.Logger.Debug().Msg("reading foreign keys")
+
+	return w.foreignKeys, nil
+}
+
+func TestFixtureDiscoverReferences(t *testing.T) {
+	config := &Config{
+		DiscoverReferences: true,
+		Strict:             true,
+		TableOptions: map[string]*TableOptions{
+			// Set by hand, not dereferenced.
+			"orders": {References: map[string]string{"coupon_id": ""}},
+		},
+	}
+
+	writer := &foreignKeyWriter{foreignKeys: []ForeignKey{
+		{Table: "orders", Column: "user_id", RefTable: "users", RefColumn: "id"},
+		{Table: "orders", Column: "coupon_id", RefTable: "coupons", RefColumn: "id"},
+		{Table: "orders", Column: "user_email", RefTable: "users", RefColumn: "email"},
+		// Of a table without records, not an unused table options.
+		{Table: "payments", Column: "order_id", RefTable: "orders", RefColumn: "id"},
+	}}
+
+	f := &Fixture{
+		Config: config,
+		Writer: writer,
+		Body: strings.NewReader(`users:
+  alice: {}
+orders:
+  1:
+    user_id: alice
+    coupon_id: SUMMER
+    user_email: alice@example.com
+`),
+		BodyFormat: ".yaml",
+	}
+
+	f.MustApply(t)
+
+	// The TableOptions are left untouched.
+	assert.Equal(t, map[string]*TableOptions{"orders": {References: map[string]string{"coupon_id": ""}}}, config.TableOptions)
+	assert.Equal(t, []string{"users.alice", "orders.1"}, writer.inserted)
+
+	userID, err := f.GetField("users", "alice", "id")
+	assert.NoError(t, err)
+
+	order := f.Database["orders"]["1"]
+	assert.Equal(t, userID, order["user_id"])
+	assert.Equal(t, "SUMMER", order["coupon_id"])
+	assert.Equal(t, "alice@example.com", order["user_email"])
+
+	config = &Config{DiscoverReferences: true}
+
+	f = &Fixture{
+		Config:     config,
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("users:\n  alice: {}\n"),
+		BodyFormat: ".yaml",
+	}
+
+	assert.EqualError(t, f.Apply(), "writer *fixture.memoryWriter cannot discover references")
+
+	f = &Fixture{
+		Config:     config,
+		Writers:    map[string]Writer{"memory": &memoryWriter{}},
+		Body:       strings.NewReader("users:\n  alice: {}\n"),
+		BodyFormat: ".yaml",
+	}
+
+	assert.EqualError(t, f.Apply(), "no writer can discover references")
+
+	// The failures aren't kept by the config, and the reader can be one of
+	// Writers.
+	writer = &foreignKeyWriter{foreignKeys: []ForeignKey{
+		{Table: "orders", Column: "user_id", RefTable: "users", RefColumn: "id"},
+	}}
+
+	f = &Fixture{
+		Config: config,
+		Writers: map[string]Writer{
+			"memory":   &memoryWriter{},
+			"postgres": writer,
+		},
+		Body:       strings.NewReader("_options:\n  tables:\n    users: {writer: postgres}\n    orders: {writer: postgres}\nusers:\n  alice: {}\norders:\n  1:\n    user_id: alice\n"),
+		BodyFormat: ".yaml",
+	}
+
+	f.MustApply(t)
+
+	assert.Equal(t, f.MustGetField(t, "users", "alice", "id"), f.Database["orders"]["1"]["user_id"])
+}
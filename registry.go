@@ -0,0 +1,172 @@
+package fixture
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrFixtureNameTaken is returned by Apply when another fixture is already
+// published under the same name in the same registry namespace.
+var ErrFixtureNameTaken = errors.New("fixture name already taken")
+
+// Registry shares the records of applied fixtures, so other fixtures can
+// reference them with the =xref command. Fixtures with a Name and a Registry
+// are published to it when applied. E.g.:
+//
+//	registry := fixture.NewRegistry()
+//
+//	users := &fixture.Fixture{Name: "users", Registry: registry, ...}
+//	users.MustApply(t)
+//
+//	orders := &fixture.Fixture{Registry: registry, ...}
+//
+// with `user_id: =xref users users alice` in the orders fixture.
+//
+// Registries are safe for concurrent use, e.g. by the parallel tests of a
+// package. The =xref commands of a fixture read the records published by the
+// last Apply of the referenced fixture, and fail while it is being applied,
+// so fixtures referencing each other can't deadlock.
+//
+// Registries are in-process only: test packages, run as separate processes
+// by go test, don't share them, even with DefaultRegistry. Fixtures can only
+// reference the fixtures applied by the same test binary.
+type Registry struct {
+	store *registryStore
+
+	// namespace prefixes the names of the registry's fixtures, see
+	// Namespace.
+	namespace string
+	parent    *Registry
+}
+
+// registryStore holds the fixtures of a registry and its namespaces.
+type registryStore struct {
+	mu       sync.RWMutex
+	fixtures map[string]*Fixture
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{store: &registryStore{fixtures: make(map[string]*Fixture)}}
+}
+
+// DefaultRegistry is a Registry shared by the fixtures of a process that set
+// it, i.e. of a single test package.
+var DefaultRegistry = NewRegistry()
+
+// Namespace returns a registry whose fixtures are published under id, e.g.
+// a test name, so the parallel tests of a process can publish fixtures with
+// the same names without clobbering each other. Its fixtures can reference the
+// ones of r (and of the parents of r), unless they have the same names.
+// E.g.:
+//
+//	registry := fixture.DefaultRegistry.Namespace(t.Name())
+func (r *Registry) Namespace(id string) *Registry {
+	return &Registry{
+		store:     r.store,
+		namespace: r.namespace + id + "/",
+		parent:    r,
+	}
+}
+
+// Fixture returns the fixture published under name in the registry, or else
+// in its parents, if any.
+func (r *Registry) Fixture(name string) (*Fixture, bool) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for registry := r; registry != nil; registry = registry.parent {
+		if f, ok := r.store.fixtures[registry.namespace+name]; ok {
+			return f, true
+		}
+	}
+
+	return nil, false
+}
+
+// Remove unpublishes the fixture published under name in the registry, e.g.
+// in a test cleanup.
+func (r *Registry) Remove(name string) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	delete(r.store.fixtures, r.namespace+name)
+}
+
+// publish publishes a fixture under name, unless another fixture is.
+func (r *Registry) publish(name string, f *Fixture) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if published, ok := r.store.fixtures[r.namespace+name]; ok && published != f {
+		return fmt.Errorf("%s: %w", name, ErrFixtureNameTaken)
+	}
+
+	r.store.fixtures[r.namespace+name] = f
+
+	return nil
+}
+
+// xrefCommand references a record of a fixture published to the registry
+// of the fixture, given its name, table, key and optional field, which
+// defaults to the table's primary key in that fixture. E.g.:
+//
+//	user_id: =xref users users alice
+//	user_email: =xref users users alice email
+//
+// The record is already written, so the referencing record doesn't depend
+// on any other.
+func xrefCommand(in *CommandInput) (*CommandOutput, error) {
+	args, _, err := in.ScanLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan command line: %w", err)
+	}
+
+	if len(args) < 3 || len(args) > 4 {
+		return nil, fmt.Errorf("expected 3 or 4 positional arguments, got %d", len(args))
+	}
+
+	name, table, key := args[0], args[1], args[2]
+
+	if in.Fixture.Registry == nil {
+		return nil, fmt.Errorf("fixture has no registry to look up %s", name)
+	}
+
+	other, ok := in.Fixture.Registry.Fixture(name)
+	if !ok {
+		return nil, fmt.Errorf("fixture %s is not published", name)
+	}
+
+	if other == in.Fixture {
+		return nil, fmt.Errorf("fixture %s cannot reference itself, use =ref", name)
+	}
+
+	// Only the published state is read, so other can be applying.
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+
+	other = other.view()
+
+	if !other.applied {
+		return nil, fmt.Errorf("fixture %s is not applied", name)
+	}
+
+	var field string
+
+	if len(args) == 4 {
+		field = args[3]
+	} else {
+		field, err = other.getPrimaryKeyName(table)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	v, err := other.getFieldPath(table, other.resolveKey(table, key), field)
+	if err != nil {
+		return nil, fmt.Errorf("fixture %s, %s.%s.%s: %w", name, table, key, field, err)
+	}
+
+	return &CommandOutput{Value: v}, nil
+}
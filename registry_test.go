@@ -0,0 +1,156 @@
+package fixture
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixtureXref(t *testing.T) {
+	registry := NewRegistry()
+
+	users := &Fixture{
+		Name:       "users",
+		Registry:   registry,
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("users:\n  alice:\n    email: alice@example.com\n  bob: {}\n"),
+		BodyFormat: ".yaml",
+	}
+
+	users.MustApply(t)
+
+	published, ok := registry.Fixture("users")
+	assert.True(t, ok)
+	assert.Same(t, users, published)
+
+	orders := &Fixture{
+		Registry: registry,
+		Writer:   &memoryWriter{},
+		Body: strings.NewReader(`orders:
+  1:
+    user_id: =xref users users bob
+    user_email: =xref users users alice email
+`),
+		BodyFormat: ".yaml",
+	}
+
+	orders.MustApply(t)
+
+	bobID, err := users.GetField("users", "bob", "id")
+	assert.NoError(t, err)
+
+	assert.Equal(t, bobID, orders.Database["orders"]["1"]["user_id"])
+	assert.Equal(t, "alice@example.com", orders.Database["orders"]["1"]["user_email"])
+
+	for line, expected := range map[string]string{
+		"=xref accounts users bob":    "fixture accounts is not published",
+		"=xref users users carol":     "fixture users, users.carol.id: record not found",
+		"=xref users users bob email": "fixture users, users.bob.email: field not found",
+		"=xref users users":           "expected 3 or 4 positional arguments, got 2",
+	} {
+		f := &Fixture{
+			Registry:   registry,
+			Writer:     &memoryWriter{},
+			Body:       strings.NewReader("orders:\n  1:\n    user_id: " + line + "\n"),
+			BodyFormat: ".yaml",
+		}
+
+		assert.ErrorContains(t, f.Apply(), expected, line)
+	}
+}
+
+func TestFixtureXrefApplying(t *testing.T) {
+	registry := NewRegistry()
+
+	orders := &Fixture{
+		Name:       "orders",
+		Registry:   registry,
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("orders:\n  1:\n    user_id: =xref users users alice\n"),
+		BodyFormat: ".yaml",
+	}
+
+	users := &Fixture{
+		Name:       "users",
+		Registry:   registry,
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("users:\n  alice:\n    last_order: =orders\n"),
+		BodyFormat: ".yaml",
+		Commands: map[string]CommandFunc{
+			// Applies a fixture referencing users while it is applying.
+			"orders": func(in *CommandInput) (*CommandOutput, error) {
+				return &CommandOutput{Value: nil}, orders.Apply()
+			},
+		},
+	}
+
+	assert.ErrorContains(t, users.Apply(), "fixture users is not applied")
+	assert.False(t, orders.Applied())
+}
+
+func TestRegistryNamespaces(t *testing.T) {
+	run := NewRegistry()
+
+	seed := &Fixture{
+		Name:       "seed",
+		Registry:   run,
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("plans:\n  pro: {}\n"),
+		BodyFormat: ".yaml",
+	}
+
+	seed.MustApply(t)
+
+	var wg sync.WaitGroup
+
+	for _, id := range []string{"a", "b", "c"} {
+		registry := run.Namespace(id)
+
+		users := &Fixture{
+			Name:       "users",
+			Registry:   registry,
+			Writer:     &memoryWriter{},
+			Body:       strings.NewReader("users:\n  alice:\n    name: " + id + "\n    plan_id: =xref seed plans pro\n"),
+			BodyFormat: ".yaml",
+		}
+
+		orders := &Fixture{
+			Registry:   registry,
+			Writer:     &memoryWriter{},
+			Body:       strings.NewReader("orders:\n  1:\n    user_name: =xref users users alice name\n"),
+			BodyFormat: ".yaml",
+		}
+
+		wg.Add(1)
+
+		go func(id string) {
+			defer wg.Done()
+
+			assert.NoError(t, users.Apply())
+			assert.NoError(t, orders.Apply())
+
+			assert.Equal(t, int64(1), users.Database["users"]["alice"]["plan_id"])
+			assert.Equal(t, id, orders.Database["orders"]["1"]["user_name"])
+		}(id)
+	}
+
+	wg.Wait()
+
+	_, ok := run.Fixture("users")
+	assert.False(t, ok)
+
+	other := &Fixture{
+		Name:       "users",
+		Registry:   run.Namespace("a"),
+		Writer:     &memoryWriter{},
+		Body:       strings.NewReader("users:\n  bob: {}\n"),
+		BodyFormat: ".yaml",
+	}
+
+	assert.ErrorIs(t, other.Apply(), ErrFixtureNameTaken)
+
+	run.Namespace("a").Remove("users")
+	other.MustApply(t)
+}
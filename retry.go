@@ -0,0 +1,111 @@
+package fixture
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Retry retries the writer operations failing with transient errors, see
+// Config.Retry.
+type Retry struct {
+	// The maximum number of attempts of an operation, including the first.
+	// Default: 3
+	Attempts int
+
+	// The delay before the second attempt, doubled after each attempt.
+	// Default: 100ms
+	Backoff time.Duration
+
+	// The maximum delay between attempts.
+	// Default: no maximum
+	MaxBackoff time.Duration
+
+	// Retryable returns whether an operation failing with err can be retried.
+	// Default: IsRetryable
+	Retryable func(err error) bool
+}
+
+// IsRetryable returns whether err is a transient Postgres error: a
+// serialization failure, a deadlock, a connection exception or an error
+// pgconn reports as safe to retry, such as a failure to connect. Network
+// timeouts are retryable too.
+func IsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == "40001", pgErr.Code == "40P01":
+			// serialization_failure, deadlock_detected
+			return true
+		case strings.HasPrefix(pgErr.Code, "08"):
+			// connection_exception class
+			return true
+		}
+
+		return false
+	}
+
+	var safeToRetry interface{ SafeToRetry() bool }
+
+	if errors.As(err, &safeToRetry) && safeToRetry.SafeToRetry() {
+		return true
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// retry runs op, retrying it according to Config.Retry. It stops waiting
+// for the next attempt when the fixture's context is done.
+func (f *Fixture) retry(op func() error) error {
+	retry := f.Config.Retry
+
+	err := op()
+	if err == nil || retry == nil {
+		return err
+	}
+
+	attempts := retry.Attempts
+	if attempts == 0 {
+		attempts = 3
+	}
+
+	backoff := retry.Backoff
+	if backoff == 0 {
+		backoff = 100 * time.Millisecond
+	}
+
+	retryable := retry.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	for attempt := 2; attempt <= attempts && retryable(err); attempt++ {
+		f.Logger.Debug().
+			Err(err).
+			Int("attempt", attempt).
+			Dur("backoff", backoff).
+			Msg("retrying writer operation")
+
+		if sleepErr := sleep(f.Context, backoff); sleepErr != nil {
+			return errors.Join(err, sleepErr)
+		}
+
+		if err = op(); err == nil {
+			return nil
+		}
+
+		backoff *= 2
+
+		if retry.MaxBackoff > 0 && backoff > retry.MaxBackoff {
+			backoff = retry.MaxBackoff
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,79 @@
+package fixture
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyWriter fails the first inserts with err.
+type flakyWriter struct {
+	memoryWriter
+	failures int
+	attempts int
+	err      error
+}
+
+func (w *flakyWriter) Insert(f *Fixture, table string, key string, record Record) error {
+	w.attempts++
+
+	if w.attempts <= w.failures {
+		return fmt.Errorf("failed query database: %w", w.err)
+	}
+
+	return w.memoryWriter.Insert(f, table, key, record)
+}
+
+func TestFixtureRetry(t *testing.T) {
+	serializationErr := &pgconn.PgError{Code: "40001"}
+
+	apply := func(writer *flakyWriter, retry *Retry) error {
+		f := &Fixture{
+			Config:     &Config{Retry: retry},
+			Writer:     writer,
+			Body:       strings.NewReader("users:\n  alice:\n    name: Alice\n"),
+			BodyFormat: ".yaml",
+		}
+
+		return f.Apply()
+	}
+
+	writer := &flakyWriter{failures: 2, err: serializationErr}
+	assert.NoError(t, apply(writer, &Retry{Backoff: time.Millisecond}))
+	assert.Equal(t, 3, writer.attempts)
+	assert.Equal(t, []string{"users.alice"}, writer.inserted)
+
+	writer = &flakyWriter{failures: 3, err: serializationErr}
+	assert.ErrorIs(t, apply(writer, &Retry{Backoff: time.Millisecond}), serializationErr)
+	assert.Equal(t, 3, writer.attempts)
+
+	writer = &flakyWriter{failures: 1, err: serializationErr}
+	assert.Error(t, apply(writer, nil))
+	assert.Equal(t, 1, writer.attempts)
+
+	writer = &flakyWriter{failures: 1, err: &pgconn.PgError{Code: "23505"}}
+	assert.Error(t, apply(writer, &Retry{Backoff: time.Millisecond}))
+	assert.Equal(t, 1, writer.attempts)
+
+	errCustom := errors.New("custom")
+	writer = &flakyWriter{failures: 4, err: errCustom}
+	assert.NoError(t, apply(writer, &Retry{
+		Attempts:  5,
+		Backoff:   time.Millisecond,
+		Retryable: func(err error) bool { return errors.Is(err, errCustom) },
+	}))
+	assert.Equal(t, 5, writer.attempts)
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(&pgconn.PgError{Code: "40001"}))
+	assert.True(t, IsRetryable(fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: "40P01"})))
+	assert.True(t, IsRetryable(&pgconn.PgError{Code: "08006"}))
+	assert.False(t, IsRetryable(&pgconn.PgError{Code: "23505"}))
+	assert.False(t, IsRetryable(errors.New("failed")))
+}
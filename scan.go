@@ -0,0 +1,214 @@
+package fixture
+
+import (
+	"database/sql"
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ScanRecord converts a record into dst, a pointer to a struct. Fields are
+// matched by their db tag, json tag or name, in this order, ignoring case if
+// there is no exact match. E.g.:
+//
+//	var user struct {
+//		ID    int64     `db:"id"`
+//		Email string    `json:"email"`
+//		Team  uuid.UUID `db:"team_id"`
+//	}
+//
+//	err := f.ScanRecord("users", "alice", &user)
+//
+// Values are converted when possible: between numeric types, from strings to
+// encoding.TextUnmarshaler (e.g. time.Time or uuid.UUID), into sql.Scanner
+// fields, and recursively for nested structs, maps and slices.
+func (f *Fixture) ScanRecord(table, key string, dst any) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	database := f.view().Database
+	if database == nil {
+		return ErrDatabaseNotFound
+	}
+
+	tableItem, ok := database[table]
+	if !ok {
+		return ErrTableNotFound
+	}
+
+	record, ok := tableItem[key]
+	if !ok {
+		return ErrRecordNotFound
+	}
+
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("dst must be a non-nil pointer to a struct, got %T", dst)
+	}
+
+	if err := scanStruct(rv.Elem(), record); err != nil {
+		return fmt.Errorf("failed to scan %s.%s: %w", table, key, err)
+	}
+
+	return nil
+}
+
+func scanStruct(dst reflect.Value, src map[string]any) error {
+	dstType := dst.Type()
+
+	for i := 0; i < dstType.NumField(); i++ {
+		field := dstType.Field(i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := scanStruct(dst.Field(i), src); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		name := scanFieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		value, ok := src[name]
+		if !ok {
+			for k, v := range src {
+				if strings.EqualFold(k, name) {
+					value, ok = v, true
+					break
+				}
+			}
+		}
+
+		if !ok {
+			continue
+		}
+
+		if err := scanValue(dst.Field(i), value); err != nil {
+			return fmt.Errorf("field %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// scanFieldName returns the record field name of a struct field.
+func scanFieldName(field reflect.StructField) string {
+	for _, tag := range []string{"db", "json"} {
+		if name, _, _ := strings.Cut(field.Tag.Get(tag), ","); name != "" {
+			return name
+		}
+	}
+
+	return field.Name
+}
+
+var errUnsupportedScan = errors.New("unsupported conversion")
+
+func scanValue(dst reflect.Value, src any) error {
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	srcValue := reflect.ValueOf(src)
+
+	if srcValue.Type().AssignableTo(dst.Type()) {
+		dst.Set(srcValue)
+		return nil
+	}
+
+	if dst.Kind() == reflect.Array && srcValue.CanConvert(dst.Type()) {
+		// E.g. [16]uint8 values into uuid.UUID fields.
+		dst.Set(srcValue.Convert(dst.Type()))
+		return nil
+	}
+
+	if dst.CanAddr() {
+		switch t := dst.Addr().Interface().(type) {
+		case sql.Scanner:
+			return t.Scan(src)
+		case encoding.TextUnmarshaler:
+			if s, ok := src.(string); ok {
+				return t.UnmarshalText([]byte(s))
+			}
+		}
+	}
+
+	switch dst.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(dst.Type().Elem())
+
+		if err := scanValue(elem.Elem(), src); err != nil {
+			return err
+		}
+
+		dst.Set(elem)
+
+		return nil
+	case reflect.Struct:
+		if m, ok := stringMap(src); ok {
+			return scanStruct(dst, m)
+		}
+	case reflect.Map:
+		m, ok := stringMap(src)
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			break
+		}
+
+		dstMap := reflect.MakeMapWithSize(dst.Type(), len(m))
+
+		for k, v := range m {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+
+			if err := scanValue(elem, v); err != nil {
+				return fmt.Errorf("key %s: %w", k, err)
+			}
+
+			dstMap.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), elem)
+		}
+
+		dst.Set(dstMap)
+
+		return nil
+	case reflect.Slice:
+		l, ok := src.([]any)
+		if !ok {
+			break
+		}
+
+		dstSlice := reflect.MakeSlice(dst.Type(), len(l), len(l))
+
+		for i := range l {
+			if err := scanValue(dstSlice.Index(i), l[i]); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+
+		dst.Set(dstSlice)
+
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if _, ok := numberValue(src); ok && srcValue.CanConvert(dst.Type()) {
+			dst.Set(srcValue.Convert(dst.Type()))
+			return nil
+		}
+	case reflect.String:
+		if b, ok := src.([]byte); ok {
+			dst.SetString(string(b))
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w from %T to %s", errUnsupportedScan, src, dst.Type())
+}
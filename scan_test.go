@@ -0,0 +1,85 @@
+package fixture
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixtureScanRecord(t *testing.T) {
+	teamID := uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	f := &Fixture{
+		Config: &Config{},
+		Writer: &memoryWriter{},
+		Body: strings.NewReader(`
+users:
+  alice:
+    email: alice@example.com
+    Nickname: ali
+    born_at: 2000-01-02T03:04:05Z
+    score: 1.5
+    tags: [a, b]
+    settings: {theme: dark}
+    address: {city: Lisbon}
+    bio: null
+`),
+		BodyFormat: ".yaml",
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	// As returned by pgx.
+	f.Database["users"]["alice"]["team_id"] = [16]uint8(teamID)
+	f.Database["users"]["alice"]["avatar"] = []byte("png")
+
+	type Base struct {
+		ID int32 `db:"id"`
+	}
+
+	var user struct {
+		Base
+		Email    string            `json:"email,omitempty"`
+		Nickname string            `db:"nickname"`
+		BornAt   time.Time         `db:"born_at"`
+		Score    float32           `db:"score"`
+		Tags     []string          `db:"tags"`
+		Settings map[string]string `db:"settings"`
+		Address  *struct {
+			City string `json:"city"`
+		} `db:"address"`
+		Bio     sql.NullString `db:"bio"`
+		TeamID  uuid.UUID      `db:"team_id"`
+		Avatar  string         `db:"avatar"`
+		Ignored string         `db:"-"`
+	}
+
+	if err := f.ScanRecord("users", "alice", &user); err != nil {
+		t.Fatalf("failed to scan record: %s", err)
+	}
+
+	assert.Equal(t, int32(1), user.ID)
+	assert.Equal(t, "alice@example.com", user.Email)
+	assert.Equal(t, "ali", user.Nickname)
+	assert.Equal(t, time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC), user.BornAt)
+	assert.Equal(t, float32(1.5), user.Score)
+	assert.Equal(t, []string{"a", "b"}, user.Tags)
+	assert.Equal(t, map[string]string{"theme": "dark"}, user.Settings)
+	assert.Equal(t, "Lisbon", user.Address.City)
+	assert.False(t, user.Bio.Valid)
+	assert.Equal(t, teamID, user.TeamID)
+	assert.Equal(t, "png", user.Avatar)
+
+	var invalid struct {
+		Email int `db:"email"`
+	}
+
+	assert.ErrorContains(t, f.ScanRecord("users", "alice", &invalid), "field email: unsupported conversion from string to int")
+	assert.ErrorIs(t, f.ScanRecord("users", "bob", &invalid), ErrRecordNotFound)
+	assert.ErrorContains(t, f.ScanRecord("users", "alice", invalid), "dst must be a non-nil pointer to a struct")
+}
@@ -0,0 +1,208 @@
+package fixture
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SchemaReader is an optional interface implemented by writers that can list
+// the columns of a table, used by Config.ValidateSchema and
+// Config.CoerceValues. Columns returns ErrTableNotFound if the table does not
+// exist.
+type SchemaReader interface {
+	Columns(f *Fixture, table string) ([]Column, error)
+}
+
+// Column is a column of a database table.
+type Column struct {
+	Name string
+
+	// Type is the SQL type of the column, as named by information_schema,
+	// e.g. "integer" or "timestamp with time zone", suffixed by "[]" for
+	// arrays, e.g. "uuid[]".
+	Type string
+
+	// Values are the labels of the column's enum type, if any.
+	Values []string
+}
+
+// checkEnum returns an error if v is not one of the labels of the column's
+// enum type.
+func (c Column) checkEnum(v any) error {
+	if len(c.Values) == 0 {
+		return nil
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		// E.g. nil or Raw values.
+		return nil
+	}
+
+	for _, label := range c.Values {
+		if s == label {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid value %q for enum %s, expected one of: %s", s, c.Type, strings.Join(c.Values, ", "))
+}
+
+// columnsKey is the key of Fixture.columns.
+type columnsKey struct {
+	reader SchemaReader
+	table  string
+}
+
+// getColumns returns the columns of a table by name, read once per Apply.
+func (f *Fixture) getColumns(reader SchemaReader, table string) (map[string]Column, error) {
+	cacheKey := columnsKey{reader: reader, table: table}
+
+	if columns, ok := f.columns[cacheKey]; ok {
+		return columns, nil
+	}
+
+	list, err := reader.Columns(f, table)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]Column, len(list))
+
+	for _, column := range list {
+		columns[column.Name] = column
+	}
+
+	f.columns[cacheKey] = columns
+
+	return columns, nil
+}
+
+// validateSchema returns, with Config.ValidateSchema, the errors of the
+// tables and columns of the records to write missing from the database, and
+// of their values not allowed by enum columns. Tables whose writer is not a
+// SchemaReader are not checked.
+func (f *Fixture) validateSchema() error {
+	if !f.Config.ValidateSchema {
+		return nil
+	}
+
+	var errs []error
+
+	for _, table := range sortedKeys(f.Database) {
+		tableOptions := f.getTableOptions(table)
+
+		// The columns and keys of the table's records, by writer.
+		columns := make(map[SchemaReader]map[string]bool)
+		keys := make(map[SchemaReader][]string)
+		var readers []SchemaReader
+
+		for _, key := range sortedKeys(f.Database[table]) {
+			if f.filtered[[2]string{table, key}] {
+				continue
+			}
+
+			writer, err := f.getRecordWriter(table, key)
+			if err != nil {
+				return err
+			}
+
+			reader, ok := writer.(SchemaReader)
+			if !ok {
+				continue
+			}
+
+			if _, ok := columns[reader]; !ok {
+				columns[reader] = make(map[string]bool)
+				readers = append(readers, reader)
+			}
+
+			for field := range f.Database[table][key] {
+				columns[reader][f.columnName(tableOptions, field)] = true
+			}
+
+			keys[reader] = append(keys[reader], key)
+		}
+
+		for _, reader := range readers {
+			existing, err := f.getColumns(reader, table)
+			if errors.Is(err, ErrTableNotFound) {
+				errs = append(errs, fmt.Errorf("table %s does not exist", table))
+				continue
+			} else if err != nil {
+				return fmt.Errorf("failed to get columns of table %s: %w", table, err)
+			}
+
+			for _, column := range sortedKeys(columns[reader]) {
+				if _, ok := existing[column]; !ok {
+					errs = append(errs, fmt.Errorf("table %s, column %s does not exist", table, column))
+				}
+			}
+
+			for _, key := range keys[reader] {
+				record := f.Database[table][key]
+
+				for _, field := range sortedKeys(record) {
+					column := f.columnName(tableOptions, field)
+
+					if err := existing[column].checkEnum(record[field]); err != nil {
+						errs = append(errs, fmt.Errorf("table %s, key %s, column %s: %w", table, key, column, err))
+					}
+				}
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid schema:\n%w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// columnName returns the name of the column of a field, see
+// TableOptions.FieldAliases and Config.ColumnName.
+func (f *Fixture) columnName(tableOptions *TableOptions, field string) string {
+	if tableOptions != nil {
+		if column, ok := tableOptions.FieldAliases[field]; ok {
+			return column
+		}
+	}
+
+	if f.Config.ColumnName != nil {
+		return f.Config.ColumnName(field)
+	}
+
+	return field
+}
+
+// columnNames returns the column names of the fields of a record named
+// differently, by field.
+func (f *Fixture) columnNames(tableOptions *TableOptions, record Record) map[string]string {
+	var columns map[string]string
+
+	for field := range record {
+		if column := f.columnName(tableOptions, field); column != field {
+			if columns == nil {
+				columns = make(map[string]string)
+			}
+
+			columns[field] = column
+		}
+	}
+
+	return columns
+}
+
+// columnRecord returns a copy of a record with the column names of its
+// fields.
+func (f *Fixture) columnRecord(tableOptions *TableOptions, record Record) Record {
+	columns := make(Record, len(record))
+
+	for field, v := range record {
+		columns[f.columnName(tableOptions, field)] = v
+	}
+
+	return columns
+}
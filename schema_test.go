@@ -0,0 +1,110 @@
+package fixture
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// schemaWriter is a memoryWriter with the columns of its tables.
+type schemaWriter struct {
+	memoryWriter
+	tables map[string][]Column
+}
+
+func (w *schemaWriter) Columns(f *Fixture, table string) ([]Column, error) {
+	columns, ok := w.tables[table]
+	if !ok {
+		return nil, ErrTableNotFound
+	}
+
+	return columns, nil
+}
+
+func TestFixtureValidateSchema(t *testing.T) {
+	body := `users:
+  alice:
+    name: Alice
+    emial: alice@example.com
+orders:
+  1:
+    user_id: =ref users alice
+    totl: 10
+comments:
+  1:
+    body: Hello
+`
+
+	writer := &schemaWriter{tables: map[string][]Column{
+		"users":  {{Name: "id"}, {Name: "name"}, {Name: "email"}},
+		"orders": {{Name: "id"}, {Name: "user_id"}, {Name: "total"}},
+	}}
+
+	f := &Fixture{
+		Config:     &Config{ValidateSchema: true},
+		Writer:     writer,
+		Body:       strings.NewReader(body),
+		BodyFormat: ".yaml",
+	}
+
+	assert.EqualError(t, f.Apply(), `invalid schema:
+table comments does not exist
+table orders, column totl does not exist
+table users, column emial does not exist`)
+
+	// Nothing is written.
+	assert.Empty(t, writer.records)
+
+	f = &Fixture{
+		Config:     &Config{ValidateSchema: true},
+		Writer:     writer,
+		Body:       strings.NewReader("users:\n  alice:\n    name: Alice\n"),
+		BodyFormat: ".yaml",
+	}
+
+	f.MustApply(t)
+
+	// Aliased fields are checked by their column names.
+	f = &Fixture{
+		Config: &Config{
+			ValidateSchema: true,
+			TableOptions: map[string]*TableOptions{
+				"users": {FieldAliases: map[string]string{"mail": "email"}},
+			},
+		},
+		Writer:     writer,
+		Body:       strings.NewReader("users:\n  alice:\n    mail: alice@example.com\n"),
+		BodyFormat: ".yaml",
+	}
+
+	f.MustApply(t)
+}
+
+func TestFixtureValidateSchemaEnums(t *testing.T) {
+	writer := &schemaWriter{tables: map[string][]Column{
+		"orders": {
+			{Name: "id"},
+			{Name: "status", Type: "order_status", Values: []string{"pending", "paid", "shipped"}},
+		},
+	}}
+
+	f := &Fixture{
+		Config:     &Config{ValidateSchema: true},
+		Writer:     writer,
+		Body:       strings.NewReader("orders:\n  1:\n    status: paid\n  2:\n    status: payed\n"),
+		BodyFormat: ".yaml",
+	}
+
+	assert.EqualError(t, f.Apply(), `invalid schema:
+table orders, key 2, column status: invalid value "payed" for enum order_status, expected one of: pending, paid, shipped`)
+
+	f = &Fixture{
+		Config:     &Config{CoerceValues: true},
+		Writer:     writer,
+		Body:       strings.NewReader("orders:\n  1:\n    status: lost\n"),
+		BodyFormat: ".yaml",
+	}
+
+	assert.EqualError(t, f.Apply(), `failed to coerce record "orders"."1": column status: invalid value "lost" for enum order_status, expected one of: pending, paid, shipped`)
+}
@@ -0,0 +1,387 @@
+package fixture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/jackc/pgx/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// SnapshotConn is the connection Snapshot reads from, e.g. a *pgx.Conn,
+// *pgxpool.Pool or pgx.Tx.
+type SnapshotConn interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// snapshotTable holds the rows and constraints of a table read by Snapshot.
+type snapshotTable struct {
+	name string
+
+	// primaryKey is the primary key column, empty if the table has none or
+	// a composite one.
+	primaryKey string
+
+	// foreignKeys maps the single column foreign keys of the table to the
+	// table and column they reference.
+	foreignKeys map[string][2]string
+
+	rows []Record
+}
+
+// Snapshot reads the records of the given Postgres tables, or of every table
+// of the public schema if none is given, as a fixture Database. Records are
+// keyed by their primary key, and foreign keys to the snapshot's records are
+// rewritten as =ref commands. Strings starting with "=" are escaped as "==",
+// so they are loaded as is. See WriteTableFiles.
+func Snapshot(ctx context.Context, conn SnapshotConn, tables ...string) (Database, error) {
+	snapshotTables, err := readSnapshotTables(ctx, conn, tables)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshotDatabase(snapshotTables, nil)
+}
+
+// Snapshot is like the Snapshot function, but names the fields of the
+// records as the fixture files of the config do: columns aliased by the
+// TableOptions.FieldAliases of their table are renamed to their fields.
+// ColumnName can't be inverted, so the other columns are kept, and the
+// snapshot fails if ColumnName would write them to another column.
+func (c *Config) Snapshot(ctx context.Context, conn SnapshotConn, tables ...string) (Database, error) {
+	snapshotTables, err := readSnapshotTables(ctx, conn, tables)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshotDatabase(snapshotTables, c.fieldName)
+}
+
+// fieldName returns the name of the field of a column, the inverse of
+// Fixture.columnName.
+func (c *Config) fieldName(table, column string) (string, error) {
+	if options := c.tableOptions(table); options != nil {
+		for _, field := range sortedKeys(options.FieldAliases) {
+			if options.FieldAliases[field] == column {
+				return field, nil
+			}
+		}
+	}
+
+	if c.ColumnName != nil {
+		if name := c.ColumnName(column); name != column {
+			return "", fmt.Errorf("column %s.%s would be written to %s by ColumnName", table, column, name)
+		}
+	}
+
+	return column, nil
+}
+
+// readSnapshotTables reads the given tables, or every table of the public
+// schema if none is given.
+func readSnapshotTables(ctx context.Context, conn SnapshotConn, tables []string) ([]*snapshotTable, error) {
+	if len(tables) == 0 {
+		var err error
+
+		tables, err = snapshotTableNames(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	snapshotTables := make([]*snapshotTable, len(tables))
+
+	for i, name := range tables {
+		table, err := readSnapshotTable(ctx, conn, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to snapshot table %s: %w", name, err)
+		}
+
+		snapshotTables[i] = table
+	}
+
+	return snapshotTables, nil
+}
+
+func snapshotTableNames(ctx context.Context, conn SnapshotConn) ([]string, error) {
+	rows, err := conn.Query(ctx, "SELECT tablename FROM pg_tables WHERE schemaname = 'public' ORDER BY tablename")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+
+	return names, nil
+}
+
+func readSnapshotTable(ctx context.Context, conn SnapshotConn, name string) (*snapshotTable, error) {
+	table := &snapshotTable{
+		name:        name,
+		foreignKeys: make(map[string][2]string),
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT a.attname
+		FROM pg_index i
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+		WHERE i.indrelid = $1::regclass AND i.indisprimary`,
+		name,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read primary key: %w", err)
+	}
+
+	primaryKey, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read primary key: %w", err)
+	}
+
+	if len(primaryKey) == 1 {
+		table.primaryKey = primaryKey[0]
+	}
+
+	rows, err = conn.Query(ctx, `
+		SELECT a.attname, c.confrelid::regclass::text, af.attname
+		FROM pg_constraint c
+		JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = c.conkey[1]
+		JOIN pg_attribute af ON af.attrelid = c.confrelid AND af.attnum = c.confkey[1]
+		WHERE c.contype = 'f' AND c.conrelid = $1::regclass AND array_length(c.conkey, 1) = 1`,
+		name,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read foreign keys: %w", err)
+	}
+
+	var column, refTable, refColumn string
+
+	if _, err := pgx.ForEachRow(rows, []any{&column, &refTable, &refColumn}, func() error {
+		table.foreignKeys[column] = [2]string{refTable, refColumn}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to read foreign keys: %w", err)
+	}
+
+	sql := fmt.Sprintf("SELECT row_to_json(t)::text FROM %s t", pgx.Identifier(strings.Split(name, ".")).Sanitize())
+
+	if table.primaryKey != "" {
+		sql += " ORDER BY " + pgx.Identifier{table.primaryKey}.Sanitize()
+	}
+
+	rows, err = conn.Query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rows: %w", err)
+	}
+
+	var row string
+
+	if _, err := pgx.ForEachRow(rows, []any{&row}, func() error {
+		record, err := decodeSnapshotRow(row)
+		if err != nil {
+			return err
+		}
+
+		table.rows = append(table.rows, record)
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to read rows: %w", err)
+	}
+
+	return table, nil
+}
+
+// decodeSnapshotRow decodes a row encoded by row_to_json, converting numbers
+// to int64 when they are integers and float64 otherwise.
+func decodeSnapshotRow(row string) (Record, error) {
+	decoder := json.NewDecoder(strings.NewReader(row))
+	decoder.UseNumber()
+
+	var record Record
+
+	if err := decoder.Decode(&record); err != nil {
+		return nil, fmt.Errorf("failed to decode row: %w", err)
+	}
+
+	for k, v := range record {
+		record[k] = snapshotValue(v)
+	}
+
+	return record, nil
+}
+
+func snapshotValue(v any) any {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+
+		if f, err := t.Float64(); err == nil {
+			return f
+		}
+
+		return t.String()
+	case map[string]any:
+		for k := range t {
+			t[k] = snapshotValue(t[k])
+		}
+	case []any:
+		for i := range t {
+			t[i] = snapshotValue(t[i])
+		}
+	}
+
+	return v
+}
+
+// snapshotDatabase converts the snapshot tables to a Database, keying records
+// by their primary key (or position, starting at 1) and rewriting foreign
+// keys to records of the snapshot as =ref commands. fieldName, if set,
+// returns the fields of the columns.
+func snapshotDatabase(tables []*snapshotTable, fieldName func(table, column string) (string, error)) (Database, error) {
+	if fieldName == nil {
+		fieldName = func(table, column string) (string, error) {
+			return column, nil
+		}
+	}
+
+	database := make(Database, len(tables))
+	byName := make(map[string]*snapshotTable, len(tables))
+
+	// keys maps the values of each table's columns to their record keys,
+	// indexed lazily by column.
+	keys := make(map[[2]string]map[string]string)
+
+	for _, table := range tables {
+		byName[table.name] = table
+	}
+
+	recordKey := func(table *snapshotTable, i int) string {
+		if table.primaryKey != "" {
+			if v, ok := table.rows[i][table.primaryKey]; ok && v != nil {
+				return fmt.Sprint(v)
+			}
+		}
+
+		return fmt.Sprint(i + 1)
+	}
+
+	keyOf := func(table *snapshotTable, column string, value any) (string, bool) {
+		index, ok := keys[[2]string{table.name, column}]
+		if !ok {
+			index = make(map[string]string, len(table.rows))
+
+			for i, row := range table.rows {
+				if v, ok := row[column]; ok && v != nil {
+					index[fmt.Sprint(v)] = recordKey(table, i)
+				}
+			}
+
+			keys[[2]string{table.name, column}] = index
+		}
+
+		key, ok := index[fmt.Sprint(value)]
+
+		return key, ok
+	}
+
+	for _, table := range tables {
+		databaseTable := make(Table, len(table.rows))
+
+		for i, row := range table.rows {
+			record := make(Record, len(row))
+
+			for column, value := range row {
+				field, err := fieldName(table.name, column)
+				if err != nil {
+					return nil, err
+				}
+
+				record[field] = escapeValue(value)
+
+				ref, ok := table.foreignKeys[column]
+				if !ok || value == nil {
+					continue
+				}
+
+				refTable, ok := byName[ref[0]]
+				if !ok {
+					continue
+				}
+
+				refKey, ok := keyOf(refTable, ref[1], value)
+				if !ok {
+					continue
+				}
+
+				if ref[1] == refTable.primaryKey {
+					record[field] = fmt.Sprintf("=ref %s %s", ref[0], refKey)
+					continue
+				}
+
+				refField, err := fieldName(ref[0], ref[1])
+				if err != nil {
+					return nil, err
+				}
+
+				record[field] = fmt.Sprintf("=ref %s %s %s", ref[0], refKey, refField)
+			}
+
+			databaseTable[recordKey(table, i)] = record
+		}
+
+		database[table.name] = databaseTable
+	}
+
+	return database, nil
+}
+
+// WriteTableFiles writes the tables of database to dir as table files, in
+// the format of the given extension (".yaml", ".yml" or ".toml"), so they can
+// be loaded in directory mode.
+func WriteTableFiles(dir, ext string, database Database) error {
+	format, err := bodyFormat(ext)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	for _, name := range sortedKeys(database) {
+		b, err := marshalTable(format, database[name])
+		if err != nil {
+			return fmt.Errorf("failed to marshal table %s: %w", name, err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, name+ext), b, 0o644); err != nil {
+			return fmt.Errorf("failed to write table %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func marshalTable(format int, table Table) ([]byte, error) {
+	if format == yamlFormat {
+		return yaml.Marshal(table)
+	}
+
+	buf := new(bytes.Buffer)
+
+	if err := toml.NewEncoder(buf).Encode(tableWithoutNulls(table)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
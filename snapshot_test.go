@@ -0,0 +1,164 @@
+package fixture
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotDatabase(t *testing.T) {
+	row, err := decodeSnapshotRow(`{"id": 7, "email": "bob@example.com", "score": 1.5, "meta": {"n": 2}}`)
+	if err != nil {
+		t.Fatalf("failed to decode row: %s", err)
+	}
+
+	assert.Equal(t, Record{"id": int64(7), "email": "bob@example.com", "score": 1.5, "meta": map[string]any{"n": int64(2)}}, row)
+
+	database, err := snapshotDatabase([]*snapshotTable{
+		{
+			name:       "users",
+			primaryKey: "id",
+			rows: []Record{
+				{"id": int64(1), "email": "alice@example.com"},
+				row,
+			},
+		},
+		{
+			name: "posts",
+			foreignKeys: map[string][2]string{
+				"user_id":    {"users", "id"},
+				"user_email": {"users", "email"},
+				"plan_id":    {"plans", "id"},
+			},
+			rows: []Record{
+				{"user_id": int64(7), "user_email": "alice@example.com", "plan_id": int64(3)},
+				{"user_id": nil, "user_email": nil, "plan_id": nil},
+			},
+		},
+	}, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, Database{
+		"users": {
+			"1": {"id": int64(1), "email": "alice@example.com"},
+			"7": row,
+		},
+		"posts": {
+			"1": {"user_id": "=ref users 7", "user_email": "=ref users 1 email", "plan_id": int64(3)},
+			"2": {"user_id": nil, "user_email": nil, "plan_id": nil},
+		},
+	}, database)
+}
+
+func TestSnapshotDatabaseFieldAliases(t *testing.T) {
+	config := &Config{
+		TableOptions: map[string]*TableOptions{
+			"users": {FieldAliases: map[string]string{"email": "email_address"}},
+			"posts": {FieldAliases: map[string]string{"author": "user_id"}},
+		},
+	}
+
+	database, err := snapshotDatabase([]*snapshotTable{
+		{
+			name:       "users",
+			primaryKey: "id",
+			rows:       []Record{{"id": int64(1), "email_address": "alice@example.com"}},
+		},
+		{
+			name: "posts",
+			foreignKeys: map[string][2]string{
+				"user_id":    {"users", "id"},
+				"user_email": {"users", "email_address"},
+			},
+			rows: []Record{{"user_id": int64(1), "user_email": "alice@example.com"}},
+		},
+	}, config.fieldName)
+	assert.NoError(t, err)
+
+	assert.Equal(t, Database{
+		"users": {"1": {"id": int64(1), "email": "alice@example.com"}},
+		"posts": {"1": {"author": "=ref users 1", "user_email": "=ref users 1 email"}},
+	}, database)
+}
+
+func TestWriteTableFiles(t *testing.T) {
+	for _, ext := range []string{".yaml", ".toml"} {
+		t.Run(ext, func(st *testing.T) {
+			dir := st.TempDir()
+
+			err := WriteTableFiles(dir, ext, Database{
+				"users": {"alice": {"name": "Alice", "nickname": nil}},
+				"posts": {"p1": {"user_id": "=ref users alice"}},
+			})
+			if err != nil {
+				st.Fatalf("failed to write table files: %s", err)
+			}
+
+			f := &Fixture{
+				Config: &Config{},
+				Writer: &memoryWriter{},
+				File:   dir,
+			}
+
+			if err := f.Apply(); err != nil {
+				st.Fatalf("failed to Apply: %s", err)
+			}
+
+			assert.Equal(st, "Alice", f.Database["users"]["alice"]["name"])
+			assert.Equal(st, f.Database["users"]["alice"]["id"], f.Database["posts"]["p1"]["user_id"])
+		})
+	}
+
+	assert.Error(t, WriteTableFiles(t.TempDir(), ".json", Database{}))
+}
+
+func TestSnapshotDatabaseColumnName(t *testing.T) {
+	config := &Config{ColumnName: SnakeCase}
+	tables := []*snapshotTable{
+		{name: "users", rows: []Record{{"user_id": int64(1), "first_name": "Alice"}}},
+	}
+
+	database, err := snapshotDatabase(tables, config.fieldName)
+	assert.NoError(t, err)
+	assert.Equal(t, Database{"users": {"1": {"user_id": int64(1), "first_name": "Alice"}}}, database)
+
+	config.ColumnName = func(field string) string {
+		return "c_" + field
+	}
+
+	_, err = snapshotDatabase(tables, config.fieldName)
+	assert.ErrorContains(t, err, "would be written to c_")
+}
+
+func TestSnapshotDatabaseEscape(t *testing.T) {
+	row := Record{"formula": "=SUM(A1:A3)", "meta": map[string]any{"cells": []any{"=A1", "B1"}}, "note": "a=b"}
+
+	database, err := snapshotDatabase([]*snapshotTable{{name: "sheets", rows: []Record{row}}}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "==SUM(A1:A3)", database["sheets"]["1"]["formula"])
+
+	for _, ext := range []string{".yaml", ".toml"} {
+		t.Run(ext, func(st *testing.T) {
+			dir := st.TempDir()
+
+			if err := WriteTableFiles(dir, ext, database); err != nil {
+				st.Fatalf("failed to write table files: %s", err)
+			}
+
+			f := &Fixture{
+				Config: &Config{},
+				Writer: &memoryWriter{},
+				File:   dir,
+			}
+
+			if err := f.Apply(); err != nil {
+				st.Fatalf("failed to Apply: %s", err)
+			}
+
+			record := f.Database["sheets"]["1"]
+			assert.Equal(st, row["formula"], record["formula"])
+			assert.Equal(st, row["meta"], record["meta"])
+			assert.Equal(st, row["note"], record["note"])
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package fixture
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ObjectStore is implemented by object storage clients (e.g. S3 or GCS)
+// to load fixture files from remote URLs such as s3://bucket/seed/users.yaml
+// or whole prefixes such as s3://bucket/seed/. See RegisterObjectStore.
+//
+// This package doesn't depend on any storage SDK: an ObjectStore is usually
+// a small adapter over the application's own client.
+type ObjectStore interface {
+	// List returns the keys of the objects starting with prefix.
+	List(ctx context.Context, bucket, prefix string) ([]string, error)
+
+	// Get returns the content of an object.
+	Get(ctx context.Context, bucket, key string) ([]byte, error)
+}
+
+var (
+	objectStores   = map[string]ObjectStore{}
+	objectStoresMu sync.RWMutex
+)
+
+// RegisterObjectStore makes the fixture paths with the given URL scheme
+// (e.g. "s3" or "gs") load from store. Remote paths can be used anywhere a
+// fixture file or directory is accepted, including _include directives.
+func RegisterObjectStore(scheme string, store ObjectStore) {
+	objectStoresMu.Lock()
+	defer objectStoresMu.Unlock()
+
+	objectStores[scheme] = store
+}
+
+// isRemotePath returns whether name is a URL with a registered scheme.
+func isRemotePath(name string) bool {
+	scheme, _, ok := strings.Cut(name, "://")
+	if !ok {
+		return false
+	}
+
+	objectStoresMu.RLock()
+	defer objectStoresMu.RUnlock()
+
+	_, ok = objectStores[scheme]
+
+	return ok
+}
+
+// remoteSource returns the file source of a remote path, the bucket of its
+// object store, and the key of the path in the bucket. Relative includes of
+// its files are fetched from the same bucket.
+func (f *Fixture) remoteSource(name string) (*fileSource, string) {
+	scheme, rest, _ := strings.Cut(name, "://")
+	bucket, key, _ := strings.Cut(rest, "/")
+
+	objectStoresMu.RLock()
+	store := objectStores[scheme]
+	objectStoresMu.RUnlock()
+
+	key = strings.Trim(key, "/")
+	if key == "" {
+		key = "."
+	}
+
+	return &fileSource{
+		fsys:   &objectStoreFS{ctx: f.Context, store: store, bucket: bucket},
+		prefix: scheme + "://" + bucket + "/",
+	}, key
+}
+
+// objectStoreFS is a read-only fs.FS over the objects of a bucket, whose
+// directories are the key prefixes separated by slashes.
+type objectStoreFS struct {
+	ctx    context.Context
+	store  ObjectStore
+	bucket string
+}
+
+func (s *objectStoreFS) Open(name string) (fs.File, error) {
+	b, err := s.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &objectFile{Reader: bytes.NewReader(b), info: objectInfo{name: path.Base(name), size: int64(len(b))}}, nil
+}
+
+func (s *objectStoreFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+
+	b, err := s.store.Get(s.ctx, s.bucket, name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	return b, nil
+}
+
+func (s *objectStoreFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name == "." {
+		return objectInfo{name: ".", dir: true}, nil
+	}
+
+	keys, err := s.store.List(s.ctx, s.bucket, name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	for _, key := range keys {
+		if key == name {
+			return objectInfo{name: path.Base(name)}, nil
+		}
+
+		if strings.HasPrefix(key, name+"/") {
+			return objectInfo{name: path.Base(name), dir: true}, nil
+		}
+	}
+
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+func (s *objectStoreFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+
+	keys, err := s.store.List(s.ctx, s.bucket, prefix)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	seen := make(map[string]bool)
+	entries := make([]fs.DirEntry, 0, len(keys))
+
+	for _, key := range keys {
+		child, rest, isDir := strings.Cut(strings.TrimPrefix(key, prefix), "/")
+		if child == "" || seen[child] {
+			continue
+		}
+
+		seen[child] = true
+		entries = append(entries, fs.FileInfoToDirEntry(objectInfo{name: child, dir: isDir && rest != ""}))
+	}
+
+	if len(entries) == 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+type objectFile struct {
+	*bytes.Reader
+	info objectInfo
+}
+
+func (o *objectFile) Stat() (fs.FileInfo, error) {
+	return o.info, nil
+}
+
+func (o *objectFile) Close() error {
+	return nil
+}
+
+type objectInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i objectInfo) Name() string {
+	return i.name
+}
+
+func (i objectInfo) Size() int64 {
+	return i.size
+}
+
+func (i objectInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+
+	return 0o444
+}
+
+func (i objectInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (i objectInfo) IsDir() bool {
+	return i.dir
+}
+
+func (i objectInfo) Sys() any {
+	return nil
+}
@@ -0,0 +1,103 @@
+package fixture
+
+import (
+	"context"
+	"io/fs"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryObjectStore is an ObjectStore keeping objects in memory, by bucket
+// and key.
+type memoryObjectStore map[string]map[string]string
+
+func (s memoryObjectStore) List(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var keys []string
+
+	for key := range s[bucket] {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+func (s memoryObjectStore) Get(ctx context.Context, bucket, key string) ([]byte, error) {
+	v, ok := s[bucket][key]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+
+	return []byte(v), nil
+}
+
+func TestObjectStoreSource(t *testing.T) {
+	RegisterObjectStore("memtest", memoryObjectStore{
+		"seeds": {
+			"base/plans.yaml":        "plans:\n  free: {name: Free}\n",
+			"prefix/users.yaml":      "alice:\n  plan: =ref plans free\n",
+			"prefix/orders.yaml":     "o1:\n  user: =ref users alice\n",
+			"prefix/nested/skip.txt": "ignored",
+			"main.yaml":              "_include: [base/plans.yaml]\nroles:\n  admin: {name: Admin}\n",
+		},
+	})
+
+	f := &Fixture{
+		Config: &Config{},
+		Writer: &memoryWriter{},
+		Dir:    "ignored/for/remote/paths",
+		Files:  []string{"memtest://seeds/main.yaml", "memtest://seeds/prefix/"},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	assert.Contains(t, f.Database["roles"], "admin")
+	assert.Equal(t, f.Database["plans"]["free"]["id"], f.Database["users"]["alice"]["plan"])
+	assert.Equal(t, f.Database["users"]["alice"]["id"], f.Database["orders"]["o1"]["user"])
+	assert.Nil(t, f.FS)
+
+	f = &Fixture{
+		Config: &Config{},
+		Writer: &memoryWriter{},
+		File:   "memtest://seeds/missing.yaml",
+	}
+
+	assert.Error(t, f.Apply())
+}
+
+func TestObjectStoreSourceIDs(t *testing.T) {
+	RegisterObjectStore("memids", memoryObjectStore{
+		"a": {
+			"plans.yaml": "plans:\n  free: {name: Free}\n",
+			"main.yaml":  "_requires: [plans.yaml]\nusers:\n  alice: {plan: =ref plans free}\n",
+		},
+		"b": {
+			"plans.yaml": "plans:\n  pro: {name: Pro}\n",
+			"main.yaml":  "_requires: [plans.yaml]\nusers:\n  bob: {plan: =ref plans pro}\n",
+		},
+	})
+
+	f := &Fixture{
+		Config: &Config{},
+		Writer: &memoryWriter{},
+		Files:  []string{"memids://a/main.yaml", "memids://b/main.yaml"},
+	}
+
+	if err := f.Apply(); err != nil {
+		t.Fatalf("failed to Apply: %s", err)
+	}
+
+	// Files with the same keys in different buckets are different files.
+	assert.Equal(t, f.Database["plans"]["free"]["id"], f.Database["users"]["alice"]["plan"])
+	assert.Equal(t, f.Database["plans"]["pro"]["id"], f.Database["users"]["bob"]["plan"])
+	assert.Contains(t, f.loaded, "memids://a/plans.yaml")
+	assert.Contains(t, f.loaded, "memids://b/plans.yaml")
+}
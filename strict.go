@@ -0,0 +1,87 @@
+package fixture
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUndefinedRecord is the error of references to records the fixture does
+// not define, with Config.Strict.
+var ErrUndefinedRecord = errors.New("undefined record")
+
+// checkDefined returns an ErrUndefinedRecord error, with Config.Strict, if
+// the referenced record is not defined.
+func (f *Fixture) checkDefined(label [2]string) error {
+	if !f.Config.Strict {
+		return nil
+	}
+
+	if _, ok := f.Database[label[0]][label[1]]; ok {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s.%s", ErrUndefinedRecord, label[0], label[1])
+}
+
+// validateConfig returns, with Config.Strict, the errors of the references
+// (Config.References and TableOptions) to tables the fixture does not
+// define, and of the TableOptions used by none of its tables.
+func (f *Fixture) validateConfig() error {
+	if !f.Config.Strict {
+		return nil
+	}
+
+	var errs []error
+
+	checkTable := func(ref, source string) {
+		if ref == "" {
+			// Not dereferenced.
+			return
+		}
+
+		table, _ := f.Config.splitReference(ref, f.Database)
+
+		if _, ok := f.Database[table]; !ok {
+			errs = append(errs, fmt.Errorf("%s references undefined table %s", source, table))
+		}
+	}
+
+	for _, field := range sortedKeys(f.Config.References) {
+		checkTable(f.Config.References[field], fmt.Sprintf("reference %s", field))
+	}
+
+	// The profiles selected by the _profile field of records.
+	profiles := make(map[string]bool)
+
+	for table, records := range f.Database {
+		for _, record := range records {
+			if profile, ok := record[profileField].(string); ok {
+				profiles[profileName(table, profile)] = true
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(f.Config.TableOptions) {
+		options := f.Config.tableOptions(name)
+
+		if _, ok := f.Database[name]; !ok && !profiles[name] {
+			errs = append(errs, fmt.Errorf("unused table options %s", name))
+		}
+
+		if options == nil {
+			continue
+		}
+
+		for _, field := range sortedKeys(options.References) {
+			checkTable(options.References[field], fmt.Sprintf("table %s, reference %s", name, field))
+		}
+
+		for _, field := range sortedKeys(options.CompositeReferences) {
+			if ref := options.CompositeReferences[field]; ref != nil {
+				checkTable(ref.Table, fmt.Sprintf("table %s, composite reference %s", name, field))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
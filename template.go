@@ -1,24 +1,47 @@
 package fixture
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"text/template"
 
+	"github.com/Masterminds/sprig/v3"
 	"github.com/google/uuid"
 )
 
-var funcMap template.FuncMap
+var (
+	funcMap        = defaultFuncMap()
+	funcMapMu      sync.RWMutex
+	funcMapVersion int
+)
 
+// defaultFuncMap returns the sprig functions (https://masterminds.github.io/sprig/)
+// plus the ones defined by this package.
 func defaultFuncMap() template.FuncMap {
 	fixtureID := uuid.New().String()
 
-	return template.FuncMap{
-		"fixtureID": func() string {
-			return fixtureID
-		},
+	fm := sprig.TxtFuncMap()
+
+	fm["fixtureID"] = func() string {
+		return fixtureID
 	}
+
+	return fm
 }
 
+// AddFuncMap adds functions available to the templates of every fixture.
+// Config.FuncMap and Fixture.FuncMap can be used to add functions to a
+// single config or fixture instead.
 func AddFuncMap(fm template.FuncMap) {
+	funcMapMu.Lock()
+	defer funcMapMu.Unlock()
+
 	if funcMap == nil {
 		funcMap = defaultFuncMap()
 	}
@@ -26,4 +49,213 @@ func AddFuncMap(fm template.FuncMap) {
 	for k, v := range fm {
 		funcMap[k] = v
 	}
+
+	funcMapVersion++
+}
+
+// templateFuncs returns the global functions merged with the config's and
+// the fixture's, in that order of precedence.
+func (f *Fixture) templateFuncs() template.FuncMap {
+	funcMapMu.RLock()
+	defer funcMapMu.RUnlock()
+
+	fm := make(template.FuncMap, len(funcMap)+len(f.Config.FuncMap)+len(f.FuncMap))
+
+	for k, v := range funcMap {
+		fm[k] = v
+	}
+
+	for k, v := range f.Config.FuncMap {
+		fm[k] = v
+	}
+
+	for k, v := range f.FuncMap {
+		fm[k] = v
+	}
+
+	return fm
+}
+
+// parseTemplate returns the compiled template of text. Templates are cached
+// by content hash, so repeated Applies and commands don't parse them again,
+// unless a FuncMap is set, see resetState.
+func (f *Fixture) parseTemplate(text string) (*template.Template, error) {
+	funcMapMu.RLock()
+	version := funcMapVersion
+	funcMapMu.RUnlock()
+
+	if f.templates == nil || f.templatesVersion != version {
+		// AddFuncMap was called since the templates were parsed.
+		f.templates = make(map[[sha256.Size]byte]*template.Template)
+		f.templatesVersion = version
+	}
+
+	hash := sha256.Sum256([]byte(text))
+
+	if t, ok := f.templates[hash]; ok {
+		return t, nil
+	}
+
+	cacheKey, shared := f.sharedTemplateKey(hash, version)
+
+	if shared {
+		if t := cachedTemplate(cacheKey); t != nil {
+			f.templates[hash] = t
+			return t, nil
+		}
+	}
+
+	left, right := f.templateDelims()
+
+	t := template.New("fixture").Funcs(f.templateFuncs()).Delims(left, right)
+
+	if f.Config.TemplateMissingKey != "" {
+		t = t.Option("missingkey=" + f.Config.TemplateMissingKey)
+	}
+
+	t, err := t.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	f.templates[hash] = t
+
+	if shared {
+		cacheTemplate(cacheKey, t)
+	}
+
+	return t, nil
+}
+
+// templateDelims returns the configured template delimiters, "{{" and "}}"
+// by default.
+func (f *Fixture) templateDelims() (string, string) {
+	left, right := f.Config.TemplateDelims[0], f.Config.TemplateDelims[1]
+
+	if left == "" {
+		left = "{{"
+	}
+
+	if right == "" {
+		right = "}}"
+	}
+
+	return left, right
+}
+
+// TemplateDataProvider provides values for the fixture templates, which are
+// merged in order before Fixture.TemplateData. See Fixture.TemplateDataProviders.
+type TemplateDataProvider interface {
+	TemplateData(ctx context.Context) (map[string]any, error)
+}
+
+// TemplateDataFunc is a func implementing TemplateDataProvider.
+type TemplateDataFunc func(ctx context.Context) (map[string]any, error)
+
+func (fn TemplateDataFunc) TemplateData(ctx context.Context) (map[string]any, error) {
+	return fn(ctx)
+}
+
+// EnvTemplateData provides the environment variables starting with prefix,
+// keyed by their name without the prefix. E.g. with the "FIXTURE_" prefix,
+// FIXTURE_TENANT=acme is available as {{ .TENANT }}.
+func EnvTemplateData(prefix string) TemplateDataProvider {
+	return TemplateDataFunc(func(ctx context.Context) (map[string]any, error) {
+		data := make(map[string]any)
+
+		for _, env := range os.Environ() {
+			k, v, _ := strings.Cut(env, "=")
+
+			if name, ok := strings.CutPrefix(k, prefix); ok && name != "" {
+				data[name] = v
+			}
+		}
+
+		return data, nil
+	})
+}
+
+// FileTemplateData provides the values of a JSON, YAML or TOML file,
+// detected by its extension.
+func FileTemplateData(name string) TemplateDataProvider {
+	return TemplateDataFunc(func(ctx context.Context) (map[string]any, error) {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template data file: %w", err)
+		}
+
+		data := make(map[string]any)
+
+		if ext := filepath.Ext(name); strings.EqualFold(ext, ".json") {
+			err = json.Unmarshal(b, &data)
+		} else {
+			var format int
+
+			format, err = bodyFormat(ext)
+			if err != nil {
+				return nil, err
+			}
+
+			err = unmarshalBody(format, b, &data)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal template data file %s: %w", name, err)
+		}
+
+		return data, nil
+	})
+}
+
+// loadTemplateData merges the values of the providers, the suite and
+// TemplateData.
+func (f *Fixture) loadTemplateData() error {
+	f.templateData = nil
+
+	var suiteData map[string]any
+
+	if f.Suite != "" {
+		suite, err := f.Config.GetSuite(f.Suite)
+		if err != nil {
+			return err
+		}
+
+		suiteData = suite.TemplateData
+	}
+
+	if len(f.TemplateDataProviders) == 0 && len(suiteData) == 0 {
+		return nil
+	}
+
+	f.templateData = make(map[string]any)
+
+	for i, provider := range f.TemplateDataProviders {
+		data, err := provider.TemplateData(f.Context)
+		if err != nil {
+			return fmt.Errorf("failed to load template data from provider %d: %w", i, err)
+		}
+
+		for k, v := range data {
+			f.templateData[k] = v
+		}
+	}
+
+	for k, v := range suiteData {
+		f.templateData[k] = v
+	}
+
+	for k, v := range f.TemplateData {
+		f.templateData[k] = v
+	}
+
+	return nil
+}
+
+// templateValues returns the data templates are executed with.
+func (f *Fixture) templateValues() map[string]any {
+	if f.templateData != nil {
+		return f.templateData
+	}
+
+	return f.TemplateData
 }
@@ -1,12 +1,20 @@
 package fixture
 
 import (
+	"bytes"
 	"fmt"
-	"os"
+	"io/fs"
+	"math"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/BurntSushi/toml"
+	"github.com/google/uuid"
 	"github.com/oklog/ulid/v2"
 	"gopkg.in/yaml.v3"
 )
@@ -26,8 +34,147 @@ func ULID(v any, err error) ulid.ULID {
 	return ulid.ULID(v.([16]uint8))
 }
 
+// Int64 is like ULID, for integer values of any size (e.g. int32 for
+// Postgres integer columns) and integral floats. It panics if the value
+// overflows int64.
+func Int64(v any, err error) int64 {
+	if err != nil {
+		panic(fmt.Errorf("fixture.Int64: %w", err))
+	}
+
+	switch t := v.(type) {
+	case int:
+		return int64(t)
+	case int8:
+		return int64(t)
+	case int16:
+		return int64(t)
+	case int32:
+		return int64(t)
+	case int64:
+		return t
+	case uint:
+		if uint64(t) > math.MaxInt64 {
+			panic(fmt.Errorf("fixture.Int64: value %v (%T) overflows int64", v, v))
+		}
+
+		return int64(t)
+	case uint8:
+		return int64(t)
+	case uint16:
+		return int64(t)
+	case uint32:
+		return int64(t)
+	case uint64:
+		if t > math.MaxInt64 {
+			panic(fmt.Errorf("fixture.Int64: value %v (%T) overflows int64", v, v))
+		}
+
+		return int64(t)
+	case float64:
+		if t == math.Trunc(t) {
+			// -2^63 and 2^63 are exact floats, unlike math.MaxInt64.
+			if t < math.MinInt64 || t >= -math.MinInt64 {
+				panic(fmt.Errorf("fixture.Int64: value %v (%T) overflows int64", v, v))
+			}
+
+			return int64(t)
+		}
+	}
+
+	panic(fmt.Errorf("fixture.Int64: unsupported value %v (%T)", v, v))
+}
+
+// String is like ULID, for string values, []byte values and fmt.Stringer
+// implementations.
+func String(v any, err error) string {
+	if err != nil {
+		panic(fmt.Errorf("fixture.String: %w", err))
+	}
+
+	switch t := v.(type) {
+	case string:
+		return t
+	case []byte:
+		return string(t)
+	case fmt.Stringer:
+		return t.String()
+	}
+
+	panic(fmt.Errorf("fixture.String: unsupported value %v (%T)", v, v))
+}
+
+// Time is like ULID, for time.Time values and RFC 3339 strings.
+func Time(v any, err error) time.Time {
+	if err != nil {
+		panic(fmt.Errorf("fixture.Time: %w", err))
+	}
+
+	switch t := v.(type) {
+	case time.Time:
+		return t
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			panic(fmt.Errorf("fixture.Time: %w", err))
+		}
+
+		return parsed
+	}
+
+	panic(fmt.Errorf("fixture.Time: unsupported value %v (%T)", v, v))
+}
+
+// UUID is like ULID, for [16]uint8 values (as returned by pgx for uuid
+// columns), uuid.UUID values and UUID strings.
+func UUID(v any, err error) uuid.UUID {
+	if err != nil {
+		panic(fmt.Errorf("fixture.UUID: %w", err))
+	}
+
+	switch t := v.(type) {
+	case [16]uint8:
+		return uuid.UUID(t)
+	case uuid.UUID:
+		return t
+	case string:
+		parsed, err := uuid.Parse(t)
+		if err != nil {
+			panic(fmt.Errorf("fixture.UUID: %w", err))
+		}
+
+		return parsed
+	}
+
+	panic(fmt.Errorf("fixture.UUID: unsupported value %v (%T)", v, v))
+}
+
+// Bytes is like ULID, for []byte and string values.
+func Bytes(v any, err error) []byte {
+	if err != nil {
+		panic(fmt.Errorf("fixture.Bytes: %w", err))
+	}
+
+	switch t := v.(type) {
+	case []byte:
+		return t
+	case string:
+		return []byte(t)
+	}
+
+	panic(fmt.Errorf("fixture.Bytes: unsupported value %v (%T)", v, v))
+}
+
+// GetDefaultValues reads a TOML or YAML file of default records by table
+// name, as loaded by Config.DefaultValuesDir and TableOptions.DefaultValuesFile.
 func GetDefaultValues(file string) (Table, error) {
-	body, err := os.ReadFile(file)
+	return readDefaultValues(osFS{}, file)
+}
+
+// readDefaultValues reads a file of default values from fsys, see
+// GetDefaultValues.
+func readDefaultValues(fsys fs.FS, file string) (Table, error) {
+	body, err := fs.ReadFile(fsys, file)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
@@ -51,6 +198,19 @@ func GetDefaultValues(file string) (Table, error) {
 	return table, nil
 }
 
+// sortedKeys returns the keys of m in ascending order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
 func bodyFormat(ext string) (int, error) {
 	switch strings.ToLower(ext) {
 	case ".toml":
@@ -61,3 +221,189 @@ func bodyFormat(ext string) (int, error) {
 
 	return 0, fmt.Errorf("unsupported file extension: %s", ext)
 }
+
+// deepMerge merges src into dst, recursively for nested maps. Other values
+// of src, including lists, replace the ones of dst.
+func deepMerge(dst, src map[string]any) {
+	for k, v := range src {
+		srcMap, ok := stringMap(v)
+		if !ok {
+			dst[k] = v
+			continue
+		}
+
+		dstMap, ok := stringMap(dst[k])
+		if !ok {
+			dst[k] = v
+			continue
+		}
+
+		deepMerge(dstMap, srcMap)
+		dst[k] = dstMap
+	}
+}
+
+// copyDatabase returns a deep copy of database, see deepCopy.
+func copyDatabase(database Database) Database {
+	if database == nil {
+		return nil
+	}
+
+	copied := make(Database, len(database))
+
+	for name, table := range database {
+		t := make(Table, len(table))
+
+		for key, record := range table {
+			t[key] = deepCopy(record).(Record)
+		}
+
+		copied[name] = t
+	}
+
+	return copied
+}
+
+// deepCopy returns a copy of v, recursively for maps, lists and byte slices.
+func deepCopy(v any) any {
+	switch t := v.(type) {
+	case Record:
+		m := make(Record, len(t))
+
+		for k := range t {
+			m[k] = deepCopy(t[k])
+		}
+
+		return m
+	case map[string]any:
+		m := make(map[string]any, len(t))
+
+		for k := range t {
+			m[k] = deepCopy(t[k])
+		}
+
+		return m
+	case map[any]any:
+		m := make(map[any]any, len(t))
+
+		for k := range t {
+			m[k] = deepCopy(t[k])
+		}
+
+		return m
+	case []any:
+		l := make([]any, len(t))
+
+		for i := range t {
+			l[i] = deepCopy(t[i])
+		}
+
+		return l
+	case []map[string]any:
+		// E.g. TOML arrays of tables.
+		l := make([]map[string]any, len(t))
+
+		for i := range t {
+			l[i] = deepCopy(t[i]).(map[string]any)
+		}
+
+		return l
+	case []byte:
+		return append([]byte(nil), t...)
+	}
+
+	return v
+}
+
+// databaseWithoutNulls returns a copy of database without the null fields of
+// its records, which TOML doesn't support.
+func databaseWithoutNulls(database Database) Database {
+	copied := make(Database, len(database))
+
+	for name, table := range database {
+		copied[name] = tableWithoutNulls(table)
+	}
+
+	return copied
+}
+
+// tableWithoutNulls is like databaseWithoutNulls, for a table.
+func tableWithoutNulls(table Table) Table {
+	copied := make(Table, len(table))
+
+	for key, record := range table {
+		copied[key] = make(Record, len(record))
+
+		for k, v := range record {
+			if v != nil {
+				copied[key][k] = v
+			}
+		}
+	}
+
+	return copied
+}
+
+// renameFields renames the fields of record by names, mapping old names to
+// new ones.
+func renameFields(record Record, names map[string]string) {
+	renamed := make(Record, len(names))
+
+	for from, to := range names {
+		if v, ok := record[from]; ok {
+			delete(record, from)
+			renamed[to] = v
+		}
+	}
+
+	for k, v := range renamed {
+		record[k] = v
+	}
+}
+
+// invertNames returns the new names of names mapped to the old ones.
+func invertNames(names map[string]string) map[string]string {
+	inverted := make(map[string]string, len(names))
+
+	for from, to := range names {
+		inverted[to] = from
+	}
+
+	return inverted
+}
+
+// SnakeCase returns the snake_case form of a camelCase or PascalCase name,
+// e.g. "userID" and "UserId" become "user_id", for Config.ColumnName.
+func SnakeCase(name string) string {
+	runes := []rune(name)
+
+	var b strings.Builder
+
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && runes[i-1] != '_' && (!unicode.IsUpper(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+
+			r = unicode.ToLower(r)
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// goroutineID returns the id of the calling goroutine, read from the header
+// of its stack trace, e.g. "goroutine 18 [running]:".
+func goroutineID() uint64 {
+	var buf [64]byte
+
+	b := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+	b, _, _ = bytes.Cut(b, []byte(" "))
+
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+
+	return id
+}
@@ -0,0 +1,74 @@
+package fixture
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedFieldHelpers(t *testing.T) {
+	id := uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	f := &Fixture{
+		Database: Database{"users": {"alice": {
+			"id":         int32(7),
+			"score":      float64(3),
+			"name":       "Alice",
+			"avatar":     []byte("png"),
+			"team_id":    [16]uint8(id),
+			"team":       id.String(),
+			"created_at": at,
+			"born_at":    "2024-01-02T03:04:05Z",
+		}}},
+	}
+
+	assert.Equal(t, int64(7), Int64(f.GetField("users", "alice", "id")))
+	assert.Equal(t, int64(3), Int64(f.GetField("users", "alice", "score")))
+	assert.Equal(t, "Alice", String(f.GetField("users", "alice", "name")))
+	assert.Equal(t, "png", String(f.GetField("users", "alice", "avatar")))
+	assert.Equal(t, []byte("Alice"), Bytes(f.GetField("users", "alice", "name")))
+	assert.Equal(t, id, UUID(f.GetField("users", "alice", "team_id")))
+	assert.Equal(t, id, UUID(f.GetField("users", "alice", "team")))
+	assert.Equal(t, at, Time(f.GetField("users", "alice", "created_at")))
+	assert.Equal(t, at, Time(f.GetField("users", "alice", "born_at")))
+
+	assert.PanicsWithError(t, "fixture.Int64: unsupported value Alice (string)", func() {
+		Int64(f.GetField("users", "alice", "name"))
+	})
+
+	assert.Equal(t, int64(math.MaxInt64), Int64(uint64(math.MaxInt64), nil))
+	assert.Equal(t, int64(42), Int64(uint(42), nil))
+	assert.Equal(t, int64(math.MinInt64), Int64(float64(math.MinInt64), nil))
+	assert.PanicsWithError(t, "fixture.Int64: value 9223372036854775808 (uint64) overflows int64", func() {
+		Int64(uint64(math.MaxInt64)+1, nil)
+	})
+	assert.PanicsWithError(t, "fixture.Int64: value 18446744073709551615 (uint) overflows int64", func() {
+		Int64(uint(math.MaxUint64), nil)
+	})
+	assert.PanicsWithError(t, "fixture.Int64: value 9.223372036854776e+18 (float64) overflows int64", func() {
+		Int64(float64(math.MaxInt64), nil)
+	})
+	assert.PanicsWithError(t, "fixture.Int64: unsupported value 1.5 (float64)", func() {
+		Int64(1.5, nil)
+	})
+	assert.PanicsWithError(t, "fixture.String: record not found", func() {
+		String(f.GetField("users", "bob", "name"))
+	})
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, expected := range map[string]string{
+		"createdAt":   "created_at",
+		"userID":      "user_id",
+		"UserId":      "user_id",
+		"HTTPServer":  "http_server",
+		"already_set": "already_set",
+		"line2Total":  "line2_total",
+		"id":          "id",
+	} {
+		assert.Equal(t, expected, SnakeCase(name), name)
+	}
+}
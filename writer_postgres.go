@@ -2,6 +2,9 @@ package fixture
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/jackc/pgx/v5"
@@ -13,41 +16,20 @@ type PostgresWriter struct {
 	Conn   *pgxpool.Pool
 	Tx     pgx.Tx
 	GormDB *gorm.DB
+
+	// statements caches the insert SQL by table and columns, see insertSQL.
+	statementsMu sync.Mutex
+	statements   map[string]string
 }
 
 func (w *PostgresWriter) Insert(f *Fixture, table string, key string, record Record) error {
-	queryFields := make([]string, len(record))
-	queryValues := make([]any, len(record))
-
-	var j int
-
-	for k, v := range record {
-		queryFields[j] = k
-		queryValues[j] = v
-		j++
-	}
-
 	if v := f.Config.TableAlias(table); v != "" {
 		table = v
 	}
 
-	var args []any
-	var err error
-	var sql string
-
-	if len(queryFields) == 0 {
-		sql = fmt.Sprintf("INSERT INTO %s DEFAULT VALUES RETURNING *", table)
-	} else {
-		sql, args, err = squirrel.StatementBuilder.
-			PlaceholderFormat(squirrel.Dollar).
-			Insert(table).
-			Columns(queryFields...).
-			Values(queryValues...).
-			Suffix("RETURNING *").
-			ToSql()
-		if err != nil {
-			return fmt.Errorf("failed to generate sql: %w", err)
-		}
+	sql, args, err := w.insertSQL(table, record)
+	if err != nil {
+		return err
 	}
 
 	f.Logger.Debug().
@@ -60,7 +42,7 @@ func (w *PostgresWriter) Insert(f *Fixture, table string, key string, record Rec
 	if w.GormDB != nil {
 		values := make(map[string]any)
 
-		if err := w.GormDB.WithContext(f.Context).Raw(sql, args...).Find(&values).Error; err != nil {
+		if err := w.GormDB.WithContext(f.WriteContext()).Raw(sql, args...).Find(&values).Error; err != nil {
 			return fmt.Errorf("failed query gorm database: %w", err)
 		}
 
@@ -75,9 +57,9 @@ func (w *PostgresWriter) Insert(f *Fixture, table string, key string, record Rec
 
 	switch {
 	case w.Tx != nil:
-		rows, err = w.Tx.Query(f.Context, sql, args...)
+		rows, err = w.Tx.Query(f.WriteContext(), sql, args...)
 	case w.Conn != nil:
-		rows, err = w.Conn.Query(f.Context, sql, args...)
+		rows, err = w.Conn.Query(f.WriteContext(), sql, args...)
 	default:
 		return fmt.Errorf("no connection or transaction")
 	}
@@ -106,6 +88,353 @@ func (w *PostgresWriter) Insert(f *Fixture, table string, key string, record Rec
 	return nil
 }
 
+func (w *PostgresWriter) Query(f *Fixture, sql string, args ...any) ([]Record, error) {
+	f.Logger.Debug().
+		Str("sql", sql).
+		Interface("sql_args", args).
+		Send()
+
+	if w.GormDB != nil {
+		var values []map[string]any
+
+		if err := w.GormDB.WithContext(f.WriteContext()).Raw(sql, args...).Find(&values).Error; err != nil {
+			return nil, fmt.Errorf("failed query gorm database: %w", err)
+		}
+
+		records := make([]Record, len(values))
+
+		for i := range values {
+			records[i] = values[i]
+		}
+
+		return records, nil
+	}
+
+	var rows pgx.Rows
+	var err error
+
+	switch {
+	case w.Tx != nil:
+		rows, err = w.Tx.Query(f.WriteContext(), sql, args...)
+	case w.Conn != nil:
+		rows, err = w.Conn.Query(f.WriteContext(), sql, args...)
+	default:
+		return nil, fmt.Errorf("no connection or transaction")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed query database: %w", err)
+	}
+
+	defer rows.Close()
+
+	var records []Record
+
+	fieldDescriptions := rows.FieldDescriptions()
+
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get row values: %w", err)
+		}
+
+		record := make(Record, len(values))
+
+		for j := range values {
+			record[fieldDescriptions[j].Name] = values[j]
+		}
+
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rows: %w", err)
+	}
+
+	return records, nil
+}
+
+func (w *PostgresWriter) Find(f *Fixture, table string, where Record) (Record, error) {
+	if v := f.Config.TableAlias(table); v != "" {
+		table = v
+	}
+
+	sql, args, err := squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Select("*").
+		From(table).
+		Where(squirrel.Eq(where)).
+		Limit(2).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate sql: %w", err)
+	}
+
+	records, err := w.Query(f, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(records) {
+	case 0:
+		return nil, ErrRecordNotFound
+	case 1:
+		return records[0], nil
+	}
+
+	return nil, fmt.Errorf("more than one record found in %s", table)
+}
+
+// Columns returns the columns of a table from information_schema. Tables not
+// qualified by a schema are looked up in the current schema. Array columns
+// are typed by their elements, e.g. "uuid[]", and columns of user-defined
+// types by their name, e.g. "hstore", with their labels if they are enums.
+func (w *PostgresWriter) Columns(f *Fixture, table string) ([]Column, error) {
+	if v := f.Config.TableAlias(table); v != "" {
+		table = v
+	}
+
+	schema, name := "", table
+
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		schema, name = table[:i], table[i+1:]
+	}
+
+	records, err := w.Query(f, `SELECT column_name::text AS column_name,
+	CASE data_type
+		WHEN 'ARRAY' THEN format_type(to_regtype(quote_ident(udt_schema) || '.' || quote_ident(udt_name)), NULL)
+		WHEN 'USER-DEFINED' THEN udt_name::text
+		ELSE data_type::text END AS data_type,
+	(
+		SELECT array_agg(e.enumlabel::text ORDER BY e.enumsortorder) FROM pg_enum e
+		WHERE e.enumtypid = to_regtype(quote_ident(udt_schema) || '.' || quote_ident(udt_name))
+	) AS enum_values
+FROM information_schema.columns
+WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND table_name = $2
+ORDER BY ordinal_position`, strings.Trim(schema, `"`), strings.Trim(name, `"`))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrTableNotFound, table)
+	}
+
+	columns := make([]Column, len(records))
+
+	for i, record := range records {
+		columns[i].Name, _ = record["column_name"].(string)
+		columns[i].Type, _ = record["data_type"].(string)
+
+		if values, ok := record["enum_values"].([]any); ok {
+			for _, v := range values {
+				label, _ := v.(string)
+				columns[i].Values = append(columns[i].Values, label)
+			}
+		}
+	}
+
+	return columns, nil
+}
+
+// ForeignKeys returns the single column foreign keys of the database, from
+// information_schema. Tables outside of the current schema are qualified by
+// their schema.
+func (w *PostgresWriter) ForeignKeys(f *Fixture) ([]ForeignKey, error) {
+	records, err := w.Query(f, `SELECT
+	CASE WHEN kcu.table_schema = current_schema() THEN kcu.table_name::text
+		ELSE kcu.table_schema || '.' || kcu.table_name END AS table_name,
+	kcu.column_name::text AS column_name,
+	CASE WHEN ccu.table_schema = current_schema() THEN ccu.table_name::text
+		ELSE ccu.table_schema || '.' || ccu.table_name END AS ref_table,
+	ccu.column_name::text AS ref_column
+FROM information_schema.table_constraints tc
+JOIN information_schema.key_column_usage kcu
+	ON kcu.constraint_schema = tc.constraint_schema AND kcu.constraint_name = tc.constraint_name
+JOIN information_schema.constraint_column_usage ccu
+	ON ccu.constraint_schema = tc.constraint_schema AND ccu.constraint_name = tc.constraint_name
+WHERE tc.constraint_type = 'FOREIGN KEY' AND (
+	SELECT count(*) FROM information_schema.key_column_usage k
+	WHERE k.constraint_schema = tc.constraint_schema AND k.constraint_name = tc.constraint_name
+) = 1
+ORDER BY 1, 2`)
+	if err != nil {
+		return nil, err
+	}
+
+	foreignKeys := make([]ForeignKey, len(records))
+
+	for i, record := range records {
+		foreignKeys[i].Table, _ = record["table_name"].(string)
+		foreignKeys[i].Column, _ = record["column_name"].(string)
+		foreignKeys[i].RefTable, _ = record["ref_table"].(string)
+		foreignKeys[i].RefColumn, _ = record["ref_column"].(string)
+	}
+
+	return foreignKeys, nil
+}
+
+// postgresValue converts a record value to a squirrel value, embedding Raw
+// values as SQL expressions. Lists are converted by postgresArray, and
+// arbitrary precision numbers by postgresDecimal.
+func postgresValue(v any) any {
+	if s, ok := postgresDecimal(v); ok {
+		return s
+	}
+
+	switch t := v.(type) {
+	case Raw:
+		return squirrel.Expr(string(t))
+	case []any:
+		return postgresArray(t)
+	}
+
+	return v
+}
+
+// postgresArray converts a list of scalars of the same kind to a slice of
+// their type, e.g. []string or []int64, which pgx encodes as arrays such as
+// text[] or bigint[]. Other lists, e.g. of maps for json columns, are
+// returned as is. See Config.CoerceValues for arrays of other types, such
+// as uuid[].
+func postgresArray(list []any) any {
+	if len(list) == 0 {
+		return list
+	}
+
+	switch list[0].(type) {
+	case string:
+		return typedArray[string](list, func(v any) (string, bool) {
+			s, ok := v.(string)
+			return s, ok
+		})
+	case bool:
+		return typedArray[bool](list, func(v any) (bool, bool) {
+			b, ok := v.(bool)
+			return b, ok
+		})
+	case int, int32, int64:
+		return typedArray[int64](list, func(v any) (int64, bool) {
+			switch n := v.(type) {
+			case int:
+				return int64(n), true
+			case int32:
+				return int64(n), true
+			case int64:
+				return n, true
+			}
+
+			return 0, false
+		})
+	case float64:
+		return typedArray[float64](list, func(v any) (float64, bool) {
+			f, ok := v.(float64)
+			return f, ok
+		})
+	}
+
+	if elem := reflect.TypeOf(list[0]); elem.Kind() != reflect.Map && elem.Kind() != reflect.Slice {
+		// Values of the same type, e.g. coerced ones.
+		array := reflect.MakeSlice(reflect.SliceOf(elem), len(list), len(list))
+
+		for i, v := range list {
+			if reflect.TypeOf(v) != elem {
+				return list
+			}
+
+			array.Index(i).Set(reflect.ValueOf(v))
+		}
+
+		return array.Interface()
+	}
+
+	return list
+}
+
+// typedArray returns the values of list converted by convert, or list if any
+// can't be.
+func typedArray[T any](list []any, convert func(v any) (T, bool)) any {
+	array := make([]T, len(list))
+
+	for i, v := range list {
+		t, ok := convert(v)
+		if !ok {
+			return list
+		}
+
+		array[i] = t
+	}
+
+	return array
+}
+
+// insertSQL returns the insert SQL of a record and its arguments. The SQL is
+// generated once per table and set of columns, so records with the same
+// columns share a statement, which pgx prepares once per connection. Records
+// with Raw values are not cached, as their SQL embeds the values.
+func (w *PostgresWriter) insertSQL(table string, record Record) (string, []any, error) {
+	columns := sortedKeys(record)
+	args := make([]any, len(columns))
+
+	for i, column := range columns {
+		if _, ok := record[column].(Raw); ok {
+			return postgresInsertSQL(table, record)
+		}
+
+		args[i] = postgresValue(record[column])
+	}
+
+	cacheKey := table + "(" + strings.Join(columns, ",") + ")"
+
+	w.statementsMu.Lock()
+	defer w.statementsMu.Unlock()
+
+	if sql, ok := w.statements[cacheKey]; ok {
+		return sql, args, nil
+	}
+
+	sql, args, err := postgresInsertSQL(table, record)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if w.statements == nil {
+		w.statements = make(map[string]string)
+	}
+
+	w.statements[cacheKey] = sql
+
+	return sql, args, nil
+}
+
+// postgresInsertSQL returns the insert SQL of a record, with its columns
+// sorted by name.
+func postgresInsertSQL(table string, record Record) (string, []any, error) {
+	if len(record) == 0 {
+		return fmt.Sprintf("INSERT INTO %s DEFAULT VALUES RETURNING *", table), nil, nil
+	}
+
+	queryFields := sortedKeys(record)
+	queryValues := make([]any, len(queryFields))
+
+	for j, k := range queryFields {
+		queryValues[j] = postgresValue(record[k])
+	}
+
+	sql, args, err := squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Insert(table).
+		Columns(queryFields...).
+		Values(queryValues...).
+		Suffix("RETURNING *").
+		ToSql()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate sql: %w", err)
+	}
+
+	return sql, args, nil
+}
+
 func (w *PostgresWriter) Update(f *Fixture, table string, key string, record Record) error {
 	queryFields := make([]string, len(record))
 	queryValues := make([]any, len(record))
@@ -114,7 +443,7 @@ func (w *PostgresWriter) Update(f *Fixture, table string, key string, record Rec
 
 	for k, v := range record {
 		queryFields[j] = k
-		queryValues[j] = v
+		queryValues[j] = postgresValue(v)
 		j++
 	}
 
@@ -137,9 +466,9 @@ func (w *PostgresWriter) Update(f *Fixture, table string, key string, record Rec
 
 	switch {
 	case w.Tx != nil:
-		rows, err = w.Tx.Query(f.Context, sql, args...)
+		rows, err = w.Tx.Query(f.WriteContext(), sql, args...)
 	case w.Conn != nil:
-		rows, err = w.Conn.Query(f.Context, sql, args...)
+		rows, err = w.Conn.Query(f.WriteContext(), sql, args...)
 	default:
 		return fmt.Errorf("no connection or transaction")
 	}
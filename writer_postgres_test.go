@@ -0,0 +1,76 @@
+package fixture
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresInsertSQL(t *testing.T) {
+	sql, args, err := postgresInsertSQL("users", Record{
+		"created_at": Raw("NOW() - interval '1 day'"),
+	})
+	if err != nil {
+		t.Fatalf("failed to generate sql: %s", err)
+	}
+
+	assert.Equal(t, "INSERT INTO users (created_at) VALUES (NOW() - interval '1 day') RETURNING *", sql)
+	assert.Empty(t, args)
+
+	sql, args, err = postgresInsertSQL("users", Record{"name": "alice"})
+	if err != nil {
+		t.Fatalf("failed to generate sql: %s", err)
+	}
+
+	assert.Equal(t, "INSERT INTO users (name) VALUES ($1) RETURNING *", sql)
+	assert.Equal(t, []any{"alice"}, args)
+
+	sql, _, err = postgresInsertSQL("users", Record{})
+	if err != nil {
+		t.Fatalf("failed to generate sql: %s", err)
+	}
+
+	assert.Equal(t, "INSERT INTO users DEFAULT VALUES RETURNING *", sql)
+}
+
+func TestPostgresWriterInsertSQL(t *testing.T) {
+	w := &PostgresWriter{}
+
+	sql, args, err := w.insertSQL("users", Record{"name": "alice", "email": "alice@example.com"})
+	if err != nil {
+		t.Fatalf("failed to generate sql: %s", err)
+	}
+
+	assert.Equal(t, "INSERT INTO users (email,name) VALUES ($1,$2) RETURNING *", sql)
+	assert.Equal(t, []any{"alice@example.com", "alice"}, args)
+
+	cached, args, err := w.insertSQL("users", Record{"email": "bob@example.com", "name": "bob"})
+	if err != nil {
+		t.Fatalf("failed to generate sql: %s", err)
+	}
+
+	assert.Equal(t, sql, cached)
+	assert.Equal(t, []any{"bob@example.com", "bob"}, args)
+	assert.Len(t, w.statements, 1)
+
+	sql, args, err = w.insertSQL("users", Record{"name": "carol", "email": Raw("'carol@' || 'example.com'")})
+	if err != nil {
+		t.Fatalf("failed to generate sql: %s", err)
+	}
+
+	assert.Equal(t, "INSERT INTO users (email,name) VALUES ('carol@' || 'example.com',$1) RETURNING *", sql)
+	assert.Equal(t, []any{"carol"}, args)
+	assert.Len(t, w.statements, 1)
+}
+
+func TestPostgresValue(t *testing.T) {
+	assert.Equal(t, []string{"a", "b"}, postgresValue([]any{"a", "b"}))
+	assert.Equal(t, []int64{1, 2}, postgresValue([]any{1, int64(2)}))
+	assert.Equal(t, []float64{1.5}, postgresValue([]any{1.5}))
+	assert.Equal(t, []bool{true}, postgresValue([]any{true}))
+
+	// Mixed lists and lists of maps (e.g. for json columns) are kept.
+	assert.Equal(t, []any{"a", 1}, postgresValue([]any{"a", 1}))
+	assert.Equal(t, []any{map[string]any{"a": 1}}, postgresValue([]any{map[string]any{"a": 1}}))
+	assert.Equal(t, []any{}, postgresValue([]any{}))
+}
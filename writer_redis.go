@@ -77,7 +77,7 @@ func (w *RedisWriter) Insert(f *Fixture, table string, key string, record Record
 			args.KeepTTL = v
 		}
 
-		_, err := cli.SetArgs(f.Context, recordKey, record["value"], args).Result()
+		_, err := cli.SetArgs(f.WriteContext(), recordKey, record["value"], args).Result()
 		if err != nil {
 			return fmt.Errorf("failed to set key: %w", err)
 		}
@@ -97,7 +97,7 @@ func (w *RedisWriter) Insert(f *Fixture, table string, key string, record Record
 			j += 2
 		}
 
-		_, err := cli.HSet(f.Context, recordKey, values...).Result()
+		_, err := cli.HSet(f.WriteContext(), recordKey, values...).Result()
 		if err != nil {
 			return fmt.Errorf("failed to set hash: %w", err)
 		}